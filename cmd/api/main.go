@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"os"
+	"test/internal/auth"
+	dbrepo "test/internal/db"
+	"test/internal/jobs"
 	"test/internal/router"
-
-	//dbrepo "test/internal/db"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -33,12 +36,30 @@ func main() {
 
 	log.Println("Connected to PostgreSQL successfully!")
 
-	//dbrepo.DropTables(db)
-	//dbrepo.CreateTables(db)
-	//dbrepo.InsertDummy(db)
-	//dbrepo.MiscDB(db)
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		_, err = dbrepo.Migrate(context.Background(), db)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	autoCloseInterval := 5 * time.Minute
+	if s := os.Getenv("AUTO_CLOSE_INTERVAL"); s != "" {
+		autoCloseInterval, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("AUTO_CLOSE_INTERVAL: %v", err)
+		}
+	}
+	if autoCloseInterval > 0 {
+		go jobs.RunAutoCloser(context.Background(), db, autoCloseInterval)
+	}
+
+	keys, err := auth.CurrentKeyring()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r := router.CreateRouter(db)
+	r := router.CreateRouter(db, keys)
 
 	port := os.Getenv("PORT")
 	log.Fatal(router.RunServer(":" + port, r))
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"test/internal/auth"
+	"test/internal/manage"
+	"test/internal/model"
+)
+
+func runBootstrapAdmin(args []string) error {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ExitOnError)
+	kt := flags.String("kt", "", "kennitala of the admin (required)")
+	firstName := flags.String("first-name", "", "first name")
+	lastName := flags.String("last-name", "", "last name")
+	email := flags.String("email", "", "login email (required)")
+	workspaceName := flags.String("workspace", "", "name of the workspace to create (required)")
+	companyName := flags.String("company", "", "name of the company to create (required)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: kronosctl bootstrap-admin [flags]")
+		fmt.Fprintln(flags.Output(), "\nthe password and PIN are read from KRONOS_ADMIN_PASSWORD and KRONOS_ADMIN_PIN")
+		fmt.Fprintln(flags.Output(), "so they don't end up in shell history")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	password := os.Getenv("KRONOS_ADMIN_PASSWORD")
+	pin := os.Getenv("KRONOS_ADMIN_PIN")
+
+	if *kt == "" || *email == "" || *workspaceName == "" || *companyName == "" || password == "" {
+		flags.Usage()
+		return fmt.Errorf("bootstrap-admin: missing required flags or KRONOS_ADMIN_PASSWORD")
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	input := auth.ProfileCreate{
+		KT:        *kt,
+		FirstName: *firstName,
+		LastName:  *lastName,
+		Email:     email,
+		Password:  &password,
+	}
+	if pin != "" {
+		input.Pin = &pin
+	}
+
+	result, err := bootstrapAdmin(context.Background(), db, input, *workspaceName, *companyName)
+	if err != nil {
+		return err
+	}
+
+	log.Printf(
+		"Created owner profile %d in workspace %d, company %d",
+		result.Profile.ID,
+		result.Workspace.Id,
+		result.Company.Id,
+	)
+	return nil
+}
+
+type bootstrapResult struct {
+	Profile   *model.Profile
+	Workspace *model.Workspace
+	Company   *model.Company
+}
+
+// bootstrapAdmin creates the owner profile, its workspace and a first company
+// in one transaction, so a failure leaves nothing behind to trip up a re-run.
+// The owner is employed at the workspace and through it manages every
+// company in it.
+func bootstrapAdmin(
+	ctx context.Context,
+	db *sql.DB,
+	input auth.ProfileCreate,
+	workspaceName string,
+	companyName string,
+) (*bootstrapResult, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap-admin: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	profile, err := auth.InsertProfile(ctx, tx, input)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap-admin: %w", err)
+	}
+
+	workspace, err := manage.InsertWorkspace(ctx, tx, manage.WorkspaceCreate{Name: workspaceName}, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap-admin: %w", err)
+	}
+
+	company, err := manage.InsertCompany(ctx, tx, manage.CompanyCreate{
+		Name:        companyName,
+		WorkspaceId: workspace.Id,
+	}, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap-admin: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("bootstrap-admin: db commit: %w", err)
+	}
+
+	return &bootstrapResult{
+		Profile:   profile,
+		Workspace: workspace,
+		Company:   company,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"test/internal/auth"
+	"test/internal/db/dbtest"
+	"testing"
+)
+
+func bootstrapInput(kt string) auth.ProfileCreate {
+	email := kt + "@example.com"
+	password := "correct horse battery staple"
+	pin := "1234"
+	return auth.ProfileCreate{
+		KT:        kt,
+		FirstName: "Anna",
+		LastName:  "Jónsdóttir",
+		Email:     &email,
+		Password:  &password,
+		Pin:       &pin,
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	result, err := bootstrapAdmin(ctx, db, bootstrapInput("0101302989"), "Acme", "Acme ehf.")
+	if err != nil {
+		t.Fatalf("bootstrapAdmin: %v", err)
+	}
+
+	if *result.Company.WorkspaceId != result.Workspace.Id {
+		t.Errorf("company in workspace %d, want %d", *result.Company.WorkspaceId, result.Workspace.Id)
+	}
+
+	// one owner employment, bound to the workspace
+	employments := dbtest.QueryInt(t, db, `
+		SELECT count(*) FROM employment
+		WHERE profile_id = $1 AND role = 'owner'
+		AND workspace_id = $2 AND company_id IS NULL AND end_date IS NULL
+	`, result.Profile.ID, result.Workspace.Id)
+	if employments != 1 {
+		t.Errorf("got %d owner employments, want 1", employments)
+	}
+	if n := dbtest.QueryInt(t, db, `SELECT count(*) FROM employment`); n != 1 {
+		t.Errorf("got %d employments in total, want 1", n)
+	}
+}
+
+func TestBootstrapAdminRollsBack(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	_, err := bootstrapAdmin(ctx, db, bootstrapInput("0101302989"), "Acme", "Acme ehf.")
+	if err != nil {
+		t.Fatalf("bootstrapAdmin: %v", err)
+	}
+
+	// the kt is taken, so nothing of the second run may stay behind
+	_, err = bootstrapAdmin(ctx, db, bootstrapInput("0101302989"), "Other", "Other ehf.")
+	if err == nil {
+		t.Fatal("bootstrapAdmin with a taken kt succeeded")
+	}
+
+	for _, table := range []string{"profile", "workspace", "company", "employment"} {
+		if n := dbtest.QueryInt(t, db, `SELECT count(*) FROM `+table); n != 1 {
+			t.Errorf("got %d rows in %s, want 1", n, table)
+		}
+	}
+
+	// an invalid PIN fails only after the profile has been inserted
+	input := bootstrapInput("1212902219")
+	input.Pin = new(string)
+	_, err = bootstrapAdmin(ctx, db, input, "Other", "Other ehf.")
+	if err == nil {
+		t.Fatal("bootstrapAdmin with an invalid PIN succeeded")
+	}
+	if n := dbtest.QueryInt(t, db, `SELECT count(*) FROM profile`); n != 1 {
+		t.Errorf("got %d profiles, want 1", n)
+	}
+}
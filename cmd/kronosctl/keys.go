@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"test/internal/auth"
+	"time"
+)
+
+// runRotateKey adds a new signing key to JWT_KEYS_FILE. The new key starts
+// signing after -delay, which has to be long enough for every instance to be
+// restarted with the new file. The keys signing until then keep verifying for
+// -retire-after more, which has to cover the access token lifetime.
+func runRotateKey(args []string) error {
+	flags := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	path := flags.String("file", os.Getenv("JWT_KEYS_FILE"), "keys file, defaults to JWT_KEYS_FILE")
+	alg := flags.String("alg", "EdDSA", "algorithm of the new key: EdDSA, ES256 or HS256")
+	kid := flags.String("kid", time.Now().UTC().Format("20060102T150405Z"), "id of the new key")
+	delay := flags.Duration("delay", 10*time.Minute, "time until the new key starts signing")
+	retireAfter := flags.Duration("retire-after", 15*time.Minute, "time old keys keep verifying after the new key starts signing")
+	flags.Parse(args)
+
+	if *path == "" {
+		return fmt.Errorf("rotate-key: no keys file, pass -file or set JWT_KEYS_FILE")
+	}
+
+	entries, err := auth.ReadKeyFile(*path)
+	if errors.Is(err, fs.ErrNotExist) {
+		entries = nil
+		// carry the JWT_SECRET key over so tokens signed with it stay valid
+		// while moving to a keys file
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			entries = append(entries, auth.KeyFileEntry{
+				ID:     "default",
+				Alg:    "HS256",
+				Secret: base64.StdEncoding.EncodeToString([]byte(secret)),
+			})
+		}
+	} else if err != nil {
+		return fmt.Errorf("rotate-key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	notBefore := now.Add(*delay)
+	retireAt := notBefore.Add(*retireAfter)
+
+	kept := []auth.KeyFileEntry{}
+	for _, entry := range entries {
+		if entry.ID == *kid {
+			return fmt.Errorf("rotate-key: kid %q already exists", *kid)
+		}
+		if entry.ExpiresAt != nil && entry.ExpiresAt.Before(now) {
+			log.Printf("Dropping expired key %s", entry.ID)
+			continue
+		}
+		if entry.ExpiresAt == nil || entry.ExpiresAt.After(retireAt) {
+			entry.ExpiresAt = &retireAt
+		}
+		kept = append(kept, entry)
+	}
+
+	entry, err := auth.GenerateKeyFileEntry(*alg, *kid, notBefore)
+	if err != nil {
+		return fmt.Errorf("rotate-key: %w", err)
+	}
+	kept = append(kept, entry)
+
+	// make sure the api will accept the file before writing it
+	_, err = auth.NewKeyring(kept)
+	if err != nil {
+		return fmt.Errorf("rotate-key: %w", err)
+	}
+
+	err = auth.WriteKeyFile(*path, kept)
+	if err != nil {
+		return fmt.Errorf("rotate-key: %w", err)
+	}
+
+	log.Printf(
+		"Added %s key %s, signing from %s, previous keys retire at %s. Restart every instance before then.",
+		*alg,
+		*kid,
+		notBefore.Format(time.RFC3339),
+		retireAt.Format(time.RFC3339),
+	)
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage: kronosctl <command> [flags]
+
+commands:
+  migrate up|down [n]|status   apply, roll back or list schema migrations
+  seed                         insert demo data into a migrated database
+  bootstrap-admin              create the first owner profile, workspace and company
+  rotate-key                   add a new JWT signing key and retire the current ones
+  revoke-sessions              sign a profile out of every device
+
+run kronosctl <command> -h for the flags of a command`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+
+	switch command {
+	case "migrate":
+		err = runMigrate(args)
+	case "seed":
+		err = runSeed(args)
+	case "bootstrap-admin":
+		err = runBootstrapAdmin(args)
+	case "rotate-key":
+		err = runRotateKey(args)
+	case "revoke-sessions":
+		err = runRevokeSessions(args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func openDB() (*sql.DB, error) {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_CONNECTION_STRING"))
+	if err != nil {
+		return nil, fmt.Errorf("openDB: %w", err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("openDB: ping: %w", err)
+	}
+
+	return db, nil
+}
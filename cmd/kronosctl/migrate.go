@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	dbrepo "test/internal/db"
+)
+
+func runMigrate(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("migrate: expected up, down [n] or status")
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		count, err := dbrepo.Migrate(ctx, db)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migrations applied", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: n must be a positive integer")
+			}
+		}
+		count, err := dbrepo.Rollback(ctx, db, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migrations rolled back", count)
+
+	case "status":
+		states, err := dbrepo.Status(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d  %-40s  %s\n", state.Version, state.Name, applied)
+		}
+
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q", args[0])
+	}
+
+	return nil
+}
+
+func runSeed(args []string) error {
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = dbrepo.Seed(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	log.Println("Seed data inserted")
+	return nil
+}
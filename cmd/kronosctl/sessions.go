@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"test/internal/auth"
+)
+
+func runRevokeSessions(args []string) error {
+	flags := flag.NewFlagSet("revoke-sessions", flag.ExitOnError)
+	profileId := flags.Int("profile-id", 0, "id of the profile to sign out")
+	kt := flags.String("kt", "", "kennitala of the profile to sign out")
+	flags.Parse(args)
+
+	if (*profileId == 0) == (*kt == "") {
+		return fmt.Errorf("revoke-sessions: pass exactly one of -profile-id or -kt")
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if *kt != "" {
+		err = db.QueryRowContext(
+			ctx,
+			`
+			SELECT id FROM profile WHERE kt = $1
+			`,
+			*kt,
+		).Scan(
+			profileId,
+		)
+		if err != nil {
+			return fmt.Errorf("revoke-sessions: find profile: %w", err)
+		}
+	}
+
+	count, err := auth.RevokeProfileSessions(ctx, db, *profileId)
+	if err != nil {
+		return fmt.Errorf("revoke-sessions: %w", err)
+	}
+
+	log.Printf("Revoked %d sessions of profile %d", count, *profileId)
+	return nil
+}
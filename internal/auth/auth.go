@@ -2,21 +2,22 @@ package auth
 
 import (
 	"context"
-	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/subtle"
 	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
+	"sync"
 	"test/internal/model"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -31,8 +32,31 @@ func CreateProfile(
 	}
 	defer tx.Rollback()
 
+	profile, err := InsertProfile(ctx, tx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateProfile: db commit: %w", err)
+	}
+
+	return profile, nil
+}
+
+// InsertProfile adds the profile and its PIN and password logins in tx.
+func InsertProfile(
+	ctx context.Context,
+	tx *sql.Tx,
+	input ProfileCreate,
+) (*model.Profile, error) {
+	// the email is what the password logs in with
+	if input.Password != nil && (input.Email == nil || *input.Email == "") {
+		return nil, ErrEmailRequired
+	}
+
 	var profile model.Profile
-	err = tx.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		`
 		INSERT INTO profile (kt, first_name, last_name)
@@ -49,26 +73,26 @@ func CreateProfile(
 		&profile.LastName,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("CreateProfile: db insert: %w", err)
+		return nil, fmt.Errorf("InsertProfile: db insert: %w", err)
 	}
 
 	if input.Pin != nil {
+		err = validatePin(*input.Pin)
+		if err != nil {
+			return nil, err
+		}
 		err = addPinAuth(ctx, tx, profile.ID, *input.Pin)
 		if err != nil {
-			return nil, fmt.Errorf("CreateProfile: %w", err)
+			return nil, fmt.Errorf("InsertProfile: %w", err)
 		}
 	}
 	if input.Password != nil {
 		err = addPasswordAuth(ctx, tx, profile.ID, *input.Password, *input.Email)
 		if err != nil {
-			return nil, fmt.Errorf("CreateProfile: %w", err)
+			return nil, fmt.Errorf("InsertProfile: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("CreateProfile: db commit: %w", err)
-	}
-
 	return &profile, nil
 }
 
@@ -78,8 +102,12 @@ func addPinAuth(
 	profile_id int,
 	pin string,
 ) error {
-	pinHash := hashPin(pin, os.Getenv("PIN_HASH_SECRET"))
-	_, err := tx.ExecContext(
+	pinHash, err := hashPin(pin)
+	if err != nil {
+		return fmt.Errorf("addPinAuth: %w", err)
+	}
+
+	_, err = tx.ExecContext(
 		ctx,
 		`
 		INSERT INTO profile_pin_auth (profile_id, pin)
@@ -94,12 +122,6 @@ func addPinAuth(
 	return nil
 }
 
-func hashPin(pin string, key string) string {
-	h := hmac.New(sha256.New, []byte(key))
-	h.Write([]byte(pin))
-	return hex.EncodeToString(h.Sum(nil))
-}
-
 func addPasswordAuth(
 	ctx context.Context,
 	tx *sql.Tx,
@@ -145,10 +167,8 @@ func ColdStartPin(
 
 	var (
 		pinHash string
-		profile    model.Profile
-		employment model.Employment
-		company model.Company
-		workspace model.Workspace
+		mustChangePin bool
+		profile model.Profile
 	)
 
 	err = tx.QueryRowContext(
@@ -156,15 +176,9 @@ func ColdStartPin(
 		`
 		SELECT
 			u.id, u.kt, u.first_name, u.last_name,
-			p.pin,
-			e.id, e.profile_id, e.company_id, e.contract_id, e.role, e.start_date, e.end_date,
-			c.id, c.workspace_id, c.name,
-			w.id, w.name
+			p.pin, p.must_change
 		FROM profile u
 		JOIN profile_pin_auth p ON p.profile_id = u.id
-		JOIN employment e ON u.id = e.profile_id
-        JOIN company c ON c.id = e.company_id
-		JOIN workspace w ON w.id = c.workspace_id
 		WHERE u.kt = $1
 		`,
 		input.KT,
@@ -174,53 +188,161 @@ func ColdStartPin(
 		&profile.FirstName,
 		&profile.LastName,
 		&pinHash,
-		&employment.Id,
-		&employment.ProfileId,
-		&employment.CompanyId,
-		&employment.ContractId,
-		&employment.Role,
-		&employment.StartDate,
-		&employment.EndDate,
-		&company.Id,
-		&company.WorkspaceId,
-		&company.Name,
-		&workspace.Id,
-		&workspace.Name,
+		&mustChangePin,
 	)
+	// guesses at unknown kts are counted too, against the kt
 	if err == sql.ErrNoRows {
+		if err := countAttempt(ctx, db, attemptScopeKT, input.KT); err != nil {
+			return nil, err
+		}
 		return nil, ErrProfileNotFound
 	}; if err != nil {
 		return nil, fmt.Errorf("AuthenticateProfile: query profile: %w", err)
 	}
 
-	inputPinHash := hashPin(input.Pin, os.Getenv("PIN_HASH_SECRET"))
-	if subtle.ConstantTimeCompare(
-		[]byte(pinHash),
-		[]byte(inputPinHash),
-	) != 1 {
+	err = countPinAttempt(ctx, db, profile.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, needsRehash, err := verifyPin(input.Pin, pinHash)
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticateProfile: %w", err)
+	}
+	if !ok {
 		return nil, ErrInvalidCredentials
 	}
 
-	accessToken, refreshToken, err := rotateTokens(ctx, tx, profile.ID, deviceId)
+	if needsRehash {
+		err = rehashPin(ctx, tx, profile.ID, input.Pin)
+		if err != nil {
+			return nil, fmt.Errorf("AuthenticateProfile: %w", err)
+		}
+	}
+
+	err = clearPinAttempts(ctx, tx, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticateProfile: %w", err)
+	}
+
+	employments, err := getActiveEmployments(ctx, tx, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticateProfile: %w", err)
+	}
+	selected, err := selectEmployment(employments, input.EmploymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := rotateTokens(ctx, db, tx, profile.ID, deviceId, AuthStagePin, selected.Scope(), mustChangePin, nil)
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticateProfile: %w", err)
+	}
 
-	profileExtended := ProfileExtended{
-		Profile: profile,
-		Employment: employment,
-		Company: company,
-		Workspace: workspace,
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("AuthenticateProfile: db commit: %w", err)
 	}
 
 	response := AuthResponse{
 		Message: "Login successful",
-		ProfileExtended: profileExtended,
+		PinChangeRequired: mustChangePin,
+		ProfileExtended: newProfileExtended(profile, employments, selected),
 		Tokens: Tokens{
 			AccessToken: *accessToken,
 			RefreshToken: *refreshToken,
 		},
 	}
-	println(response.Message)
 
-	tx.Commit()
+	return &response, nil
+}
+
+// dummyPasswordHash is compared against when no profile has the email, at the
+// same cost as real password hashes.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("dummyPasswordHash: %v", err))
+	}
+	return hash
+})
+
+func PasswordLogin(
+	ctx context.Context,
+	db *sql.DB,
+	input ProfilePasswordAuth,
+) (*AuthResponse, error) {
+	deviceId := GetDeviceID(ctx)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PasswordLogin: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		passwordHash string
+		profile model.Profile
+	)
+
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT
+			u.id, u.kt, u.first_name, u.last_name,
+			p.password
+		FROM profile u
+		JOIN profile_password_auth p ON p.profile_id = u.id
+		WHERE lower(p.email) = lower($1)
+		`,
+		input.Email,
+	).Scan(
+		&profile.ID,
+		&profile.KT,
+		&profile.FirstName,
+		&profile.LastName,
+		&passwordHash,
+	)
+	// an unknown email is reported the same way as a wrong password so the
+	// endpoint can't be used to probe which emails are registered, and still
+	// pays for a bcrypt comparison so it can't be told apart by timing either
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(input.Password))
+		return nil, ErrInvalidCredentials
+	}; if err != nil {
+		return nil, fmt.Errorf("PasswordLogin: query profile: %w", err)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(input.Password))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	employments, err := getActiveEmployments(ctx, tx, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("PasswordLogin: %w", err)
+	}
+	selected, err := selectEmployment(employments, input.EmploymentId)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := rotateTokens(ctx, db, tx, profile.ID, deviceId, AuthStagePassword, selected.Scope(), false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PasswordLogin: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PasswordLogin: db commit: %w", err)
+	}
+
+	response := AuthResponse{
+		Message: "Login successful",
+		ProfileExtended: newProfileExtended(profile, employments, selected),
+		Tokens: Tokens{
+			AccessToken: *accessToken,
+			RefreshToken: *refreshToken,
+		},
+	}
 
 	return &response, nil
 }
@@ -229,6 +351,26 @@ func RefreshTokens(
 	ctx context.Context,
 	db *sql.DB,
 	input ProfileSilentRefresh,
+) (*AuthResponse, error) {
+	return refreshSession(ctx, db, input.RefreshToken, nil, "Silent refresh successful")
+}
+
+// SwitchEmployment moves an existing session to another of the profile's
+// active employments without asking for the PIN again.
+func SwitchEmployment(
+	ctx context.Context,
+	db *sql.DB,
+	input EmploymentSwitch,
+) (*AuthResponse, error) {
+	return refreshSession(ctx, db, input.RefreshToken, &input.EmploymentId, "Employment switched")
+}
+
+func refreshSession(
+	ctx context.Context,
+	db *sql.DB,
+	refreshToken string,
+	employmentId *int,
+	message string,
 ) (*AuthResponse, error) {
 	deviceId := GetDeviceID(ctx)
 
@@ -238,80 +380,83 @@ func RefreshTokens(
 	}
 	defer tx.Rollback()
 
-	hash := hashToken(input.RefreshToken)
+	token, err := getRefreshToken(ctx, db, tx, refreshToken, deviceId)
+	if err != nil {
+		return nil, err
+	}
 
 	var (
-	    profile_id int
-	    token_id int
+		mustChangePin bool
 		profile model.Profile
-		employment model.Employment
-		company model.Company
-		workspace model.Workspace
 	)
 	err = tx.QueryRowContext(
 		ctx,
 		`
-		SELECT r.id, r.profile_id,
-			p.id, p.kt, p.first_name, p.last_name,
-			e.id, e.profile_id, e.company_id, e.contract_id, e.role, e.start_date, e.end_date,
-			c.id, c.workspace_id, c.name,
-			w.id, w.name
-		FROM refresh_token r
-		JOIN profile p ON p.id = r.profile_id
-		JOIN employment e ON p.id = e.profile_id
-        JOIN company c ON c.id = e.company_id
-		JOIN workspace w ON w.id = c.workspace_id
-		WHERE r.token_hash = $1 AND r.device_id = $2
+		SELECT p.id, p.kt, p.first_name, p.last_name,
+			COALESCE(pa.must_change, false)
+		FROM profile p
+		LEFT JOIN profile_pin_auth pa ON pa.profile_id = p.id
+		WHERE p.id = $1
 		`,
-		hash,
-		deviceId,
+		token.ProfileId,
 	).Scan(
-		&token_id,
-		&profile_id,
 		&profile.ID,
 		&profile.KT,
 		&profile.FirstName,
 		&profile.LastName,
-		&employment.Id,
-		&employment.ProfileId,
-		&employment.CompanyId,
-		&employment.ContractId,
-		&employment.Role,
-		&employment.StartDate,
-		&employment.EndDate,
-		&company.Id,
-		&company.WorkspaceId,
-		&company.Name,
-		&workspace.Id,
-		&workspace.Name,
+		&mustChangePin,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("RefreshTokens: db select: %w", err)
 	}
 
-	access, refresh, err := rotateTokens(ctx, tx, profile_id, deviceId)
+	employments, err := getActiveEmployments(ctx, tx, profile.ID)
 	if err != nil {
 		return nil, fmt.Errorf("RefreshTokens: %w", err)
 	}
 
-	tx.Commit()
+	var selected *EmploymentDetail
+	if employmentId != nil {
+		selected, err = selectEmployment(employments, employmentId)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// keep the employment the session already had, unless it has ended
+		// in the meantime
+		selected, err = selectEmployment(employments, token.EmploymentId)
+		if errors.Is(err, ErrEmploymentNotFound) {
+			selected, err = selectEmployment(employments, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// password sessions never authenticated with the PIN in the first place
+	if token.Auth != AuthStagePin {
+		mustChangePin = false
+	}
+
+	access, refresh, err := rotateTokens(ctx, db, tx, profile.ID, deviceId, token.Auth, selected.Scope(), mustChangePin, token)
+	if err != nil {
+		return nil, fmt.Errorf("RefreshTokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("RefreshTokens: db commit: %w", err)
+	}
 
 	tokens := Tokens{
 		AccessToken: *access,
 		RefreshToken: *refresh,
 	}
 
-	profileExtended := ProfileExtended{
-		Profile: profile,
-		Employment: employment,
-		Company: company,
-		Workspace: workspace,
-	}
-
 	response := AuthResponse{
-		Message: "Silent refresh successful",
+		Message: message,
+		PinChangeRequired: mustChangePin,
 		Tokens: tokens,
-		ProfileExtended: profileExtended,
+		ProfileExtended: newProfileExtended(profile, employments, selected),
 	}
 
 	return &response, nil
@@ -330,135 +475,206 @@ func WarmStartPin(
 	}
 	defer tx.Rollback()
 
-	hashedToken := hashToken(input.RefreshToken)
+	token, err := getRefreshToken(ctx, db, tx, input.RefreshToken, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = countSessionPinAttempt(ctx, db, token.ProfileId, token.FamilyId)
+	if err != nil {
+		return nil, err
+	}
 
 	var (
-		profile_id int
 		pinHash string
-		token_id int
+		mustChangePin bool
 		profile model.Profile
-		employment model.Employment
-		company model.Company
-		workspace model.Workspace
 	)
 
 	err = tx.QueryRowContext(
 		ctx,
 		`
-		SELECT r.id, u.id, p.pin,
-			u.id, u.kt, u.first_name, u.last_name, 
-			e.id, e.profile_id, e.company_id, e.contract_id, e.role, e.start_date, e.end_date,
-			c.id, c.workspace_id, c.name,
-			w.id, w.name
-        FROM profile u
-        JOIN refresh_token r ON r.profile_id = u.id
-        JOIN profile_pin_auth p ON p.profile_id = u.id
-        JOIN employment e ON e.profile_id = u.id
-        JOIN company c ON c.id = e.company_id
-		JOIN workspace w ON w.id = c.workspace_id
-        WHERE r.token_hash = $1 AND r.device_id = $2
+		SELECT p.pin, p.must_change,
+			u.id, u.kt, u.first_name, u.last_name
+		FROM profile u
+		JOIN profile_pin_auth p ON p.profile_id = u.id
+		WHERE u.id = $1
 		`,
-		hashedToken,
-		deviceId,
+		token.ProfileId,
 	).Scan(
-		&token_id,
-		&profile_id,
 		&pinHash,
+		&mustChangePin,
 		&profile.ID,
 		&profile.KT,
 		&profile.FirstName,
 		&profile.LastName,
-		&employment.Id,
-		&employment.ProfileId,
-		&employment.CompanyId,
-		&employment.ContractId,
-		&employment.Role,
-		&employment.StartDate,
-		&employment.EndDate,
-		&company.Id,
-		&company.WorkspaceId,
-		&company.Name,
-		&workspace.Id,
-		&workspace.Name,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("WarmStartPin: db select: %w", err)
 	}
 
-	inputPinHash := hashPin(input.Pin, os.Getenv("PIN_HASH_SECRET"))
-	if subtle.ConstantTimeCompare(
-		[]byte(pinHash),
-		[]byte(inputPinHash),
-	) != 1 {
+	ok, needsRehash, err := verifyPin(input.Pin, pinHash)
+	if err != nil {
+		return nil, fmt.Errorf("WarmStartPin: %w", err)
+	}
+	if !ok {
 		return nil, ErrInvalidCredentials
 	}
 
-	accessToken, refreshToken, err := rotateTokens(ctx, tx, profile_id, deviceId)
+	if needsRehash {
+		err = rehashPin(ctx, tx, profile.ID, input.Pin)
+		if err != nil {
+			return nil, fmt.Errorf("WarmStartPin: %w", err)
+		}
+	}
+
+	err = clearSessionPinAttempts(ctx, tx, profile.ID, token.FamilyId)
+	if err != nil {
+		return nil, fmt.Errorf("WarmStartPin: %w", err)
+	}
+
+	employments, err := getActiveEmployments(ctx, tx, profile.ID)
+	if err != nil {
+		return nil, fmt.Errorf("WarmStartPin: %w", err)
+	}
+
+	requested := input.EmploymentId
+	if requested == nil {
+		requested = token.EmploymentId
+	}
+	selected, err := selectEmployment(employments, requested)
+	if input.EmploymentId == nil && errors.Is(err, ErrEmploymentNotFound) {
+		selected, err = selectEmployment(employments, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := rotateTokens(ctx, db, tx, profile.ID, deviceId, AuthStagePin, selected.Scope(), mustChangePin, token)
 	if err != nil {
 		return nil, fmt.Errorf("WarmStartPin: %w", err)
 	}
 
-	tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("WarmStartPin: db commit: %w", err)
+	}
 
 	tokens := Tokens{
 		AccessToken: *accessToken,
 		RefreshToken: *refreshToken,
 	}
 
-	profileExtended := ProfileExtended{
-		Profile: profile,
-		Employment: employment,
-		Company: company,
-		Workspace: workspace,
-	}
-
 	response := AuthResponse{
 		Message: "Authentication successful",
+		PinChangeRequired: mustChangePin,
 		Tokens: tokens,
-		ProfileExtended: profileExtended,
+		ProfileExtended: newProfileExtended(profile, employments, selected),
 	}
 
 	return &response, nil
 }
 
+// rotateTokens issues a fresh access and refresh token pair. A login passes
+// a nil previous token and starts a new token family for the device, while a
+// refresh marks previous as rotated and continues its family.
 func rotateTokens(
 	ctx context.Context,
+	db *sql.DB,
 	tx *sql.Tx,
 	profile_id int,
 	device_id string,
+	auth string,
+	scope EmploymentScope,
+	pinChangeRequired bool,
+	previous *refreshTokenRecord,
 ) (*AccessToken, *RefreshToken, error) {
-	_, err := tx.ExecContext(
-		ctx,
-		`
-		DELETE FROM refresh_token WHERE profile_id = $1 AND device_id = $2
-		`,
-		profile_id,
-		device_id,
+	var (
+		familyId string
+		parentId *int
 	)
+
+	if previous == nil {
+		_, err := tx.ExecContext(
+			ctx,
+			`
+			DELETE FROM refresh_token WHERE profile_id = $1 AND device_id = $2
+			`,
+			profile_id,
+			device_id,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rotateTokens: %w", err)
+		}
+
+		familyId, err = generateRefreshToken()
+		if err != nil {
+			return nil, nil, fmt.Errorf("rotateTokens: %w", err)
+		}
+	} else {
+		result, err := tx.ExecContext(
+			ctx,
+			`
+			UPDATE refresh_token
+			SET rotated_at = now()
+			WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+			`,
+			previous.Id,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rotateTokens: %w", err)
+		}
+
+		// another request rotated the same token between our read and
+		// update, which is a reuse like any other
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return nil, nil, fmt.Errorf("rotateTokens: rows affected: %w", err)
+		}
+		if rows != 1 {
+			err = revokeTokenFamily(ctx, db, *previous, device_id)
+			if err != nil {
+				return nil, nil, fmt.Errorf("rotateTokens: %w", err)
+			}
+			return nil, nil, ErrTokenReused
+		}
+
+		familyId = previous.FamilyId
+		parentId = &previous.Id
+	}
+
+	access, err := createAccessToken(profile_id, auth, scope, pinChangeRequired)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rotateTokens: %w", err)
+	}
+	refresh, err := createRefreshToken(ctx, tx, profile_id, device_id, auth, scope, familyId, parentId)
 	if err != nil {
 		return nil, nil, fmt.Errorf("rotateTokens: %w", err)
 	}
-
-	access, _ := createAccessToken(profile_id, "pin")
-	refresh, _ := createRefreshToken(ctx, tx, profile_id, device_id)
 	return access, refresh, nil
 }
 
 func createAccessToken(
 	profile_id int,
 	auth string,
+	scope EmploymentScope,
+	pinChangeRequired bool,
 ) (*AccessToken, error) {
 	expiresAt := time.Now().Add(time.Minute * 5)
 	claims := Claims{
 		ProfileID: profile_id,
 		Auth: auth,
+		EmploymentScope: scope,
+		PinChangeRequired: pinChangeRequired,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	keys, err := CurrentKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("createAccessToken: %w", err)
+	}
 
-	accessTokenString, err := accessToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	accessTokenString, err := keys.Sign(claims)
 	if err != nil {
 		return nil, fmt.Errorf("createAccessToken: sign jwt: %w", err)
 	}
@@ -471,6 +687,10 @@ func createRefreshToken(
 	tx *sql.Tx,
 	profile_id int,
 	device_id string,
+	auth string,
+	scope EmploymentScope,
+	family_id string,
+	parent_id *int,
 ) (*RefreshToken, error) {
 	token, err := generateRefreshToken()
 	if err != nil {
@@ -479,20 +699,25 @@ func createRefreshToken(
 
 	tokenHash := hashToken(token)
 
+	var employmentId *int
+	if scope.EmploymentID != 0 {
+		employmentId = &scope.EmploymentID
+	}
+
 	_, err = tx.ExecContext(
 		ctx,
 		`
-		INSERT INTO refresh_token (profile_id, device_id, token_hash, expires_at)
-		VALUES ($1, $2, $3, now() + interval '90 days')
-		ON CONFLICT (profile_id, device_id)
-		DO UPDATE SET
-			token_hash = EXCLUDED.token_hash,
-			expires_at = EXCLUDED.expires_at,
-			created_at = now()
+		INSERT INTO refresh_token (profile_id, device_id, token_hash, auth, employment_id, family_id, parent_id, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now() + interval '90 days')
 		`,
 		profile_id,
 		device_id,
 		tokenHash,
+		auth,
+		employmentId,
+		family_id,
+		parent_id,
+		GetUserAgent(ctx),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("createRefreshToken: db insert: %w", err)
@@ -516,7 +741,22 @@ func hashToken(token string) string {
 	return hex.EncodeToString(sum[:])
 }
 
-func PinAuthMiddleware(secret []byte) func(http.Handler) http.Handler {
+func PinAuthMiddleware(keys *Keyring) func(http.Handler) http.Handler {
+	return stageAuthMiddleware(keys, AuthStagePin)
+}
+
+func PasswordAuthMiddleware(keys *Keyring) func(http.Handler) http.Handler {
+	return stageAuthMiddleware(keys, AuthStagePassword)
+}
+
+// SessionAuthMiddleware accepts an access token of any auth stage, for
+// endpoints that deal with the session itself rather than pin or manage
+// functionality.
+func SessionAuthMiddleware(keys *Keyring) func(http.Handler) http.Handler {
+	return stageAuthMiddleware(keys, AuthStagePin, AuthStagePassword)
+}
+
+func stageAuthMiddleware(keys *Keyring, stages ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
@@ -534,22 +774,19 @@ func PinAuthMiddleware(secret []byte) func(http.Handler) http.Handler {
 			tokenStr := parts[1]
 
 			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (any, error) {
-				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method")
-				}
-				return secret, nil
-			})
+			token, err := jwt.ParseWithClaims(
+				tokenStr,
+				claims,
+				keys.Keyfunc,
+				jwt.WithValidMethods(keys.Methods()),
+			)
 
 			if err != nil || !token.Valid {
-				print(err)
-				print(token.Valid)
-				print(token)
 				http.Error(w, "invalid token", http.StatusUnauthorized)
 				return
 			}
 
-			if claims.Auth != "pin" {
+			if !slices.Contains(stages, claims.Auth) {
 				http.Error(w, "invalid auth stage", http.StatusForbidden)
 				return
 			}
@@ -561,6 +798,75 @@ func PinAuthMiddleware(secret []byte) func(http.Handler) http.Handler {
 	}
 }
 
+// RequirePinChangedMiddleware must run after PinAuthMiddleware. It turns
+// away sessions that logged in with a temporary PIN until it is changed.
+func RequirePinChangedMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				http.Error(w, "missing claims", http.StatusUnauthorized)
+				return
+			}
+
+			if claims.PinChangeRequired {
+				http.Error(w, ErrPinChangeRequired.Error(), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRoleMiddleware must run after an auth middleware. It lets the
+// request through only if the profile holds one of roles in a currently
+// active employment.
+func RequireRoleMiddleware(db *sql.DB, roles ...model.Role) func(http.Handler) http.Handler {
+	allowed := make([]string, len(roles))
+	for i, role := range roles {
+		allowed[i] = string(role)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				http.Error(w, "missing claims", http.StatusUnauthorized)
+				return
+			}
+
+			var hasRole bool
+			err := db.QueryRowContext(
+				r.Context(),
+				`
+				SELECT EXISTS (
+					SELECT 1
+					FROM employment
+					WHERE profile_id = $1
+					AND role = ANY($2)
+					AND (start_date IS NULL OR start_date <= now())
+					AND (end_date IS NULL OR end_date > now())
+				)
+				`,
+				claims.ProfileID,
+				pq.Array(allowed),
+			).Scan(&hasRole)
+			if err != nil {
+				WriteDomainError(w, fmt.Errorf("RequireRoleMiddleware: db select: %w", err))
+				return
+			}
+
+			if !hasRole {
+				http.Error(w, "insufficient role", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func DeviceIdMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -572,6 +878,7 @@ func DeviceIdMiddleware() func(http.Handler) http.Handler {
 
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, DeviceIdKey, deviceId)
+			ctx = context.WithValue(ctx, UserAgentKey, r.UserAgent())
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -585,3 +892,10 @@ func GetDeviceID(ctx context.Context) string {
 	println("Failed to get device id")
     return ""
 }
+
+func GetUserAgent(ctx context.Context) string {
+	if val, ok := ctx.Value(UserAgentKey).(string); ok {
+		return val
+	}
+	return ""
+}
@@ -6,6 +6,7 @@ type contextKey string
 
 const ClaimsKey contextKey = "claims"
 const DeviceIdKey contextKey = "deviceID"
+const UserAgentKey contextKey = "userAgent"
 
 func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
 	claims, ok := ctx.Value(ClaimsKey).(*Claims)
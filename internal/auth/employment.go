@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"test/internal/model"
+)
+
+func getActiveEmployments(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+) ([]EmploymentDetail, error) {
+	employments := []EmploymentDetail{}
+	rows, err := tx.QueryContext(
+		ctx,
+		`
+		SELECT
+			e.id, e.profile_id, e.company_id, e.contract_id, e.role, e.start_date, e.end_date,
+			c.id, c.name,
+			w.id, w.name
+		FROM employment e
+		LEFT JOIN company c ON c.id = e.company_id
+		JOIN workspace w ON w.id = COALESCE(c.workspace_id, e.workspace_id)
+		WHERE e.profile_id = $1
+		AND (e.start_date IS NULL OR e.start_date <= now())
+		AND (e.end_date IS NULL OR e.end_date > now())
+		ORDER BY e.start_date, e.id
+		`,
+		profile_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getActiveEmployments: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			detail EmploymentDetail
+			company_id *int
+			company_name *string
+		)
+		err = rows.Scan(
+			&detail.Employment.Id,
+			&detail.Employment.ProfileId,
+			&detail.Employment.CompanyId,
+			&detail.Employment.ContractId,
+			&detail.Employment.Role,
+			&detail.Employment.StartDate,
+			&detail.Employment.EndDate,
+			&company_id,
+			&company_name,
+			&detail.Workspace.Id,
+			&detail.Workspace.Name,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getActiveEmployments: db scan: %w", err)
+		}
+
+		// owners of a workspace can be employed at no company in particular
+		if company_id != nil {
+			detail.Company = &model.Company{
+				Id: *company_id,
+				WorkspaceId: &detail.Workspace.Id,
+				Name: *company_name,
+			}
+		}
+
+		employments = append(employments, detail)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getActiveEmployments: rows: %w", err)
+	}
+
+	if len(employments) == 0 {
+		return nil, ErrNoActiveEmployment
+	}
+
+	return employments, nil
+}
+
+// selectEmployment picks the requested employment out of the active ones.
+// Without a request the only employment is picked, and with several to
+// choose from nil is returned so the client has to pick one itself.
+func selectEmployment(
+	employments []EmploymentDetail,
+	employmentId *int,
+) (*EmploymentDetail, error) {
+	if employmentId != nil {
+		for i := range employments {
+			if employments[i].Employment.Id == *employmentId {
+				return &employments[i], nil
+			}
+		}
+		return nil, ErrEmploymentNotFound
+	}
+
+	if len(employments) == 1 {
+		return &employments[0], nil
+	}
+	return nil, nil
+}
+
+// Scope returns the claims scope for the employment, empty when d is nil.
+func (d *EmploymentDetail) Scope() EmploymentScope {
+	if d == nil {
+		return EmploymentScope{}
+	}
+	scope := EmploymentScope{
+		EmploymentID: d.Employment.Id,
+		WorkspaceID: d.Workspace.Id,
+	}
+	if d.Company != nil {
+		scope.CompanyID = d.Company.Id
+	}
+	return scope
+}
+
+func newProfileExtended(
+	profile model.Profile,
+	employments []EmploymentDetail,
+	selected *EmploymentDetail,
+) ProfileExtended {
+	profileExtended := ProfileExtended{
+		Profile: profile,
+		Employments: employments,
+	}
+	if selected != nil {
+		profileExtended.Employment = &selected.Employment
+		profileExtended.Company = selected.Company
+		profileExtended.Workspace = &selected.Workspace
+	}
+	return profileExtended
+}
+
+// CompanyScope returns the company the session is scoped to, or nil when no
+// employment has been selected.
+func (c *Claims) CompanyScope() *int {
+	if c.CompanyID == 0 {
+		return nil
+	}
+	return &c.CompanyID
+}
+
+// WorkspaceScope returns the workspace the session is scoped to, or nil when
+// no employment has been selected.
+func (c *Claims) WorkspaceScope() *int {
+	if c.WorkspaceID == 0 {
+		return nil
+	}
+	return &c.WorkspaceID
+}
+
+// SelectedEmployment returns the employment the session is scoped to, or nil
+// when none has been selected.
+func (c *Claims) SelectedEmployment() *int {
+	if c.EmploymentID == 0 {
+		return nil
+	}
+	return &c.EmploymentID
+}
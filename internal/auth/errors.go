@@ -3,20 +3,66 @@ package auth
 import (
 	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrProfileNotFound    = errors.New("profile not found")
+	ErrNoActiveEmployment = errors.New("no active employment")
+	ErrEmploymentNotFound = errors.New("employment not found")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrTokenReused        = errors.New("refresh token reused, session revoked")
+	ErrTooManyAttempts    = errors.New("too many attempts")
+	ErrInvalidPin         = errors.New("pin must be 4 to 6 digits")
+	ErrPinChangeRequired  = errors.New("pin change required")
+	ErrEmailRequired      = errors.New("email is required to log in with a password")
 )
 
+// RetryAfterError tells the client how long to wait before trying again.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
 func WriteDomainError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, ErrInvalidCredentials):
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 	case errors.Is(err, ErrProfileNotFound):
 		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNoActiveEmployment):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrEmploymentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidRefreshToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrTokenReused):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrInvalidPin):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrEmailRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrPinChangeRequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrTooManyAttempts):
+		var retryErr *RetryAfterError
+		if errors.As(err, &retryErr) {
+			seconds := int(math.Ceil(retryErr.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
 	default:
 		log.Printf("internal error: %+v", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// A Keyring holds every key access tokens may be signed or verified with.
+// Tokens carry the id of their key in the kid header. To rotate, a new key is
+// added with a not_before in the future, and the old one is given an
+// expires_at some time after that, so for a while both verify and every
+// instance has picked up the new key before it starts signing with it.
+//
+// Keys are read from the JSON file in JWT_KEYS_FILE. Without one the keyring
+// holds a single HS256 key made from JWT_SECRET under the id "default".
+type Keyring struct {
+	keys []*SigningKey
+}
+
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	NotBefore time.Time
+	ExpiresAt time.Time
+
+	// signKey is nil for keys that are only kept around for verification
+	signKey   any
+	verifyKey any
+}
+
+// KeyFileEntry is one key in JWT_KEYS_FILE. HS256 keys give a base64
+// encoded secret, EdDSA and ES256 keys a PKCS#8 PEM private key, or only a
+// PKIX PEM public key when they are retired and kept for verification.
+type KeyFileEntry struct {
+	ID         string     `json:"kid"`
+	Alg        string     `json:"alg"`
+	Secret     string     `json:"secret,omitempty"`
+	PrivateKey string     `json:"private_key,omitempty"`
+	PublicKey  string     `json:"public_key,omitempty"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+const defaultKeyId = "default"
+
+var (
+	keyringOnce sync.Once
+	keyring     *Keyring
+	keyringErr  error
+)
+
+// CurrentKeyring returns the process wide keyring, loading it from the
+// environment on first use.
+func CurrentKeyring() (*Keyring, error) {
+	keyringOnce.Do(func() {
+		keyring, keyringErr = LoadKeyring()
+	})
+	return keyring, keyringErr
+}
+
+func LoadKeyring() (*Keyring, error) {
+	path := os.Getenv("JWT_KEYS_FILE")
+	if path == "" {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, fmt.Errorf("LoadKeyring: neither JWT_KEYS_FILE nor JWT_SECRET is set")
+		}
+		return &Keyring{keys: []*SigningKey{{
+			ID: defaultKeyId,
+			Method: jwt.SigningMethodHS256,
+			signKey: []byte(secret),
+			verifyKey: []byte(secret),
+		}}}, nil
+	}
+
+	entries, err := ReadKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadKeyring: %w", err)
+	}
+	return NewKeyring(entries)
+}
+
+// GenerateKeyFileEntry creates a new key for JWT_KEYS_FILE that becomes
+// valid for signing at notBefore.
+func GenerateKeyFileEntry(alg string, kid string, notBefore time.Time) (KeyFileEntry, error) {
+	entry := KeyFileEntry{
+		ID: kid,
+		Alg: alg,
+		NotBefore: &notBefore,
+	}
+
+	var private any
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return entry, fmt.Errorf("GenerateKeyFileEntry: rand: %w", err)
+		}
+		entry.Secret = base64.StdEncoding.EncodeToString(secret)
+		return entry, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return entry, fmt.Errorf("GenerateKeyFileEntry: generate: %w", err)
+		}
+		private = key
+	case jwt.SigningMethodES256.Alg():
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return entry, fmt.Errorf("GenerateKeyFileEntry: generate: %w", err)
+		}
+		private = key
+	default:
+		return entry, fmt.Errorf("GenerateKeyFileEntry: unsupported alg %q", alg)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return entry, fmt.Errorf("GenerateKeyFileEntry: marshal: %w", err)
+	}
+	entry.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	return entry, nil
+}
+
+func WriteKeyFile(path string, entries []KeyFileEntry) error {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("WriteKeyFile: encode: %w", err)
+	}
+
+	err = os.WriteFile(path, append(b, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("WriteKeyFile: %w", err)
+	}
+	return nil
+}
+
+func ReadKeyFile(path string) ([]KeyFileEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadKeyFile: %w", err)
+	}
+
+	var entries []KeyFileEntry
+	err = json.Unmarshal(b, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("ReadKeyFile: decode: %w", err)
+	}
+	return entries, nil
+}
+
+func NewKeyring(entries []KeyFileEntry) (*Keyring, error) {
+	k := &Keyring{}
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		if entry.ID == "" {
+			return nil, fmt.Errorf("NewKeyring: key without kid")
+		}
+		if seen[entry.ID] {
+			return nil, fmt.Errorf("NewKeyring: duplicate kid %q", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		key, err := parseKeyFileEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("NewKeyring: kid %q: %w", entry.ID, err)
+		}
+		k.keys = append(k.keys, key)
+	}
+
+	if len(k.keys) == 0 {
+		return nil, fmt.Errorf("NewKeyring: no keys")
+	}
+	return k, nil
+}
+
+func parseKeyFileEntry(entry KeyFileEntry) (*SigningKey, error) {
+	key := &SigningKey{ID: entry.ID}
+	if entry.NotBefore != nil {
+		key.NotBefore = *entry.NotBefore
+	}
+	if entry.ExpiresAt != nil {
+		key.ExpiresAt = *entry.ExpiresAt
+	}
+
+	switch entry.Alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret, err := base64.StdEncoding.DecodeString(entry.Secret)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("HS256 key needs a base64 secret")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey = secret
+		key.verifyKey = secret
+		return key, nil
+
+	case jwt.SigningMethodEdDSA.Alg():
+		key.Method = jwt.SigningMethodEdDSA
+	case jwt.SigningMethodES256.Alg():
+		key.Method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", entry.Alg)
+	}
+
+	if entry.PrivateKey != "" {
+		private, err := parsePEM(entry.PrivateKey, x509.ParsePKCS8PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("private key: %w", err)
+		}
+
+		switch private := private.(type) {
+		case ed25519.PrivateKey:
+			key.signKey = private
+			key.verifyKey = private.Public()
+		case *ecdsa.PrivateKey:
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		}
+	} else if entry.PublicKey != "" {
+		public, err := parsePEM(entry.PublicKey, x509.ParsePKIXPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("public key: %w", err)
+		}
+		key.verifyKey = public
+	} else {
+		return nil, fmt.Errorf("%s key needs a private_key or public_key", entry.Alg)
+	}
+
+	switch verifyKey := key.verifyKey.(type) {
+	case ed25519.PublicKey:
+		if key.Method != jwt.SigningMethodEdDSA {
+			return nil, fmt.Errorf("ed25519 key used with %s", entry.Alg)
+		}
+	case *ecdsa.PublicKey:
+		if key.Method != jwt.SigningMethodES256 || verifyKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 needs a P-256 key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", verifyKey)
+	}
+
+	return key, nil
+}
+
+func parsePEM(data string, parse func([]byte) (any, error)) (any, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	return parse(block.Bytes)
+}
+
+func (key *SigningKey) validAt(t time.Time) bool {
+	if !key.NotBefore.IsZero() && t.Before(key.NotBefore) {
+		return false
+	}
+	if !key.ExpiresAt.IsZero() && !t.Before(key.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// signingKey picks the newest key that has private material and is valid at
+// t. Of keys that became valid at the same time the last one listed wins.
+func (k *Keyring) signingKey(t time.Time) (*SigningKey, error) {
+	var active *SigningKey
+	for _, key := range k.keys {
+		if key.signKey == nil || !key.validAt(t) {
+			continue
+		}
+		if active == nil || !key.NotBefore.Before(active.NotBefore) {
+			active = key
+		}
+	}
+	if active == nil {
+		return nil, fmt.Errorf("signingKey: no key valid for signing")
+	}
+	return active, nil
+}
+
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := k.signingKey(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("Sign: %w", err)
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", fmt.Errorf("Sign: %w", err)
+	}
+	return signed, nil
+}
+
+// Keyfunc resolves the verification key for a token by its kid. Tokens
+// without a kid, issued before keys had ids, are checked against the
+// default key.
+func (k *Keyring) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = defaultKeyId
+	}
+
+	now := time.Now()
+	for _, key := range k.keys {
+		if key.ID != kid {
+			continue
+		}
+		if !key.validAt(now) {
+			return nil, fmt.Errorf("key %q is not valid", kid)
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return key.verifyKey, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// Methods lists the algorithms of every key, for jwt.WithValidMethods.
+func (k *Keyring) Methods() []string {
+	methods := []string{}
+	for _, key := range k.keys {
+		methods = append(methods, key.Method.Alg())
+	}
+	return methods
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public half of every asymmetric key that is still
+// valid, or will become valid, so other services can verify access tokens.
+// HS256 keys are shared secrets and never published.
+func (k *Keyring) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	now := time.Now()
+
+	for _, key := range k.keys {
+		if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+			continue
+		}
+
+		jwk := JWK{
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			Kid: key.ID,
+		}
+
+		switch public := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *ecdsa.PublicKey:
+			b, err := public.ECDH()
+			if err != nil {
+				continue
+			}
+			// uncompressed point, 0x04 || X || Y
+			point := b.Bytes()
+			size := (len(point) - 1) / 2
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = base64.RawURLEncoding.EncodeToString(point[1 : 1+size])
+			jwk.Y = base64.RawURLEncoding.EncodeToString(point[1+size:])
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newEntry makes a key file entry with a fresh key.
+func newEntry(t *testing.T, alg string, kid string, notBefore time.Time) KeyFileEntry {
+	t.Helper()
+	entry := KeyFileEntry{ID: kid, Alg: alg, NotBefore: &notBefore}
+
+	var private any
+	var err error
+	switch alg {
+	case "HS256":
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		entry.Secret = base64.StdEncoding.EncodeToString(secret)
+		return entry
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("generate %s key: %v", alg, err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshal %s key: %v", alg, err)
+	}
+	entry.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	return entry
+}
+
+func parse(k *Keyring, signed string) (*jwt.Token, error) {
+	return jwt.Parse(signed, k.Keyfunc, jwt.WithValidMethods(k.Methods()))
+}
+
+func TestKeyringSignVerify(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	for _, alg := range []string{"HS256", "EdDSA", "ES256"} {
+		t.Run(alg, func(t *testing.T) {
+			k, err := NewKeyring([]KeyFileEntry{newEntry(t, alg, "k1", past)})
+			if err != nil {
+				t.Fatalf("NewKeyring: %v", err)
+			}
+
+			signed, err := k.Sign(jwt.RegisteredClaims{Subject: "1"})
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			token, err := parse(k, signed)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if token.Header["kid"] != "k1" {
+				t.Errorf("kid = %v, want k1", token.Header["kid"])
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	now := time.Now()
+	old := newEntry(t, "EdDSA", "old", now.Add(-48*time.Hour))
+	next := newEntry(t, "EdDSA", "next", now.Add(time.Hour))
+
+	before, err := NewKeyring([]KeyFileEntry{old})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	signed, err := before.Sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// the new key is listed but not valid yet, so the old one still signs
+	// and tokens it signed still verify
+	during, err := NewKeyring([]KeyFileEntry{old, next})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	key, err := during.signingKey(now)
+	if err != nil || key.ID != "old" {
+		t.Fatalf("signingKey = %v, %v, want old", key, err)
+	}
+	if _, err := parse(during, signed); err != nil {
+		t.Errorf("token of the old key: %v", err)
+	}
+
+	// once it is, it signs
+	key, err = during.signingKey(now.Add(2 * time.Hour))
+	if err != nil || key.ID != "next" {
+		t.Fatalf("signingKey = %v, %v, want next", key, err)
+	}
+
+	// and tokens of the old key stop verifying once it expires
+	expired := now.Add(-time.Minute)
+	old.ExpiresAt = &expired
+	after, err := NewKeyring([]KeyFileEntry{old, next})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if _, err := parse(after, signed); err == nil {
+		t.Error("token of an expired key verified")
+	}
+}
+
+func TestKeyringRejectsOtherKeys(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	ours, err := NewKeyring([]KeyFileEntry{newEntry(t, "EdDSA", "k1", past)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	theirs, err := NewKeyring([]KeyFileEntry{newEntry(t, "EdDSA", "k1", past)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	hs, err := NewKeyring([]KeyFileEntry{newEntry(t, "HS256", "k1", past)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		signer *Keyring
+	}{
+		{"same kid, other key", theirs},
+		{"same kid, other alg", hs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.signer.Sign(jwt.RegisteredClaims{Subject: "1"})
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if _, err := parse(ours, signed); err == nil {
+				t.Error("token verified")
+			}
+		})
+	}
+}
+
+func TestKeyFileRoundTrip(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	entries := []KeyFileEntry{
+		newEntry(t, "HS256", "a", past),
+		newEntry(t, "EdDSA", "b", past),
+		newEntry(t, "ES256", "c", past),
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	read, err := ReadKeyFile(path)
+	if err != nil {
+		t.Fatalf("ReadKeyFile: %v", err)
+	}
+
+	k, err := NewKeyring(read)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	// HS256 secrets are never published
+	jwks := k.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "b" || jwks.Keys[1].Kid != "c" {
+		t.Errorf("JWKS = %+v, want the keys b and c", jwks.Keys)
+	}
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	ed := newEntry(t, "EdDSA", "k1", past)
+
+	mismatched := ed
+	mismatched.Alg = "ES256"
+
+	tests := []struct {
+		name    string
+		entries []KeyFileEntry
+	}{
+		{"no keys", nil},
+		{"no kid", []KeyFileEntry{{Alg: "HS256", Secret: "c2VjcmV0"}}},
+		{"duplicate kid", []KeyFileEntry{ed, ed}},
+		{"unsupported alg", []KeyFileEntry{{ID: "k1", Alg: "RS256"}}},
+		{"key of another alg", []KeyFileEntry{mismatched}},
+		{"no key material", []KeyFileEntry{{ID: "k1", Alg: "EdDSA"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyring(tt.entries); err == nil {
+				t.Error("NewKeyring succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	attemptScopeProfile = "profile"
+	// logins with a kt no profile has
+	attemptScopeKT = "kt"
+	// PIN re-entry on a session, keyed on its refresh token family rather
+	// than the device id, which clients pick themselves
+	attemptScopeDevice = "device"
+
+	// failures allowed before any backoff kicks in
+	freeAttempts = 3
+	// backoff after the first failure past freeAttempts, doubled for each
+	// further failure up to maxLockout
+	baseLockout = 30 * time.Second
+	maxLockout  = time.Hour
+)
+
+// lockoutFor returns how long an attempt counter is locked after reaching
+// failed consecutive attempts.
+func lockoutFor(failed int) time.Duration {
+	if failed <= freeAttempts {
+		return 0
+	}
+
+	lockout := baseLockout
+	for i := freeAttempts + 1; i < failed; i++ {
+		lockout *= 2
+		if lockout >= maxLockout {
+			return maxLockout
+		}
+	}
+	return lockout
+}
+
+// countAttempt counts an attempt against the counter before it is checked,
+// and returns a RetryAfterError instead while the counter is locked. The
+// count is committed on its own, since login transactions are rolled back on
+// invalid credentials, and holding the row lock until then makes parallel
+// attempts wait for each other's lockout. A successful attempt clears the
+// counter again. A counter that has been quiet for a day starts over.
+func countAttempt(
+	ctx context.Context,
+	db *sql.DB,
+	scope string,
+	key string,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("countAttempt: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failed int
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO auth_attempt (scope, key, failed_count, last_failed)
+		VALUES ($1, $2, 1, now())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failed_count = CASE
+				WHEN auth_attempt.last_failed < now() - interval '1 day' THEN 1
+				ELSE auth_attempt.failed_count + 1
+			END,
+			last_failed = now()
+		WHERE auth_attempt.locked_until IS NULL OR auth_attempt.locked_until <= now()
+		RETURNING failed_count
+		`,
+		scope,
+		key,
+	).Scan(
+		&failed,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		var lockedUntil time.Time
+		err = tx.QueryRowContext(
+			ctx,
+			`
+			SELECT locked_until
+			FROM auth_attempt
+			WHERE scope = $1 AND key = $2
+			`,
+			scope,
+			key,
+		).Scan(
+			&lockedUntil,
+		)
+		if err != nil {
+			return fmt.Errorf("countAttempt: db select: %w", err)
+		}
+		return &RetryAfterError{
+			Err: ErrTooManyAttempts,
+			RetryAfter: time.Until(lockedUntil),
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("countAttempt: db upsert: %w", err)
+	}
+
+	lockout := lockoutFor(failed)
+	if lockout > 0 {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			UPDATE auth_attempt
+			SET locked_until = now() + $3 * interval '1 second'
+			WHERE scope = $1 AND key = $2
+			`,
+			scope,
+			key,
+			int(lockout.Seconds()),
+		)
+		if err != nil {
+			return fmt.Errorf("countAttempt: db update: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("countAttempt: db commit: %w", err)
+	}
+	return nil
+}
+
+func clearAttempts(
+	ctx context.Context,
+	tx *sql.Tx,
+	scope string,
+	key string,
+) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`
+		DELETE FROM auth_attempt WHERE scope = $1 AND key = $2
+		`,
+		scope,
+		key,
+	)
+	if err != nil {
+		return fmt.Errorf("clearAttempts: db delete: %w", err)
+	}
+	return nil
+}
+
+// countPinAttempt counts a PIN attempt against the profile, whichever device
+// it comes from.
+func countPinAttempt(
+	ctx context.Context,
+	db *sql.DB,
+	profile_id int,
+) error {
+	return countAttempt(ctx, db, attemptScopeProfile, strconv.Itoa(profile_id))
+}
+
+func clearPinAttempts(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+) error {
+	return clearAttempts(ctx, tx, attemptScopeProfile, strconv.Itoa(profile_id))
+}
+
+// countSessionPinAttempt counts a PIN attempt made to resume a session,
+// against the session as well as its profile. A login elsewhere clears the
+// profile's counter, but not the one of a session guessing at the PIN.
+func countSessionPinAttempt(
+	ctx context.Context,
+	db *sql.DB,
+	profile_id int,
+	family_id string,
+) error {
+	err := countAttempt(ctx, db, attemptScopeDevice, family_id)
+	if err != nil {
+		return err
+	}
+	return countPinAttempt(ctx, db, profile_id)
+}
+
+func clearSessionPinAttempts(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+	family_id string,
+) error {
+	err := clearAttempts(ctx, tx, attemptScopeDevice, family_id)
+	if err != nil {
+		return err
+	}
+	return clearPinAttempts(ctx, tx, profile_id)
+}
+
+// UnlockProfile lifts a PIN lockout on a profile the caller manages, and on
+// its sessions, ahead of time.
+func UnlockProfile(
+	ctx context.Context,
+	db *sql.DB,
+	profile_id int,
+) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("UnlockProfile: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = checkManagedProfile(ctx, tx, profile_id)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`
+		DELETE FROM auth_attempt
+		WHERE (scope = $1 AND key = $2)
+		OR (scope = $3 AND key IN (
+			SELECT family_id FROM refresh_token WHERE profile_id = $4
+		))
+		`,
+		attemptScopeProfile,
+		strconv.Itoa(profile_id),
+		attemptScopeDevice,
+		profile_id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("UnlockProfile: db delete: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("UnlockProfile: rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("UnlockProfile: db commit: %w", err)
+	}
+
+	return rows, nil
+}
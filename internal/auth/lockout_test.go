@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"test/internal/db/dbtest"
+	"testing"
+	"time"
+)
+
+func TestLockoutFor(t *testing.T) {
+	tests := []struct {
+		failed int
+		want   time.Duration
+	}{
+		{0, 0},
+		{freeAttempts, 0},
+		{freeAttempts + 1, baseLockout},
+		{freeAttempts + 2, 2 * baseLockout},
+		{freeAttempts + 3, 4 * baseLockout},
+		{freeAttempts + 100, maxLockout},
+	}
+
+	for _, tt := range tests {
+		if got := lockoutFor(tt.failed); got != tt.want {
+			t.Errorf("lockoutFor(%d) = %v, want %v", tt.failed, got, tt.want)
+		}
+	}
+}
+
+// createPinProfile adds a profile that logs in with pin.
+func createPinProfile(t *testing.T, db *sql.DB, kt string, pin string) int {
+	t.Helper()
+	profile, err := CreateProfile(context.Background(), db, ProfileCreate{
+		KT:        kt,
+		FirstName: "Jón",
+		LastName:  "Jónsson",
+		Pin:       &pin,
+	})
+	if err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+	return profile.ID
+}
+
+// pinLogin tries the PIN from the given device.
+func pinLogin(db *sql.DB, kt string, pin string, device string) error {
+	ctx := context.WithValue(context.Background(), DeviceIdKey, device)
+	_, err := ColdStartPin(ctx, db, ProfilePinAuth{KT: kt, Pin: pin})
+	return err
+}
+
+func TestPinLockoutAcrossDevices(t *testing.T) {
+	db := dbtest.Open(t)
+	createPinProfile(t, db, "0101302989", "1234")
+
+	// every guess comes from a new device, which must not reset the count
+	for i := range freeAttempts + 1 {
+		err := pinLogin(db, "0101302989", "0000", fmt.Sprintf("device-%d", i))
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("guess %d: got %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	err := pinLogin(db, "0101302989", "1234", "device-z")
+	var retryErr *RetryAfterError
+	if !errors.As(err, &retryErr) || retryErr.RetryAfter <= 0 {
+		t.Fatalf("got %v, want a RetryAfterError", err)
+	}
+}
+
+func TestPinLockoutParallel(t *testing.T) {
+	db := dbtest.Open(t)
+	profile := createPinProfile(t, db, "0101302989", "1234")
+
+	const guesses = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := countPinAttempt(context.Background(), db, profile)
+			if err != nil && !errors.Is(err, ErrTooManyAttempts) {
+				t.Errorf("guess %d: %v", i, err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != freeAttempts+1 {
+		t.Errorf("%d of %d parallel guesses were let through, want %d", allowed, guesses, freeAttempts+1)
+	}
+}
+
+func TestPinLockoutSession(t *testing.T) {
+	db := dbtest.Open(t)
+	useTestKeyring(t)
+	createPinProfile(t, db, "0101302989", "1234")
+
+	ctx := context.WithValue(context.Background(), DeviceIdKey, "device-a")
+	login, err := ColdStartPin(ctx, db, ProfilePinAuth{KT: "0101302989", Pin: "1234"})
+	if err != nil {
+		t.Fatalf("ColdStartPin: %v", err)
+	}
+	guess := func() error {
+		_, err := WarmStartPin(ctx, db, ProfileReAuth{Pin: "0000", RefreshToken: login.Tokens.RefreshToken.Token})
+		return err
+	}
+
+	for i := range freeAttempts {
+		if err := guess(); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("guess %d: got %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	// a login on another device clears the profile's count, not the session's
+	if err := pinLogin(db, "0101302989", "1234", "device-b"); err != nil {
+		t.Fatalf("login on another device: %v", err)
+	}
+
+	if err := guess(); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("guess %d: got %v, want ErrInvalidCredentials", freeAttempts+1, err)
+	}
+	err = guess()
+	var retryErr *RetryAfterError
+	if !errors.As(err, &retryErr) || retryErr.RetryAfter <= 0 {
+		t.Fatalf("got %v, want a RetryAfterError", err)
+	}
+}
+
+func TestPinLockoutUnknownKT(t *testing.T) {
+	db := dbtest.Open(t)
+
+	for i := range freeAttempts + 1 {
+		err := pinLogin(db, "9999999999", "0000", "device")
+		if !errors.Is(err, ErrProfileNotFound) {
+			t.Fatalf("guess %d: got %v, want ErrProfileNotFound", i+1, err)
+		}
+	}
+
+	err := pinLogin(db, "9999999999", "0000", "device")
+	if !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("got %v, want ErrTooManyAttempts", err)
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"test/internal/db/dbtest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDummyPasswordHashCost(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPasswordHash())
+	if err != nil {
+		t.Fatalf("bcrypt.Cost: %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
+
+func TestPasswordLoginUnknownEmail(t *testing.T) {
+	db := dbtest.Open(t)
+
+	email := "jon@example.com"
+	password := "correct horse"
+	_, err := CreateProfile(context.Background(), db, ProfileCreate{
+		KT:        "0101302989",
+		FirstName: "Jón",
+		LastName:  "Jónsson",
+		Email:     &email,
+		Password:  &password,
+	})
+	if err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input ProfilePasswordAuth
+	}{
+		{"unknown email", ProfilePasswordAuth{Email: "nobody@example.com", Password: password}},
+		{"wrong password", ProfilePasswordAuth{Email: email, Password: "wrong"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PasswordLogin(context.Background(), db, tt.input)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("got %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
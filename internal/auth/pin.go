@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	minPinLength = 4
+	maxPinLength = 6
+	temporaryPinLength = 6
+)
+
+func validatePin(pin string) error {
+	if len(pin) < minPinLength || len(pin) > maxPinLength {
+		return ErrInvalidPin
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return ErrInvalidPin
+		}
+	}
+	return nil
+}
+
+func generateTemporaryPin() (string, error) {
+	max := big.NewInt(1)
+	for range temporaryPinLength {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("generateTemporaryPin: rand: %w", err)
+	}
+	return fmt.Sprintf("%0*d", temporaryPinLength, n), nil
+}
+
+// rehashPin stores pin again with the current hash format and cost, used
+// after a successful login against an outdated hash.
+func rehashPin(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+	pin string,
+) error {
+	pinHash, err := hashPin(pin)
+	if err != nil {
+		return fmt.Errorf("rehashPin: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE profile_pin_auth
+		SET pin = $2, updated = now()
+		WHERE profile_id = $1
+		`,
+		profile_id,
+		pinHash,
+	)
+	if err != nil {
+		return fmt.Errorf("rehashPin: db update: %w", err)
+	}
+	return nil
+}
+
+// ChangePin replaces the caller's PIN after checking the old one. Every other
+// device is signed out and the calling device gets a fresh token pair, which
+// also clears a pending temporary PIN change.
+func ChangePin(
+	ctx context.Context,
+	db *sql.DB,
+	input PinChange,
+) (*PinChangeResponse, error) {
+	claims := ctx.Value(ClaimsKey).(*Claims)
+	profile_id := claims.ProfileID
+	deviceId := GetDeviceID(ctx)
+
+	err := validatePin(input.NewPin)
+	if err != nil {
+		return nil, err
+	}
+
+	err = countPinAttempt(ctx, db, profile_id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var pinHash string
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT pin
+		FROM profile_pin_auth
+		WHERE profile_id = $1
+		FOR UPDATE
+		`,
+		profile_id,
+	).Scan(
+		&pinHash,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: db select: %w", err)
+	}
+
+	ok, _, err := verifyPin(input.OldPin, pinHash)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: %w", err)
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	newPinHash, err := hashPin(input.NewPin)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE profile_pin_auth
+		SET pin = $2, must_change = false, updated = now()
+		WHERE profile_id = $1
+		`,
+		profile_id,
+		newPinHash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: db update: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE refresh_token
+		SET revoked_at = now()
+		WHERE profile_id = $1
+		AND device_id <> $2
+		AND revoked_at IS NULL
+		`,
+		profile_id,
+		deviceId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: revoke sessions: %w", err)
+	}
+
+	err = clearPinAttempts(ctx, tx, profile_id)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: %w", err)
+	}
+
+	access, refresh, err := rotateTokens(ctx, db, tx, profile_id, deviceId, claims.Auth, claims.EmploymentScope, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ChangePin: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ChangePin: db commit: %w", err)
+	}
+
+	return &PinChangeResponse{
+		Message: "PIN changed",
+		Tokens: Tokens{
+			AccessToken: *access,
+			RefreshToken: *refresh,
+		},
+	}, nil
+}
+
+// ResetPin gives a profile the caller manages a one-time temporary PIN that
+// has to be changed at the next login. All of the profile's sessions are
+// revoked and any lockout is lifted.
+func ResetPin(
+	ctx context.Context,
+	db *sql.DB,
+	profile_id int,
+) (*PinReset, error) {
+	temporaryPin, err := generateTemporaryPin()
+	if err != nil {
+		return nil, fmt.Errorf("ResetPin: %w", err)
+	}
+
+	temporaryPinHash, err := hashPin(temporaryPin)
+	if err != nil {
+		return nil, fmt.Errorf("ResetPin: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ResetPin: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = checkManagedProfile(ctx, tx, profile_id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		INSERT INTO profile_pin_auth (profile_id, pin, must_change)
+		VALUES ($1, $2, true)
+		ON CONFLICT (profile_id) DO UPDATE SET
+			pin = EXCLUDED.pin,
+			must_change = true,
+			updated = now()
+		`,
+		profile_id,
+		temporaryPinHash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ResetPin: db upsert: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE refresh_token
+		SET revoked_at = now()
+		WHERE profile_id = $1 AND revoked_at IS NULL
+		`,
+		profile_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ResetPin: revoke sessions: %w", err)
+	}
+
+	err = clearPinAttempts(ctx, tx, profile_id)
+	if err != nil {
+		return nil, fmt.Errorf("ResetPin: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ResetPin: db commit: %w", err)
+	}
+
+	return &PinReset{TemporaryPin: temporaryPin}, nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// PINs are stored as argon2id hashes in the PHC string format,
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+//
+// Rows without the prefix are legacy unsalted HMAC-SHA256 hashes keyed with
+// PIN_HASH_SECRET. Those are still accepted and get rehashed on the next
+// successful login.
+const argon2idPrefix = "$argon2id$"
+
+const (
+	pinSaltLength = 16
+	pinKeyLength  = 32
+)
+
+type pinHashParams struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+// currentPinHashParams reads the argon2id cost from PIN_HASH_MEMORY (KiB),
+// PIN_HASH_TIME and PIN_HASH_THREADS, falling back to the OWASP recommended
+// minimum.
+func currentPinHashParams() pinHashParams {
+	return pinHashParams{
+		Memory:  uint32(envUint("PIN_HASH_MEMORY", 19*1024, 32)),
+		Time:    uint32(envUint("PIN_HASH_TIME", 2, 32)),
+		Threads: uint8(envUint("PIN_HASH_THREADS", 1, 8)),
+	}
+}
+
+func envUint(key string, fallback uint64, bits int) uint64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseUint(val, 10, bits)
+	if err != nil || parsed == 0 {
+		return fallback
+	}
+	return parsed
+}
+
+func hashPin(pin string) (string, error) {
+	salt := make([]byte, pinSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("hashPin: rand: %w", err)
+	}
+
+	params := currentPinHashParams()
+	key := argon2.IDKey([]byte(pin), salt, params.Time, params.Memory, params.Threads, pinKeyLength)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		params.Memory,
+		params.Time,
+		params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPin checks pin against a stored hash. needsRehash is set when the
+// stored hash is a legacy HMAC or was made with other cost parameters than
+// the current ones.
+func verifyPin(pin string, stored string) (ok bool, needsRehash bool, err error) {
+	if !strings.HasPrefix(stored, argon2idPrefix) {
+		legacy := legacyHashPin(pin, os.Getenv("PIN_HASH_SECRET"))
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(legacy)) == 1
+		return ok, true, nil
+	}
+
+	params, salt, key, err := decodePinHash(stored)
+	if err != nil {
+		return false, false, fmt.Errorf("verifyPin: %w", err)
+	}
+
+	candidate := argon2.IDKey([]byte(pin), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	ok = subtle.ConstantTimeCompare(key, candidate) == 1
+
+	return ok, params != currentPinHashParams(), nil
+}
+
+func decodePinHash(stored string) (pinHashParams, []byte, []byte, error) {
+	var params pinHashParams
+
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("decodePinHash: malformed hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("decodePinHash: version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("decodePinHash: unsupported version %d", version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("decodePinHash: params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("decodePinHash: salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("decodePinHash: key: %w", err)
+	}
+
+	return params, salt, key, nil
+}
+
+func legacyHashPin(pin string, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(pin))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"strings"
+	"test/internal/db/dbtest"
+	"testing"
+)
+
+// cheapPinHash keeps argon2id fast enough for tests.
+func cheapPinHash(t *testing.T) {
+	t.Setenv("PIN_HASH_MEMORY", "64")
+	t.Setenv("PIN_HASH_TIME", "1")
+	t.Setenv("PIN_HASH_THREADS", "1")
+}
+
+func TestPinHashRoundTrip(t *testing.T) {
+	cheapPinHash(t)
+
+	stored, err := hashPin("1234")
+	if err != nil {
+		t.Fatalf("hashPin: %v", err)
+	}
+	if !strings.HasPrefix(stored, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %q isn't in the PHC format with the current params", stored)
+	}
+
+	params, salt, key, err := decodePinHash(stored)
+	if err != nil {
+		t.Fatalf("decodePinHash: %v", err)
+	}
+	if params != currentPinHashParams() || len(salt) != pinSaltLength || len(key) != pinKeyLength {
+		t.Errorf("decoded %+v with %d byte salt and %d byte key", params, len(salt), len(key))
+	}
+
+	again, err := hashPin("1234")
+	if err != nil {
+		t.Fatalf("hashPin: %v", err)
+	}
+	if again == stored {
+		t.Error("the same PIN hashed twice gave the same hash, the salt isn't random")
+	}
+
+	tests := []struct {
+		pin string
+		ok  bool
+	}{
+		{"1234", true},
+		{"1235", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		ok, needsRehash, err := verifyPin(tt.pin, stored)
+		if err != nil {
+			t.Fatalf("verifyPin(%q): %v", tt.pin, err)
+		}
+		if ok != tt.ok || needsRehash {
+			t.Errorf("verifyPin(%q) = %v, %v, want %v, false", tt.pin, ok, needsRehash, tt.ok)
+		}
+	}
+}
+
+func TestDecodePinHashMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored string
+	}{
+		{"too few parts", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"},
+		{"other version", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5"},
+		{"no version", "$argon2id$19$m=64,t=1,p=1$c2FsdA$a2V5"},
+		{"bad params", "$argon2id$v=19$m=64$c2FsdA$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5"},
+		{"bad key", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!!!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := decodePinHash(tt.stored); err == nil {
+				t.Errorf("decodePinHash(%q) succeeded", tt.stored)
+			}
+			if _, _, err := verifyPin("1234", tt.stored); err == nil {
+				t.Errorf("verifyPin against %q succeeded", tt.stored)
+			}
+		})
+	}
+}
+
+func TestVerifyLegacyPin(t *testing.T) {
+	t.Setenv("PIN_HASH_SECRET", "secret")
+	stored := legacyHashPin("1234", "secret")
+
+	tests := []struct {
+		pin string
+		ok  bool
+	}{
+		{"1234", true},
+		{"4321", false},
+	}
+	for _, tt := range tests {
+		ok, needsRehash, err := verifyPin(tt.pin, stored)
+		if err != nil {
+			t.Fatalf("verifyPin(%q): %v", tt.pin, err)
+		}
+		// legacy hashes are replaced whenever the PIN is right
+		if ok != tt.ok || !needsRehash {
+			t.Errorf("verifyPin(%q) = %v, %v, want %v, true", tt.pin, ok, needsRehash, tt.ok)
+		}
+	}
+}
+
+func TestVerifyPinNeedsRehash(t *testing.T) {
+	cheapPinHash(t)
+	stored, err := hashPin("1234")
+	if err != nil {
+		t.Fatalf("hashPin: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"memory", "PIN_HASH_MEMORY", "128"},
+		{"time", "PIN_HASH_TIME", "2"},
+		{"threads", "PIN_HASH_THREADS", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			ok, needsRehash, err := verifyPin("1234", stored)
+			if err != nil {
+				t.Fatalf("verifyPin: %v", err)
+			}
+			if !ok || !needsRehash {
+				t.Errorf("verifyPin = %v, %v, want true, true", ok, needsRehash)
+			}
+		})
+	}
+}
+
+func TestPinLoginRehashes(t *testing.T) {
+	db := dbtest.Open(t)
+	useTestKeyring(t)
+	cheapPinHash(t)
+	t.Setenv("PIN_HASH_SECRET", "secret")
+	profile := createPinProfile(t, db, "0101302989", "1234")
+
+	legacy := legacyHashPin("1234", "secret")
+	dbtest.Exec(t, db, `UPDATE profile_pin_auth SET pin = $1 WHERE profile_id = $2`, legacy, profile)
+
+	if err := pinLogin(db, "0101302989", "1234", "device"); err != nil {
+		t.Fatalf("login with the legacy hash: %v", err)
+	}
+
+	var stored string
+	err := db.QueryRow(`SELECT pin FROM profile_pin_auth WHERE profile_id = $1`, profile).Scan(&stored)
+	if err != nil {
+		t.Fatalf("select pin: %v", err)
+	}
+	if !strings.HasPrefix(stored, argon2idPrefix) {
+		t.Fatalf("stored hash %q wasn't rehashed", stored)
+	}
+
+	// and the new hash still lets the profile in
+	if err := pinLogin(db, "0101302989", "1234", "device"); err != nil {
+		t.Errorf("login with the rehashed PIN: %v", err)
+	}
+}
@@ -2,8 +2,12 @@ package auth
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
+	"strconv"
 	"test/internal/abstractions"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func RegisterHandler(db *sql.DB) http.HandlerFunc {
@@ -21,3 +25,97 @@ func SilentRefreshHandler(db *sql.DB) http.HandlerFunc {
 func ReAuthHandler(db *sql.DB) http.HandlerFunc {
 	return abstractions.CreateJSONHandler(db, WarmStartPin, WriteDomainError)
 }
+
+func PasswordLoginHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, PasswordLogin, WriteDomainError)
+}
+
+func SwitchEmploymentHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, SwitchEmployment, WriteDomainError)
+}
+
+func LogoutHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, Logout, WriteDomainError)
+}
+
+func GetSessionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := GetSessions(r.Context(), db)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func RevokeProfileSessionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := RevokeProfileSessions(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func UnlockProfileHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := UnlockProfile(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func ChangePinHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, ChangePin, WriteDomainError)
+}
+
+func ResetPinHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := ResetPin(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func JWKSHandler(keys *Keyring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(keys.JWKS())
+	}
+}
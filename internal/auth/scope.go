@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Every manage query is limited to what the caller may manage, given as
+// subqueries on the profile in placeholder $n. Employments bind to a company,
+// or only to a workspace for the owner who created it.
+
+// ManagedWorkspaces returns a subquery of the workspaces the profile in
+// placeholder $n holds an active owner, admin or manager employment in.
+func ManagedWorkspaces(n int) string {
+	return fmt.Sprintf(`
+		SELECT COALESCE(ec.workspace_id, e.workspace_id)
+		FROM employment e
+		LEFT JOIN company ec ON ec.id = e.company_id
+		WHERE e.profile_id = $%d
+		AND e.role IN ('owner', 'admin', 'manager')
+		AND (e.start_date IS NULL OR e.start_date <= now())
+		AND (e.end_date IS NULL OR e.end_date > now())
+	`, n)
+}
+
+// OwnedWorkspaces returns a subquery of the workspaces the profile in
+// placeholder $n is an owner or admin of.
+func OwnedWorkspaces(n int) string {
+	return fmt.Sprintf(`
+		SELECT COALESCE(ec.workspace_id, e.workspace_id)
+		FROM employment e
+		LEFT JOIN company ec ON ec.id = e.company_id
+		WHERE e.profile_id = $%d
+		AND e.role IN ('owner', 'admin')
+		AND (e.start_date IS NULL OR e.start_date <= now())
+		AND (e.end_date IS NULL OR e.end_date > now())
+	`, n)
+}
+
+// ManagedCompanies returns a subquery of the companies the profile in
+// placeholder $n may manage. Managers manage the companies they are employed
+// at, owners and admins every company in the same workspace.
+func ManagedCompanies(n int) string {
+	return fmt.Sprintf(`
+		SELECT c.id
+		FROM employment e
+		LEFT JOIN company ec ON ec.id = e.company_id
+		JOIN company c ON c.id = e.company_id
+			OR (e.role IN ('owner', 'admin') AND c.workspace_id = COALESCE(ec.workspace_id, e.workspace_id))
+		WHERE e.profile_id = $%d
+		AND e.role IN ('owner', 'admin', 'manager')
+		AND (e.start_date IS NULL OR e.start_date <= now())
+		AND (e.end_date IS NULL OR e.end_date > now())
+	`, n)
+}
+
+// ManagedProfiles returns a subquery of the profiles employed, at any time,
+// at a company the profile in placeholder $n may manage.
+func ManagedProfiles(n int) string {
+	return fmt.Sprintf(`
+		SELECT profile_id
+		FROM employment
+		WHERE company_id IN (%s)
+	`, ManagedCompanies(n))
+}
+
+// checkManagedProfile returns ErrProfileNotFound unless the caller may manage
+// the profile, so profiles of other tenants look like they don't exist.
+func checkManagedProfile(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+) error {
+	claims := ctx.Value(ClaimsKey).(*Claims)
+
+	var managed bool
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT $1 IN (`+ManagedProfiles(2)+`)
+		`,
+		profile_id,
+		claims.ProfileID,
+	).Scan(&managed)
+	if err != nil {
+		return fmt.Errorf("checkManagedProfile: db select: %w", err)
+	}
+	if !managed {
+		return ErrProfileNotFound
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+func Logout(
+	ctx context.Context,
+	db *sql.DB,
+	input ProfileLogout,
+) (*MessageResponse, error) {
+	deviceId := GetDeviceID(ctx)
+
+	result, err := db.ExecContext(
+		ctx,
+		`
+		UPDATE refresh_token
+		SET revoked_at = now()
+		WHERE revoked_at IS NULL
+		AND family_id = (
+			SELECT family_id
+			FROM refresh_token
+			WHERE token_hash = $1 AND device_id = $2
+		)
+		`,
+		hashToken(input.RefreshToken),
+		deviceId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Logout: db update: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("Logout: rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return &MessageResponse{Message: "Logged out"}, nil
+}
+
+// GetSessions lists the devices the caller currently holds a live refresh
+// token on. A session's created time is when its token family was issued at
+// login, its last used time when the token was last rotated.
+func GetSessions(
+	ctx context.Context,
+	db *sql.DB,
+) (*[]Session, error) {
+	claims := ctx.Value(ClaimsKey).(*Claims)
+	profile_id := claims.ProfileID
+	deviceId := GetDeviceID(ctx)
+
+	sessions := []Session{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT
+			r.device_id,
+			COALESCE(r.user_agent, ''),
+			r.auth,
+			(
+				SELECT MIN(f.created_at)
+				FROM refresh_token f
+				WHERE f.family_id = r.family_id
+			),
+			r.created_at,
+			r.expires_at
+		FROM refresh_token r
+		WHERE r.profile_id = $1
+		AND r.rotated_at IS NULL
+		AND r.revoked_at IS NULL
+		AND r.expires_at > now()
+		ORDER BY r.created_at DESC
+		`,
+		profile_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetSessions: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session Session
+		err = rows.Scan(
+			&session.DeviceId,
+			&session.UserAgent,
+			&session.Auth,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("GetSessions: db scan: %w", err)
+		}
+
+		session.Current = session.DeviceId == deviceId
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetSessions: rows: %w", err)
+	}
+
+	return &sessions, nil
+}
+
+// RevokeProfileSessions ends every session of a profile, e.g. for a lost
+// tablet or a terminated employee. It returns the number of devices that
+// were signed out. A caller in ctx can only sign out profiles they manage,
+// without claims the revocation comes from the command line tooling and is
+// attributed to it.
+func RevokeProfileSessions(
+	ctx context.Context,
+	db *sql.DB,
+	profile_id int,
+) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("RevokeProfileSessions: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	revokedBy := "kronosctl"
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		revokedBy = fmt.Sprintf("profile %d", claims.ProfileID)
+
+		err = checkManagedProfile(ctx, tx, profile_id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`
+		UPDATE refresh_token
+		SET revoked_at = now()
+		WHERE profile_id = $1
+		AND rotated_at IS NULL
+		AND revoked_at IS NULL
+		`,
+		profile_id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("RevokeProfileSessions: db update: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("RevokeProfileSessions: rows affected: %w", err)
+	}
+
+	err = recordSecurityEvent(
+		ctx,
+		tx,
+		profile_id,
+		GetDeviceID(ctx),
+		SecurityEventSessionsRevoked,
+		fmt.Sprintf("%d sessions revoked by %s", rows, revokedBy),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("RevokeProfileSessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("RevokeProfileSessions: db commit: %w", err)
+	}
+
+	return rows, nil
+}
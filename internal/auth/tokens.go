@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+type refreshTokenRecord struct {
+	Id           int
+	ProfileId    int
+	FamilyId     string
+	Auth         string
+	EmploymentId *int
+	RotatedAt    *time.Time
+}
+
+// getRefreshToken looks up a presented refresh token for the device. If the
+// token was already rotated it has been used before, so the whole family is
+// revoked, a security event is recorded and ErrTokenReused is returned.
+func getRefreshToken(
+	ctx context.Context,
+	db *sql.DB,
+	tx *sql.Tx,
+	token string,
+	device_id string,
+) (*refreshTokenRecord, error) {
+	var record refreshTokenRecord
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT id, profile_id, family_id, auth, employment_id, rotated_at
+		FROM refresh_token
+		WHERE token_hash = $1
+		AND device_id = $2
+		AND revoked_at IS NULL
+		AND expires_at > now()
+		`,
+		hashToken(token),
+		device_id,
+	).Scan(
+		&record.Id,
+		&record.ProfileId,
+		&record.FamilyId,
+		&record.Auth,
+		&record.EmploymentId,
+		&record.RotatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getRefreshToken: db select: %w", err)
+	}
+
+	if record.RotatedAt != nil {
+		// runs outside the caller's transaction, which is rolled back
+		// once ErrTokenReused is returned
+		err = revokeTokenFamily(ctx, db, record, device_id)
+		if err != nil {
+			return nil, fmt.Errorf("getRefreshToken: %w", err)
+		}
+		return nil, ErrTokenReused
+	}
+
+	return &record, nil
+}
+
+func revokeTokenFamily(
+	ctx context.Context,
+	db *sql.DB,
+	record refreshTokenRecord,
+	device_id string,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("revokeTokenFamily: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE refresh_token
+		SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL
+		`,
+		record.FamilyId,
+	)
+	if err != nil {
+		return fmt.Errorf("revokeTokenFamily: db update: %w", err)
+	}
+
+	err = recordSecurityEvent(
+		ctx,
+		tx,
+		record.ProfileId,
+		device_id,
+		SecurityEventTokenReuse,
+		fmt.Sprintf("refresh token %d reused, family %s revoked", record.Id, record.FamilyId),
+	)
+	if err != nil {
+		return fmt.Errorf("revokeTokenFamily: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("revokeTokenFamily: db commit: %w", err)
+	}
+
+	log.Printf("security: refresh token reuse for profile %d on device %s", record.ProfileId, device_id)
+	return nil
+}
+
+func recordSecurityEvent(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+	device_id string,
+	kind string,
+	detail string,
+) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`
+		INSERT INTO security_event (profile_id, device_id, kind, detail)
+		VALUES ($1, $2, $3, $4)
+		`,
+		profile_id,
+		device_id,
+		kind,
+		detail,
+	)
+	if err != nil {
+		return fmt.Errorf("recordSecurityEvent: db insert: %w", err)
+	}
+	return nil
+}
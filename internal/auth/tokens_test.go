@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"test/internal/db/dbtest"
+	"testing"
+	"time"
+)
+
+// useTestKeyring makes the process wide keyring a fresh one, unless another
+// test has already loaded it.
+func useTestKeyring(t *testing.T) {
+	t.Helper()
+	keyringOnce.Do(func() {
+		keyring, keyringErr = NewKeyring([]KeyFileEntry{newEntry(t, "HS256", "test", time.Now().Add(-time.Hour))})
+	})
+	if keyringErr != nil {
+		t.Fatalf("keyring: %v", keyringErr)
+	}
+}
+
+func TestRefreshConcurrentReuse(t *testing.T) {
+	db := dbtest.Open(t)
+	useTestKeyring(t)
+	profile := createPinProfile(t, db, "0101302989", "1234")
+
+	ctx := context.WithValue(context.Background(), DeviceIdKey, "device")
+	login, err := ColdStartPin(ctx, db, ProfilePinAuth{KT: "0101302989", Pin: "1234"})
+	if err != nil {
+		t.Fatalf("ColdStartPin: %v", err)
+	}
+
+	// both refreshes present the same token, only one of them may rotate it
+	const refreshes = 2
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		rotated []string
+		reused  int
+	)
+	for i := range refreshes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := RefreshTokens(ctx, db, ProfileSilentRefresh{RefreshToken: login.Tokens.RefreshToken.Token})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				rotated = append(rotated, response.Tokens.RefreshToken.Token)
+			case errors.Is(err, ErrTokenReused):
+				reused++
+			default:
+				t.Errorf("refresh %d: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(rotated) != 1 || reused != 1 {
+		t.Fatalf("%d refreshes rotated the token and %d were refused, want 1 and 1", len(rotated), reused)
+	}
+
+	// the reuse revoked the family, including the token the winner got
+	_, err = RefreshTokens(ctx, db, ProfileSilentRefresh{RefreshToken: rotated[0]})
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh with the rotated token: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	events := dbtest.QueryInt(t, db, `
+		SELECT count(*) FROM security_event
+		WHERE profile_id = $1 AND kind = $2`, profile, SecurityEventTokenReuse)
+	if events != 1 {
+		t.Errorf("got %d token reuse events, want 1", events)
+	}
+}
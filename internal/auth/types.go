@@ -2,6 +2,7 @@ package auth
 
 import (
 	"test/internal/model"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -21,18 +22,40 @@ type ProfileSilentRefresh struct {
 }
 
 type ProfilePinAuth struct {
-	KT       string `json:"kt"`
-	Pin      string `json:"pin"`
+	KT           string `json:"kt"`
+	Pin          string `json:"pin"`
+	EmploymentId *int   `json:"employment_id,omitempty"`
 }
 
 type ProfileReAuth struct {
 	Pin          string `json:"pin"`
 	RefreshToken string `json:"refresh_token"`
+	EmploymentId *int   `json:"employment_id,omitempty"`
 }
 
 type ProfilePasswordAuth struct {
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Password     string `json:"password,omitempty"`
+	EmploymentId *int   `json:"employment_id,omitempty"`
+}
+
+type PinChange struct {
+	OldPin string `json:"old_pin"`
+	NewPin string `json:"new_pin"`
+}
+
+type PinChangeResponse struct {
+	Message string `json:"message"`
+	Tokens  Tokens `json:"tokens"`
+}
+
+type PinReset struct {
+	TemporaryPin string `json:"temporary_pin"`
+}
+
+type EmploymentSwitch struct {
+	RefreshToken string `json:"refresh_token"`
+	EmploymentId int    `json:"employment_id"`
 }
 
 type Tokens struct {
@@ -50,22 +73,71 @@ type AccessToken struct {
 	ExpiresAt int64 `json:"expires_at"`
 }
 
-type ProfileExtended struct {
-	Profile model.Profile `json:"profile"`
+// EmploymentDetail is an employment with where it is. Company is nil for an
+// employment bound only to its workspace.
+type EmploymentDetail struct {
 	Employment model.Employment `json:"employment"`
-	Company model.Company `json:"company"`
+	Company *model.Company `json:"company"`
 	Workspace model.Workspace `json:"workspace"`
 }
 
+// ProfileExtended carries the employment the session is scoped to, which is
+// nil when the profile has several active employments and none was picked
+// yet, along with every active employment the client can switch to.
+type ProfileExtended struct {
+	Profile model.Profile `json:"profile"`
+	Employment *model.Employment `json:"employment"`
+	Company *model.Company `json:"company"`
+	Workspace *model.Workspace `json:"workspace"`
+	Employments []EmploymentDetail `json:"employments"`
+}
+
 type AuthResponse struct {
 	Message string `json:"message"`
 	Tokens  Tokens `json:"tokens"`
+	PinChangeRequired bool `json:"pin_change_required"`
 	ProfileExtended ProfileExtended `json:"profile_extended"`
 }
 
+const (
+	AuthStagePin      = "pin"
+	AuthStagePassword = "password"
+)
+
+type EmploymentScope struct {
+	EmploymentID int `json:"emp,omitempty"`
+	CompanyID    int `json:"cmp,omitempty"`
+	WorkspaceID  int `json:"wsp,omitempty"`
+}
+
+type ProfileLogout struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type Session struct {
+	DeviceId   string    `json:"device_id"`
+	UserAgent  string    `json:"user_agent"`
+	Auth       string    `json:"auth"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const (
+	SecurityEventTokenReuse      = "refresh_token_reuse"
+	SecurityEventSessionsRevoked = "sessions_revoked"
+)
+
 type Claims struct {
 	ProfileID int    `json:"sub"`
 	Auth   string `json:"auth"`
+	PinChangeRequired bool `json:"pcr,omitempty"`
+	EmploymentScope
 	jwt.RegisteredClaims
 }
 
@@ -0,0 +1,94 @@
+// Package dbtest gives tests a migrated database of their own.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	dbrepo "test/internal/db"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Open returns a database migrated to the latest version, in a schema of its
+// own that is dropped when the test ends. The test is skipped unless
+// TEST_DATABASE_CONNECTION_STRING points at a Postgres server.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	conn := os.Getenv("TEST_DATABASE_CONNECTION_STRING")
+	if conn == "" {
+		t.Skip("TEST_DATABASE_CONNECTION_STRING is not set")
+	}
+
+	admin, err := sql.Open("postgres", conn)
+	if err != nil {
+		t.Fatalf("dbtest: open: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	if err != nil {
+		t.Fatalf("dbtest: create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+	})
+
+	db, err := sql.Open("postgres", withSearchPath(conn, schema))
+	if err != nil {
+		t.Fatalf("dbtest: open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = dbrepo.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+
+	return db
+}
+
+// withSearchPath sets the search_path of every connection made with conn,
+// given as a URL or as key=value pairs.
+func withSearchPath(conn string, schema string) string {
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		u, err := url.Parse(conn)
+		if err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return conn + " search_path=" + schema
+}
+
+// Exec runs query on db, failing the test on an error. It is meant for
+// setting up rows the code under test doesn't create itself.
+func Exec(t testing.TB, db *sql.DB, query string, args ...any) {
+	t.Helper()
+
+	_, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+}
+
+// QueryInt returns the single integer query selects.
+func QueryInt(t testing.TB, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+
+	var n int
+	err := db.QueryRow(query, args...).Scan(&n)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	return n
+}
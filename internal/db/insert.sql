@@ -25,8 +25,11 @@ VALUES ('Sample Company', 1);
 INSERT INTO company (name, workspace_id)
 VALUES ('Sample Company 2', 1);
 
-INSERT INTO contract (hourly_rate, unpaid_lunch_minutes)
-VALUES (4500, 30);
+INSERT INTO contract (workspace_id)
+VALUES (1);
+
+INSERT INTO contract_version (contract_id, effective_from, hourly_rate, unpaid_lunch_minutes)
+VALUES (1, now() - interval '1 year', 4500, 30);
 
 INSERT INTO employment (profile_id, company_id, contract_id, role, end_date)
 VALUES (1, 1, 1, 'worker', now() + interval '30 days');
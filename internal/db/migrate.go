@@ -0,0 +1,320 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the pg_advisory_lock key held while migrating, so that
+// several instances starting at once don't apply the same migration twice.
+const migrationLockKey = 7305126
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Migrations returns the embedded migrations ordered by version. Files are
+// named <version>_<name>.up.sql and <version>_<name>.down.sql.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("Migrations: read dir: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("Migrations: unexpected file %s", file)
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("Migrations: unexpected file %s", file)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("Migrations: bad version in %s: %w", file, err)
+		}
+
+		b, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, fmt.Errorf("Migrations: read %s: %w", file, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("Migrations: version %d used by %s and %s", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("Migrations: %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration
+// advisory lock.
+func withMigrationLock(
+	ctx context.Context,
+	db *sql.DB,
+	fn func(conn *sql.Conn) error,
+) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("withMigrationLock: conn: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey)
+	if err != nil {
+		return fmt.Errorf("withMigrationLock: lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	_, err = conn.ExecContext(
+		ctx,
+		`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+		`,
+	)
+	if err != nil {
+		return fmt.Errorf("withMigrationLock: create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+	rows, err := conn.QueryContext(
+		ctx,
+		`
+		SELECT version, applied_at
+		FROM schema_migrations
+		`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("appliedVersions: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		err = rows.Scan(
+			&version,
+			&appliedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("appliedVersions: db scan: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigration executes one migration step and records it in
+// schema_migrations within the same transaction.
+func runMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("runMigration: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return fmt.Errorf("runMigration: %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			INSERT INTO schema_migrations (version, name)
+			VALUES ($1, $2)
+			`,
+			m.Version,
+			m.Name,
+		)
+	} else {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			DELETE FROM schema_migrations WHERE version = $1
+			`,
+			m.Version,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("runMigration: record %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("runMigration: db commit: %w", err)
+	}
+	return nil
+}
+
+// Migrate applies every migration that hasn't been applied yet, in order.
+// It returns how many were applied.
+func Migrate(ctx context.Context, db *sql.DB) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, fmt.Errorf("Migrate: %w", err)
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			err = runMigration(ctx, conn, m, true)
+			if err != nil {
+				return err
+			}
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("Migrate: %w", err)
+	}
+
+	return count, nil
+}
+
+// Rollback reverts the last steps applied migrations, newest first.
+func Rollback(ctx context.Context, db *sql.DB, steps int) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, fmt.Errorf("Rollback: %w", err)
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+
+			err = runMigration(ctx, conn, m, false)
+			if err != nil {
+				return err
+			}
+			log.Printf("Rolled back migration %04d_%s", m.Version, m.Name)
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("Rollback: %w", err)
+	}
+
+	return count, nil
+}
+
+// Status lists every embedded migration along with when it was applied, nil
+// for pending ones.
+func Status(ctx context.Context, db *sql.DB) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, fmt.Errorf("Status: %w", err)
+	}
+
+	states := []MigrationState{}
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			state := MigrationState{Version: m.Version, Name: m.Name}
+			if appliedAt, ok := applied[m.Version]; ok {
+				state.AppliedAt = &appliedAt
+			}
+			states = append(states, state)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Status: %w", err)
+	}
+
+	return states, nil
+}
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"context"
+	dbrepo "test/internal/db"
+	"test/internal/db/dbtest"
+	"testing"
+)
+
+func TestMigrationsNumbered(t *testing.T) {
+	migrations, err := dbrepo.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]bool{}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %04d_%s, want version %d", m.Version, m.Name, i+1)
+		}
+		if names[m.Name] {
+			t.Errorf("migration name %s used twice", m.Name)
+		}
+		names[m.Name] = true
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	migrations, err := dbrepo.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := dbrepo.Rollback(ctx, db, len(migrations))
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if count != len(migrations) {
+		t.Errorf("rolled back %d migrations, want %d", count, len(migrations))
+	}
+
+	count, err = dbrepo.Migrate(ctx, db)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if count != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", count, len(migrations))
+	}
+}
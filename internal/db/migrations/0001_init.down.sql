@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS edit_request;
+DROP TABLE IF EXISTS refresh_token;
+DROP TABLE IF EXISTS shift;
+DROP TABLE IF EXISTS task;
+DROP TABLE IF EXISTS employment;
+DROP TABLE IF EXISTS contract;
+DROP TABLE IF EXISTS company;
+DROP TABLE IF EXISTS location;
+DROP TABLE IF EXISTS workspace;
+DROP TABLE IF EXISTS profile_password_auth;
+DROP TABLE IF EXISTS profile_pin_auth;
+DROP TABLE IF EXISTS profile;
@@ -128,3 +128,7 @@ CREATE TABLE IF NOT EXISTS edit_request (
     FOREIGN KEY (shift_id) REFERENCES shift(id) ON DELETE CASCADE,
     FOREIGN KEY (task_id) REFERENCES task(id)
 );
+
+CREATE UNIQUE INDEX IF NOT EXISTS one_ongoing_shift_per_employment
+ON shift (profile_id)
+WHERE end_ts IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS profile_password_auth_email;
+
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS auth;
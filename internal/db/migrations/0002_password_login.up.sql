@@ -0,0 +1,6 @@
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS auth VARCHAR(20) NOT NULL DEFAULT 'pin'
+        CHECK (auth IN ('pin', 'password'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS profile_password_auth_email
+ON profile_password_auth (lower(email));
@@ -0,0 +1,2 @@
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS employment_id;
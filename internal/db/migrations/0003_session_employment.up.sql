@@ -0,0 +1,2 @@
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS employment_id INT REFERENCES employment(id) ON DELETE SET NULL;
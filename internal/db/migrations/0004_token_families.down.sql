@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS security_event;
+
+DROP INDEX IF EXISTS refresh_token_family;
+DROP INDEX IF EXISTS refresh_token_active_per_device;
+
+-- only the live token of each device fits the old one token per device
+DELETE FROM refresh_token
+WHERE rotated_at IS NOT NULL OR revoked_at IS NOT NULL;
+
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS family_id,
+    DROP COLUMN IF EXISTS parent_id,
+    DROP COLUMN IF EXISTS rotated_at,
+    DROP COLUMN IF EXISTS revoked_at,
+    ADD CONSTRAINT refresh_token_profile_id_device_id_key UNIQUE (profile_id, device_id);
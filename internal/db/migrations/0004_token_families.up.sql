@@ -0,0 +1,31 @@
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS family_id TEXT,
+    ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES refresh_token(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+-- every token issued so far starts a family of its own
+UPDATE refresh_token
+SET family_id = 'legacy-' || id
+WHERE family_id IS NULL;
+
+ALTER TABLE refresh_token
+    ALTER COLUMN family_id SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS refresh_token_profile_id_device_id_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_token_active_per_device
+ON refresh_token (profile_id, device_id)
+WHERE rotated_at IS NULL AND revoked_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS refresh_token_family
+ON refresh_token (family_id);
+
+CREATE TABLE IF NOT EXISTS security_event (
+    id SERIAL PRIMARY KEY,
+    profile_id INT NOT NULL,
+    device_id TEXT,
+    kind VARCHAR(64) NOT NULL,
+    detail TEXT,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (profile_id) REFERENCES profile(id) ON DELETE CASCADE
+);
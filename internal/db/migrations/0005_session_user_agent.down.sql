@@ -0,0 +1,2 @@
+ALTER TABLE refresh_token
+    DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,2 @@
+ALTER TABLE refresh_token
+    ADD COLUMN IF NOT EXISTS user_agent TEXT;
@@ -0,0 +1,11 @@
+-- failed PIN attempts, counted per profile, per kt when no profile has it,
+-- and per device, keyed on the refresh token family since clients pick their
+-- own device id
+CREATE TABLE IF NOT EXISTS auth_attempt (
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('profile', 'kt', 'device')),
+    key TEXT NOT NULL,
+    failed_count INT NOT NULL DEFAULT 0,
+    last_failed TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
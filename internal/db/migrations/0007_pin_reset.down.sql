@@ -0,0 +1,3 @@
+ALTER TABLE profile_pin_auth
+    DROP CONSTRAINT IF EXISTS profile_pin_auth_profile_id_key,
+    DROP COLUMN IF EXISTS must_change;
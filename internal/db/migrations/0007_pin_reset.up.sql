@@ -0,0 +1,12 @@
+ALTER TABLE profile_pin_auth
+    ADD COLUMN IF NOT EXISTS must_change BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- a profile has one PIN, keep the one set last
+DELETE FROM profile_pin_auth a
+USING profile_pin_auth b
+WHERE a.profile_id = b.profile_id
+AND (a.updated, a.ctid) < (b.updated, b.ctid);
+
+ALTER TABLE profile_pin_auth
+    DROP CONSTRAINT IF EXISTS profile_pin_auth_profile_id_key,
+    ADD CONSTRAINT profile_pin_auth_profile_id_key UNIQUE (profile_id);
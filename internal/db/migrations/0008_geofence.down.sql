@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS shift_flagged;
+
+ALTER TABLE shift
+    DROP COLUMN IF EXISTS s_accuracy,
+    DROP COLUMN IF EXISTS e_accuracy,
+    DROP COLUMN IF EXISTS s_flagged,
+    DROP COLUMN IF EXISTS e_flagged;
+
+ALTER TABLE workspace
+    DROP COLUMN IF EXISTS geofence_policy;
+
+ALTER TABLE location
+    DROP COLUMN IF EXISTS latitude,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS radius_m;
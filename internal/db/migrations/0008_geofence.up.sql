@@ -0,0 +1,18 @@
+ALTER TABLE location
+    ADD COLUMN latitude DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION,
+    ADD COLUMN radius_m INT CHECK (radius_m > 0);
+
+ALTER TABLE workspace
+    ADD COLUMN geofence_policy VARCHAR(20) NOT NULL DEFAULT 'ignore'
+        CHECK (geofence_policy IN ('reject', 'flag', 'ignore'));
+
+ALTER TABLE shift
+    ADD COLUMN s_accuracy DOUBLE PRECISION,
+    ADD COLUMN e_accuracy DOUBLE PRECISION,
+    ADD COLUMN s_flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN e_flagged BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX shift_flagged
+ON shift (start_ts)
+WHERE s_flagged OR e_flagged;
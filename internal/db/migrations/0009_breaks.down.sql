@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS shift_break;
+DROP TABLE IF EXISTS break_type;
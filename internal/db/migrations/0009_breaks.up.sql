@@ -0,0 +1,33 @@
+CREATE TABLE break_type (
+    id SERIAL PRIMARY KEY,
+    contract_id INT NOT NULL,
+    name TEXT NOT NULL,
+    paid BOOLEAN NOT NULL DEFAULT FALSE,
+    max_minutes INT CHECK (max_minutes > 0),
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (contract_id) REFERENCES contract(id) ON DELETE CASCADE
+);
+
+-- paid and max_minutes are copied from the break type when the break starts
+-- so editing a contract doesn't change breaks that were already taken
+CREATE TABLE shift_break (
+    id SERIAL PRIMARY KEY,
+    shift_id INT NOT NULL,
+    break_type_id INT,
+    paid BOOLEAN NOT NULL,
+    max_minutes INT,
+    start_ts TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    end_ts TIMESTAMPTZ,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (shift_id) REFERENCES shift(id) ON DELETE CASCADE,
+    FOREIGN KEY (break_type_id) REFERENCES break_type(id) ON DELETE SET NULL
+);
+
+CREATE INDEX shift_break_shift
+ON shift_break (shift_id);
+
+CREATE UNIQUE INDEX one_ongoing_break_per_shift
+ON shift_break (shift_id)
+WHERE end_ts IS NULL;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS shift_needs_review;
+
+CREATE INDEX shift_flagged
+ON shift (start_ts)
+WHERE s_flagged OR e_flagged;
+
+ALTER TABLE shift
+    DROP COLUMN IF EXISTS auto_closed;
+
+ALTER TABLE workspace
+    DROP COLUMN IF EXISTS max_shift_minutes,
+    DROP COLUMN IF EXISTS auto_close_edit_request;
@@ -0,0 +1,12 @@
+ALTER TABLE workspace
+    ADD COLUMN max_shift_minutes INT CHECK (max_shift_minutes > 0),
+    ADD COLUMN auto_close_edit_request BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE shift
+    ADD COLUMN auto_closed BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP INDEX IF EXISTS shift_flagged;
+
+CREATE INDEX shift_needs_review
+ON shift (start_ts)
+WHERE s_flagged OR e_flagged OR auto_closed;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS shift_fit_segments ON shift;
+DROP FUNCTION IF EXISTS shift_fit_segments();
+DROP TABLE IF EXISTS shift_segment;
@@ -0,0 +1,59 @@
+-- a segment starts when the worker switches task and runs until the next
+-- segment or the end of the shift. Shifts without any segments were worked
+-- on shift.task_id from start to end.
+CREATE TABLE shift_segment (
+    id SERIAL PRIMARY KEY,
+    shift_id INT NOT NULL,
+    task_id INT NOT NULL,
+    start_ts TIMESTAMPTZ NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (shift_id) REFERENCES shift(id) ON DELETE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES task(id)
+);
+
+CREATE INDEX shift_segment_shift
+ON shift_segment (shift_id, start_ts);
+
+-- moving the start or end of a shift drops the segments that are no longer
+-- part of it, so none ends up with a negative length. The shift stays on the
+-- task of its last segment, unless the same update corrects the task too.
+CREATE FUNCTION shift_fit_segments() RETURNS trigger AS $$
+DECLARE
+    last_task INT;
+BEGIN
+    -- switches after the shift ended, but never its first segment
+    DELETE FROM shift_segment
+    WHERE shift_id = NEW.id
+    AND start_ts >= NEW.end_ts
+    AND start_ts > (SELECT min(start_ts) FROM shift_segment WHERE shift_id = NEW.id);
+
+    -- segments over before the shift started, it now starts in the last one
+    -- that had started by then
+    DELETE FROM shift_segment
+    WHERE shift_id = NEW.id
+    AND start_ts < (
+        SELECT max(start_ts) FROM shift_segment
+        WHERE shift_id = NEW.id AND start_ts <= NEW.start_ts
+    );
+
+    SELECT task_id INTO last_task
+    FROM shift_segment
+    WHERE shift_id = NEW.id
+    ORDER BY start_ts DESC
+    LIMIT 1;
+    IF last_task IS NOT NULL AND NEW.task_id = OLD.task_id THEN
+        NEW.task_id := last_task;
+    END IF;
+
+    -- a single segment is the same as none
+    DELETE FROM shift_segment
+    WHERE shift_id = NEW.id
+    AND (SELECT count(*) FROM shift_segment WHERE shift_id = NEW.id) = 1;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER shift_fit_segments
+BEFORE UPDATE OF start_ts, end_ts ON shift
+FOR EACH ROW EXECUTE FUNCTION shift_fit_segments();
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS shift_bump_version ON shift;
+DROP FUNCTION IF EXISTS shift_bump_version();
+DROP INDEX IF EXISTS shift_client_id;
+
+ALTER TABLE shift
+    DROP COLUMN IF EXISTS client_id,
+    DROP COLUMN IF EXISTS version;
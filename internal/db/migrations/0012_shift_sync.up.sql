@@ -0,0 +1,21 @@
+ALTER TABLE shift
+    ADD COLUMN client_id UUID,
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+CREATE UNIQUE INDEX shift_client_id
+ON shift (profile_id, client_id)
+WHERE client_id IS NOT NULL;
+
+-- every change to a shift bumps its version so offline clients can tell
+-- when the copy they edited is stale
+CREATE FUNCTION shift_bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    NEW.updated := now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER shift_bump_version
+BEFORE UPDATE ON shift
+FOR EACH ROW EXECUTE FUNCTION shift_bump_version();
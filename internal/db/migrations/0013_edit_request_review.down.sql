@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS edit_request_pending;
+
+DELETE FROM edit_request WHERE status = 'cancelled';
+
+ALTER TABLE edit_request
+    DROP CONSTRAINT IF EXISTS edit_request_status_check,
+    ADD CONSTRAINT edit_request_status_check
+        CHECK (status IN ('pending', 'rejected', 'approved')),
+    ALTER COLUMN status DROP NOT NULL,
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS review_comment,
+    DROP COLUMN IF EXISTS reviewer_id,
+    DROP COLUMN IF EXISTS profile_id;
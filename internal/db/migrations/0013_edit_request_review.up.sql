@@ -0,0 +1,23 @@
+ALTER TABLE edit_request
+    ADD COLUMN profile_id INT,
+    ADD COLUMN reviewer_id INT,
+    ADD COLUMN review_comment TEXT,
+    ADD COLUMN reviewed_at TIMESTAMPTZ,
+    ADD FOREIGN KEY (profile_id) REFERENCES profile(id) ON DELETE CASCADE,
+    ADD FOREIGN KEY (reviewer_id) REFERENCES profile(id) ON DELETE SET NULL;
+
+UPDATE edit_request r
+SET profile_id = s.profile_id
+FROM shift s
+WHERE s.id = r.shift_id;
+
+ALTER TABLE edit_request
+    ALTER COLUMN profile_id SET NOT NULL,
+    ALTER COLUMN status SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS edit_request_status_check,
+    ADD CONSTRAINT edit_request_status_check
+        CHECK (status IN ('pending', 'rejected', 'approved', 'cancelled'));
+
+CREATE INDEX edit_request_pending
+ON edit_request (shift_id)
+WHERE status = 'pending';
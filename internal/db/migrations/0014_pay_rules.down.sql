@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS holiday;
+DROP TABLE IF EXISTS pay_rule;
+
+ALTER TABLE contract
+    DROP COLUMN IF EXISTS daily_overtime_minutes,
+    DROP COLUMN IF EXISTS weekly_overtime_minutes,
+    DROP COLUMN IF EXISTS overtime_multiplier;
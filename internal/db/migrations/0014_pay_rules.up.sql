@@ -0,0 +1,31 @@
+ALTER TABLE contract
+    ADD COLUMN daily_overtime_minutes INT CHECK (daily_overtime_minutes > 0),
+    ADD COLUMN weekly_overtime_minutes INT CHECK (weekly_overtime_minutes > 0),
+    ADD COLUMN overtime_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (overtime_multiplier >= 1);
+
+-- a premium for the time between start_minute and end_minute (minutes after
+-- midnight, wrapping past midnight when start > end) on the days in
+-- days_mask (bit 0 is Sunday), or on public holidays for kind holiday
+CREATE TABLE pay_rule (
+    id SERIAL PRIMARY KEY,
+    contract_id INT NOT NULL,
+    name TEXT NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'window' CHECK (kind IN ('window', 'holiday')),
+    days_mask INT NOT NULL DEFAULT 127 CHECK (days_mask BETWEEN 0 AND 127),
+    start_minute INT NOT NULL DEFAULT 0 CHECK (start_minute BETWEEN 0 AND 1440),
+    end_minute INT NOT NULL DEFAULT 1440 CHECK (end_minute BETWEEN 0 AND 1440),
+    multiplier DOUBLE PRECISION NOT NULL CHECK (multiplier >= 1),
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (contract_id) REFERENCES contract(id) ON DELETE CASCADE
+);
+
+CREATE TABLE holiday (
+    id SERIAL PRIMARY KEY,
+    workspace_id INT NOT NULL,
+    date DATE NOT NULL,
+    name TEXT NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, date),
+    FOREIGN KEY (workspace_id) REFERENCES workspace(id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+ALTER TABLE contract
+    ADD COLUMN hourly_rate INT,
+    ADD COLUMN unpaid_lunch_minutes INT,
+    ADD COLUMN daily_overtime_minutes INT CHECK (daily_overtime_minutes > 0),
+    ADD COLUMN weekly_overtime_minutes INT CHECK (weekly_overtime_minutes > 0),
+    ADD COLUMN overtime_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (overtime_multiplier >= 1);
+
+UPDATE contract ct
+SET
+    hourly_rate = cv.hourly_rate,
+    unpaid_lunch_minutes = cv.unpaid_lunch_minutes,
+    daily_overtime_minutes = cv.daily_overtime_minutes,
+    weekly_overtime_minutes = cv.weekly_overtime_minutes,
+    overtime_multiplier = cv.overtime_multiplier
+FROM contract_version cv
+WHERE cv.id = (SELECT id FROM contract_version_at(ct.id, now()));
+
+DROP FUNCTION IF EXISTS contract_version_at(INT, TIMESTAMPTZ);
+DROP TABLE IF EXISTS contract_version;
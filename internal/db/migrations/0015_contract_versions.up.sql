@@ -0,0 +1,46 @@
+-- the terms of a contract are versioned so that a change only applies to
+-- shifts that start after it takes effect
+CREATE TABLE contract_version (
+    id SERIAL PRIMARY KEY,
+    contract_id INT NOT NULL,
+    effective_from TIMESTAMPTZ NOT NULL,
+    hourly_rate INT NOT NULL DEFAULT 0,
+    unpaid_lunch_minutes INT NOT NULL DEFAULT 0,
+    daily_overtime_minutes INT CHECK (daily_overtime_minutes > 0),
+    weekly_overtime_minutes INT CHECK (weekly_overtime_minutes > 0),
+    overtime_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (overtime_multiplier >= 1),
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (contract_id, effective_from),
+    FOREIGN KEY (contract_id) REFERENCES contract(id) ON DELETE CASCADE
+);
+
+INSERT INTO contract_version (
+    contract_id, effective_from, hourly_rate, unpaid_lunch_minutes,
+    daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+)
+SELECT
+    id, created, COALESCE(hourly_rate, 0), COALESCE(unpaid_lunch_minutes, 0),
+    daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+FROM contract;
+
+ALTER TABLE contract
+    DROP COLUMN hourly_rate,
+    DROP COLUMN unpaid_lunch_minutes,
+    DROP COLUMN daily_overtime_minutes,
+    DROP COLUMN weekly_overtime_minutes,
+    DROP COLUMN overtime_multiplier;
+
+-- the version of a contract in force at p_at. Before the first version takes
+-- effect the first one applies, so shifts from before a contract was set up
+-- are still paid on its terms.
+CREATE FUNCTION contract_version_at(p_contract_id INT, p_at TIMESTAMPTZ)
+RETURNS SETOF contract_version AS $$
+    SELECT *
+    FROM contract_version
+    WHERE contract_id = p_contract_id
+    ORDER BY
+        effective_from <= p_at DESC,
+        CASE WHEN effective_from <= p_at THEN effective_from END DESC,
+        effective_from
+    LIMIT 1
+$$ LANGUAGE sql STABLE;
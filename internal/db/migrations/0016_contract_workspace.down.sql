@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS contract_workspace;
+
+ALTER TABLE contract
+    DROP COLUMN IF EXISTS workspace_id;
@@ -0,0 +1,18 @@
+-- contracts belong to a workspace so managers only see their own. Existing
+-- contracts go to the workspace of a company they are used at, ones that
+-- aren't used anywhere are left without a workspace and only reachable from
+-- kronosctl.
+ALTER TABLE contract
+    ADD COLUMN workspace_id INT REFERENCES workspace(id) ON DELETE CASCADE;
+
+UPDATE contract ct
+SET workspace_id = (
+    SELECT c.workspace_id
+    FROM employment e
+    JOIN company c ON c.id = e.company_id
+    WHERE e.contract_id = ct.id
+    ORDER BY e.id
+    LIMIT 1
+);
+
+CREATE INDEX contract_workspace ON contract (workspace_id);
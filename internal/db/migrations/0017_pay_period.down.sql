@@ -0,0 +1,2 @@
+ALTER TABLE workspace
+    DROP COLUMN IF EXISTS pay_period_start_day;
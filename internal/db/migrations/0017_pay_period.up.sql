@@ -0,0 +1,6 @@
+-- pay periods run from this day of one month up to the same day of the next.
+-- Days past 28 would skip February, so they aren't allowed.
+ALTER TABLE workspace
+    ADD COLUMN pay_period_start_day INT NOT NULL DEFAULT 1
+    CONSTRAINT pay_period_start_day_range
+    CHECK (pay_period_start_day BETWEEN 1 AND 28);
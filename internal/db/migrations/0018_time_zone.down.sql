@@ -0,0 +1,2 @@
+ALTER TABLE workspace
+    DROP COLUMN IF EXISTS time_zone;
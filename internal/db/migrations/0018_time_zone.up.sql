@@ -0,0 +1,4 @@
+-- days, weeks, months and pay periods of a workspace are worked out in its
+-- time zone. The name is checked against the IANA database by the API.
+ALTER TABLE workspace
+    ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'UTC';
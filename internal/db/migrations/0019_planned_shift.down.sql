@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS one_shift_per_planned_occurrence;
+
+ALTER TABLE shift
+    DROP COLUMN IF EXISTS planned_shift_id,
+    DROP COLUMN IF EXISTS planned_start_ts;
+
+DROP TABLE IF EXISTS planned_shift;
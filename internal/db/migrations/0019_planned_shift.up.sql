@@ -0,0 +1,32 @@
+-- shifts on the roster. start_ts and end_ts are the first occurrence,
+-- recurring ones repeat at the same local time in the workspace's time zone
+-- every day or week, up to and including the date repeat_until, or forever.
+-- Whether an occurrence ends before the next one starts depends on the time
+-- zone, since a day is 23 or 25 hours long across DST changes, so it is
+-- checked by the application
+CREATE TABLE planned_shift (
+    id SERIAL PRIMARY KEY,
+    profile_id INT NOT NULL,
+    task_id INT NOT NULL,
+    start_ts TIMESTAMPTZ NOT NULL,
+    end_ts TIMESTAMPTZ NOT NULL,
+    recurrence VARCHAR(20) NOT NULL DEFAULT 'none' CHECK (recurrence IN ('none', 'daily', 'weekly')),
+    repeat_until DATE,
+    note TEXT,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT planned_shift_ends_after_start CHECK (end_ts > start_ts),
+    FOREIGN KEY (profile_id) REFERENCES profile(id) ON DELETE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES task(id) ON DELETE CASCADE
+);
+
+CREATE INDEX planned_shift_profile ON planned_shift (profile_id, start_ts);
+
+-- the occurrence of a planned shift an actual shift was clocked in for
+ALTER TABLE shift
+    ADD COLUMN planned_shift_id INT REFERENCES planned_shift(id) ON DELETE SET NULL,
+    ADD COLUMN planned_start_ts TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX one_shift_per_planned_occurrence
+    ON shift (planned_shift_id, planned_start_ts)
+    WHERE planned_shift_id IS NOT NULL;
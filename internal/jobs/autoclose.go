@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// CloseOverdueShifts ends every open shift that has run past the maximum
+// shift length of its workspace. The shift is ended at start + max length,
+// marked auto_closed for review, and when the workspace asks for it a
+// pending edit request is opened so the worker can put in the real end time.
+func CloseOverdueShifts(
+	ctx context.Context,
+	db *sql.DB,
+) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("CloseOverdueShifts: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	type closedShift struct {
+		id              int
+		end_ts          time.Time
+		maxMinutes      int
+		wantEditRequest bool
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`
+		UPDATE shift s
+		SET end_ts = s.start_ts + make_interval(mins => w.max_shift_minutes),
+			auto_closed = TRUE,
+			updated = now()
+		FROM task t
+		JOIN location l ON l.id = t.location_id
+		JOIN workspace w ON w.id = l.workspace_id
+		WHERE t.id = s.task_id
+		AND s.end_ts IS NULL
+		AND w.max_shift_minutes IS NOT NULL
+		AND s.start_ts + make_interval(mins => w.max_shift_minutes) <= now()
+		RETURNING s.id, s.end_ts, w.max_shift_minutes, w.auto_close_edit_request
+		`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("CloseOverdueShifts: db update: %w", err)
+	}
+
+	closed := []closedShift{}
+	for rows.Next() {
+		var shift closedShift
+		err = rows.Scan(
+			&shift.id,
+			&shift.end_ts,
+			&shift.maxMinutes,
+			&shift.wantEditRequest,
+		)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("CloseOverdueShifts: db scan: %w", err)
+		}
+		closed = append(closed, shift)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("CloseOverdueShifts: rows: %w", err)
+	}
+
+	for _, shift := range closed {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			UPDATE shift_break
+			SET end_ts = GREATEST(start_ts, $1), updated = now()
+			WHERE shift_id = $2
+			AND end_ts IS NULL
+			`,
+			shift.end_ts,
+			shift.id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("CloseOverdueShifts: end break: %w", err)
+		}
+
+		if !shift.wantEditRequest {
+			continue
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			INSERT INTO edit_request (shift_id, profile_id, reason)
+			SELECT id, profile_id, $2
+			FROM shift
+			WHERE id = $1
+			`,
+			shift.id,
+			fmt.Sprintf(
+				"Shift was closed automatically after %d minutes, please correct the end time",
+				shift.maxMinutes,
+			),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("CloseOverdueShifts: insert edit request: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CloseOverdueShifts: db commit: %w", err)
+	}
+
+	return len(closed), nil
+}
+
+// RunAutoCloser runs CloseOverdueShifts every interval until ctx is done.
+func RunAutoCloser(
+	ctx context.Context,
+	db *sql.DB,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := CloseOverdueShifts(ctx, db)
+		if err != nil {
+			log.Printf("auto close: %v", err)
+		} else if count > 0 {
+			log.Printf("auto close: closed %d overdue shifts", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// insertContractVersion adds a version of the contract taking effect at
+// effective_from. Fields missing from the patch are carried over from the
+// version in force at that time, and a version already starting then is
+// replaced. The patched fields are carried forward into the versions
+// scheduled after it as well, except where those change the field
+// themselves, so a scheduled version doesn't undo the patch when it takes
+// effect.
+func insertContractVersion(
+	ctx context.Context,
+	tx *sql.Tx,
+	contract_id int,
+	effective_from time.Time,
+	patch ContractPatch,
+) (*model.Contract, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if patch.HourlyRate == nil &&
+		patch.UnpaidLunchMinutes == nil &&
+		patch.DailyOvertimeMinutes == nil &&
+		patch.WeeklyOvertimeMinutes == nil &&
+		patch.OvertimeMultiplier == nil {
+		return nil, ErrNoFieldsToUpdate
+	}
+
+	// the terms the later versions were carried over from
+	var previous model.Contract
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT hourly_rate, unpaid_lunch_minutes, daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+		FROM contract_version_at($1, $2)
+		`,
+		contract_id,
+		effective_from,
+	).Scan(
+		&previous.HourlyRate,
+		&previous.UnpaidLunchMinutes,
+		&previous.DailyOvertimeMinutes,
+		&previous.WeeklyOvertimeMinutes,
+		&previous.OvertimeMultiplier,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("insertContractVersion: db select: %w", err)
+	}
+
+	var contract model.Contract
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO contract_version (
+			contract_id, effective_from, hourly_rate, unpaid_lunch_minutes,
+			daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+		)
+		SELECT
+			$1,
+			$2,
+			COALESCE($3, cv.hourly_rate),
+			COALESCE($4, cv.unpaid_lunch_minutes),
+			CASE WHEN $5::int IS NULL THEN cv.daily_overtime_minutes ELSE NULLIF($5, 0) END,
+			CASE WHEN $6::int IS NULL THEN cv.weekly_overtime_minutes ELSE NULLIF($6, 0) END,
+			COALESCE($7, cv.overtime_multiplier)
+		FROM contract_version_at($1, $2) cv
+		WHERE $1 IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(8)+`)
+		)
+		ON CONFLICT (contract_id, effective_from) DO UPDATE SET
+			hourly_rate = EXCLUDED.hourly_rate,
+			unpaid_lunch_minutes = EXCLUDED.unpaid_lunch_minutes,
+			daily_overtime_minutes = EXCLUDED.daily_overtime_minutes,
+			weekly_overtime_minutes = EXCLUDED.weekly_overtime_minutes,
+			overtime_multiplier = EXCLUDED.overtime_multiplier
+		RETURNING
+			contract_id, id, effective_from, hourly_rate, unpaid_lunch_minutes,
+			daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+		`,
+		contract_id,
+		effective_from,
+		patch.HourlyRate,
+		patch.UnpaidLunchMinutes,
+		patch.DailyOvertimeMinutes,
+		patch.WeeklyOvertimeMinutes,
+		patch.OvertimeMultiplier,
+		claims.ProfileID,
+	).Scan(
+		&contract.Id,
+		&contract.VersionId,
+		&contract.EffectiveFrom,
+		&contract.HourlyRate,
+		&contract.UnpaidLunchMinutes,
+		&contract.DailyOvertimeMinutes,
+		&contract.WeeklyOvertimeMinutes,
+		&contract.OvertimeMultiplier,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrContractNotFound
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23514" {
+			return nil, ErrInvalidOvertime
+		}
+		return nil, fmt.Errorf("insertContractVersion: db insert: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE contract_version SET
+			hourly_rate = CASE
+				WHEN $3::int IS NOT NULL AND hourly_rate = $8 THEN $3
+				ELSE hourly_rate
+			END,
+			unpaid_lunch_minutes = CASE
+				WHEN $4::int IS NOT NULL AND unpaid_lunch_minutes = $9 THEN $4
+				ELSE unpaid_lunch_minutes
+			END,
+			daily_overtime_minutes = CASE
+				WHEN $5::int IS NOT NULL AND daily_overtime_minutes IS NOT DISTINCT FROM $10::int THEN NULLIF($5, 0)
+				ELSE daily_overtime_minutes
+			END,
+			weekly_overtime_minutes = CASE
+				WHEN $6::int IS NOT NULL AND weekly_overtime_minutes IS NOT DISTINCT FROM $11::int THEN NULLIF($6, 0)
+				ELSE weekly_overtime_minutes
+			END,
+			overtime_multiplier = CASE
+				WHEN $7::float8 IS NOT NULL AND overtime_multiplier = $12 THEN $7
+				ELSE overtime_multiplier
+			END
+		WHERE contract_id = $1
+		AND effective_from > $2
+		`,
+		contract_id,
+		effective_from,
+		patch.HourlyRate,
+		patch.UnpaidLunchMinutes,
+		patch.DailyOvertimeMinutes,
+		patch.WeeklyOvertimeMinutes,
+		patch.OvertimeMultiplier,
+		previous.HourlyRate,
+		previous.UnpaidLunchMinutes,
+		previous.DailyOvertimeMinutes,
+		previous.WeeklyOvertimeMinutes,
+		previous.OvertimeMultiplier,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insertContractVersion: db update later versions: %w", err)
+	}
+
+	return &contract, nil
+}
+
+// ScheduleContractVersion schedules a change to the terms of a contract. Only
+// future changes can be scheduled, past pay is never rewritten.
+func ScheduleContractVersion(
+	ctx context.Context,
+	db *sql.DB,
+	contract_id int,
+	input ContractVersionCreate,
+) (*model.Contract, error) {
+	if !input.EffectiveFrom.After(time.Now()) {
+		return nil, ErrEffectiveFromInPast
+	}
+	if err := validateOvertime(input.DailyOvertimeMinutes, input.WeeklyOvertimeMinutes, input.OvertimeMultiplier); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ScheduleContractVersion: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	contract, err := insertContractVersion(ctx, tx, contract_id, input.EffectiveFrom, input.ContractPatch)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ScheduleContractVersion: db commit: %w", err)
+	}
+
+	return contract, nil
+}
+
+// GetContractVersions lists every version of a contract, past and scheduled,
+// oldest first.
+func GetContractVersions(
+	ctx context.Context,
+	db *sql.DB,
+	contract_id int,
+) (*[]model.Contract, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	versions := []model.Contract{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT
+			contract_id, id, effective_from, hourly_rate, unpaid_lunch_minutes,
+			daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+		FROM contract_version
+		WHERE contract_id = $1
+		AND contract_id IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(2)+`)
+		)
+		ORDER BY effective_from
+		`,
+		contract_id,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetContractVersions: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version model.Contract
+		err = rows.Scan(
+			&version.Id,
+			&version.VersionId,
+			&version.EffectiveFrom,
+			&version.HourlyRate,
+			&version.UnpaidLunchMinutes,
+			&version.DailyOvertimeMinutes,
+			&version.WeeklyOvertimeMinutes,
+			&version.OvertimeMultiplier,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("GetContractVersions: db scan: %w", err)
+		}
+
+		versions = append(versions, version)
+	}
+
+	// every contract is created with a version, so none means the contract
+	// doesn't exist or isn't the caller's
+	if len(versions) == 0 {
+		return nil, ErrContractNotFound
+	}
+
+	return &versions, nil
+}
+
+// DeleteContractVersion cancels a scheduled change. Versions that have taken
+// effect can't be removed.
+func DeleteContractVersion(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	result, err := db.ExecContext(
+		ctx,
+		`
+		DELETE FROM contract_version
+		WHERE id = $1
+		AND effective_from > now()
+		AND contract_id IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(2)+`)
+		)
+		`,
+		id,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("DeleteContractVersion: db delete: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("DeleteContractVersion: rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		var exists bool
+		err = db.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1 FROM contract_version
+				WHERE id = $1
+				AND contract_id IN (
+					SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(2)+`)
+				)
+			)
+			`,
+			id,
+			claims.ProfileID,
+		).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("DeleteContractVersion: db select: %w", err)
+		}
+		if exists {
+			return 0, ErrContractVersionInEffect
+		}
+		return 0, ErrContractVersionNotFound
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,54 @@
+package manage
+
+import (
+	"test/internal/db/dbtest"
+	"testing"
+	"time"
+)
+
+func TestPatchContractCarriesIntoScheduledVersions(t *testing.T) {
+	db := dbtest.Open(t)
+	tn := newTenant(t, db, 1)
+
+	scheduled := time.Now().AddDate(0, 1, 0)
+	raise := 120
+	_, err := ScheduleContractVersion(tn.ctx, db, tn.contract_id, ContractVersionCreate{
+		EffectiveFrom: scheduled,
+		ContractPatch: ContractPatch{HourlyRate: &raise},
+	})
+	if err != nil {
+		t.Fatalf("ScheduleContractVersion: %v", err)
+	}
+
+	rate, lunch := 110, 30
+	_, err = PatchContract(tn.ctx, db, tn.contract_id, ContractPatch{HourlyRate: &rate, UnpaidLunchMinutes: &lunch})
+	if err != nil {
+		t.Fatalf("PatchContract: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		rate  int
+		lunch int
+	}{
+		// the patch is in force until the raise
+		{"before the scheduled version", time.Now().Add(time.Hour), 110, 30},
+		// the raise still applies, and the patched lunch isn't undone by it
+		{"after the scheduled version", scheduled.AddDate(0, 0, 1), 120, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rate, lunch int
+			err := db.QueryRow(`
+				SELECT hourly_rate, unpaid_lunch_minutes
+				FROM contract_version_at($1, $2)`, tn.contract_id, tt.at).Scan(&rate, &lunch)
+			if err != nil {
+				t.Fatalf("contract_version_at: %v", err)
+			}
+			if rate != tt.rate || lunch != tt.lunch {
+				t.Errorf("got rate %d lunch %d, want rate %d lunch %d", rate, lunch, tt.rate, tt.lunch)
+			}
+		})
+	}
+}
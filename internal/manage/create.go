@@ -3,8 +3,12 @@ package manage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"test/internal/auth"
 	"test/internal/model"
+
+	"github.com/lib/pq"
 )
 
 func CreateWorkspace(
@@ -12,31 +16,73 @@ func CreateWorkspace(
 	db *sql.DB,
 	input WorkspaceCreate,
 ) (*model.Workspace, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("CreateWorkspace: begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	workspace, err := InsertWorkspace(ctx, tx, input, claims.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateWorkspace: db commit: %w", err)
+	}
+
+	return workspace, nil
+}
+
+// InsertWorkspace adds the workspace in tx, owned by the profile owner_id.
+func InsertWorkspace(
+	ctx context.Context,
+	tx *sql.Tx,
+	input WorkspaceCreate,
+	owner_id int,
+) (*model.Workspace, error) {
 	var workspace model.Workspace
-	err = tx.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		`
-		INSERT INTO workspace (name)
-		VALUES ($1)
-		RETURNING id, name
+		INSERT INTO workspace (name, geofence_policy, max_shift_minutes, auto_close_edit_request, pay_period_start_day, time_zone)
+		VALUES ($1, COALESCE($2, 'ignore'), NULLIF($3, 0), $4, COALESCE($5, 1), COALESCE($6, 'UTC'))
+		RETURNING id, name, geofence_policy, max_shift_minutes, auto_close_edit_request, pay_period_start_day, time_zone
 		`,
 		input.Name,
+		input.GeofencePolicy,
+		input.MaxShiftMinutes,
+		input.AutoCloseEditRequest,
+		input.PayPeriodStartDay,
+		input.TimeZone,
 	).Scan(
 		&workspace.Id,
 		&workspace.Name,
+		&workspace.GeofencePolicy,
+		&workspace.MaxShiftMinutes,
+		&workspace.AutoCloseEditRequest,
+		&workspace.PayPeriodStartDay,
+		&workspace.TimeZone,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("CreateWorkspace: db insert: %w", err)
+		return nil, fmt.Errorf("InsertWorkspace: db insert: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("CreateWorkspace: db commit: %w", err)
+	// the owner is bound to the workspace rather than any of its companies,
+	// has no contract and doesn't run out
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		INSERT INTO employment (profile_id, workspace_id, role, end_date)
+		VALUES ($1, $2, 'owner', NULL)
+		`,
+		owner_id,
+		workspace.Id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("InsertWorkspace: db insert employment: %w", err)
 	}
 
 	return &workspace, nil
@@ -47,33 +93,56 @@ func CreateCompany(
 	db *sql.DB,
 	input CompanyCreate,
 ) (*model.Company, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("CreateCompany: begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	company, err := InsertCompany(ctx, tx, input, claims.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateCompany: db commit: %w", err)
+	}
+
+	return company, nil
+}
+
+// InsertCompany adds the company in tx to a workspace the profile manager_id
+// manages.
+func InsertCompany(
+	ctx context.Context,
+	tx *sql.Tx,
+	input CompanyCreate,
+	manager_id int,
+) (*model.Company, error) {
 	var company model.Company
-	err = tx.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		`
 		INSERT INTO company (name, workspace_id)
-		VALUES ($1, $2)
+		SELECT $1, $2::int
+		WHERE $2 IN (`+managedWorkspaces(3)+`)
 		RETURNING id, name, workspace_id
 		`,
 		input.Name,
 		input.WorkspaceId,
+		manager_id,
 	).Scan(
 		&company.Id,
 		&company.Name,
 		&company.WorkspaceId,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("CreateCompany: db insert: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("CreateCompany: db commit: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("InsertCompany: db insert: %w", err)
 	}
 
 	return &company, nil
@@ -84,6 +153,8 @@ func CreateLocation(
 	db *sql.DB,
 	input LocationCreate,
 ) (*model.Location, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("CreateLocation: begin tx: %w", err)
@@ -94,20 +165,31 @@ func CreateLocation(
 	err = tx.QueryRowContext(
 		ctx,
 		`
-		INSERT INTO location (name, address, workspace_id)
-		VALUES ($1, $2, $3)
-		RETURNING id, name, address, workspace_id
+		INSERT INTO location (name, address, workspace_id, latitude, longitude, radius_m)
+		SELECT $1, $2, $3::int, $4::float8, $5::float8, $6::int
+		WHERE $3 IN (`+managedWorkspaces(7)+`)
+		RETURNING id, name, address, workspace_id, latitude, longitude, radius_m
 		`,
 		input.Name,
 		input.Address,
 		input.WorkspaceId,
+		input.Latitude,
+		input.Longitude,
+		input.RadiusM,
+		claims.ProfileID,
 	).Scan(
 		&location.Id,
 		&location.Name,
 		&location.Address,
 		&location.WorkspaceId,
+		&location.Latitude,
+		&location.Longitude,
+		&location.RadiusM,
 	)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
 		return nil, fmt.Errorf("CreateLocation: db insert: %w", err)
 	}
 
@@ -123,12 +205,42 @@ func CreateTask(
 	db *sql.DB,
 	input TaskCreate,
 ) (*model.Task, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("CreateTask: begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	// the location has to be in the same workspace as the company
+	var company_ok, location_ok bool
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT
+			$1 IN (`+managedCompanies(3)+`),
+			EXISTS (
+				SELECT 1
+				FROM location l
+				JOIN company c ON c.workspace_id = l.workspace_id
+				WHERE l.id = $2 AND c.id = $1
+			)
+		`,
+		input.CompanyId,
+		input.LocationId,
+		claims.ProfileID,
+	).Scan(&company_ok, &location_ok)
+	if err != nil {
+		return nil, fmt.Errorf("CreateTask: db select: %w", err)
+	}
+	if !company_ok {
+		return nil, ErrCompanyNotFound
+	}
+	if !location_ok {
+		return nil, ErrLocationNotFound
+	}
+
 	var task model.Task
 	err = tx.QueryRowContext(
 		ctx,
@@ -161,17 +273,127 @@ func CreateTask(
 	return &task, nil
 }
 
+// CreateEmployment employs a profile at one of the caller's companies. The
+// profile has to be the caller or already employed at a company the caller
+// manages, so profiles of other tenants can't be attached by id. New people
+// are hired with CreateProfile.
 func CreateEmployment(
 	ctx context.Context,
 	db *sql.DB,
 	input EmploymentCreate,
 ) (*model.Employment, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("CreateEmployment: begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	var profile_ok bool
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT $1 = $2 OR $1 IN (`+managedProfiles(2)+`)
+		`,
+		input.ProfileId,
+		claims.ProfileID,
+	).Scan(&profile_ok)
+	if err != nil {
+		return nil, fmt.Errorf("CreateEmployment: db select: %w", err)
+	}
+	if !profile_ok {
+		return nil, ErrProfileNotFound
+	}
+
+	employment, err := insertEmployment(ctx, tx, input, claims.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateEmployment: db commit: %w", err)
+	}
+
+	return employment, nil
+}
+
+// CreateProfile adds a profile employed at one of the caller's companies.
+// Profiles are always created with an employment, one without any would
+// belong to no tenant and could be attached by anyone.
+func CreateProfile(
+	ctx context.Context,
+	db *sql.DB,
+	input ProfileHire,
+) (*HiredProfile, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreateProfile: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	profile, err := auth.InsertProfile(ctx, tx, input.ProfileCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	employment, err := insertEmployment(ctx, tx, EmploymentCreate{
+		ProfileId:  profile.ID,
+		CompanyId:  input.CompanyId,
+		ContractId: input.ContractId,
+		Role:       input.Role,
+	}, claims.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateProfile: db commit: %w", err)
+	}
+
+	return &HiredProfile{Profile: *profile, Employment: *employment}, nil
+}
+
+// insertEmployment adds the employment after checking that the manager
+// manages its company, may grant its role and that the contract is in the
+// company's workspace.
+func insertEmployment(
+	ctx context.Context,
+	tx *sql.Tx,
+	input EmploymentCreate,
+	manager_id int,
+) (*model.Employment, error) {
+	manager_role, err := callerRole(ctx, tx, input.CompanyId, manager_id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGrantableRole(input.Role, manager_role); err != nil {
+		return nil, err
+	}
+
+	var contract_ok bool
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT EXISTS (
+			SELECT 1
+			FROM contract ct
+			JOIN company c ON c.workspace_id = ct.workspace_id
+			WHERE ct.id = $2 AND c.id = $1
+		)
+		`,
+		input.CompanyId,
+		input.ContractId,
+	).Scan(&contract_ok)
+	if err != nil {
+		return nil, fmt.Errorf("insertEmployment: db select: %w", err)
+	}
+	if !contract_ok {
+		return nil, ErrContractNotFound
+	}
+
 	var employment model.Employment
 	err = tx.QueryRowContext(
 		ctx,
@@ -192,11 +414,11 @@ func CreateEmployment(
 		&employment.Role,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("CreateEmployment: db insert: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("CreateEmployment: db commit: %w", err)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrProfileNotFound
+		}
+		return nil, fmt.Errorf("insertEmployment: db insert: %w", err)
 	}
 
 	return &employment, nil
@@ -207,6 +429,8 @@ func CreateContract(
 	db *sql.DB,
 	input ContractCreate,
 ) (*model.Contract, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("CreateContract: begin tx: %w", err)
@@ -217,19 +441,48 @@ func CreateContract(
 	err = tx.QueryRowContext(
 		ctx,
 		`
-		INSERT INTO contract (hourly_rate, unpaid_lunch_minutes)
-		VALUES ($1, $2)
-		RETURNING id, hourly_rate, unpaid_lunch_minutes
+		INSERT INTO contract (workspace_id)
+		SELECT $1::int
+		WHERE $1 IN (`+managedWorkspaces(2)+`)
+		RETURNING id, workspace_id
+		`,
+		input.WorkspaceId,
+		claims.ProfileID,
+	).Scan(&contract.Id, &contract.WorkspaceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("CreateContract: db insert: %w", err)
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO contract_version (
+			contract_id, effective_from, hourly_rate, unpaid_lunch_minutes,
+			daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+		)
+		VALUES ($1, now(), $2, $3, NULLIF($4, 0), NULLIF($5, 0), COALESCE($6, 1))
+		RETURNING id, effective_from, hourly_rate, unpaid_lunch_minutes, daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
 		`,
+		contract.Id,
 		input.HourlyRate,
 		input.UnpaidLunchMinutes,
+		input.DailyOvertimeMinutes,
+		input.WeeklyOvertimeMinutes,
+		input.OvertimeMultiplier,
 	).Scan(
-		&contract.Id,
+		&contract.VersionId,
+		&contract.EffectiveFrom,
 		&contract.HourlyRate,
 		&contract.UnpaidLunchMinutes,
+		&contract.DailyOvertimeMinutes,
+		&contract.WeeklyOvertimeMinutes,
+		&contract.OvertimeMultiplier,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("CreateContract: db insert: %w", err)
+		return nil, fmt.Errorf("CreateContract: db insert version: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -238,3 +491,159 @@ func CreateContract(
 
 	return &contract, nil
 }
+
+func CreateBreakType(
+	ctx context.Context,
+	db *sql.DB,
+	input BreakTypeCreate,
+) (*model.BreakType, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreateBreakType: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var breakType model.BreakType
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO break_type (contract_id, name, paid, max_minutes)
+		SELECT $1::int, $2, $3::boolean, $4::int
+		WHERE $1 IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(5)+`)
+		)
+		RETURNING id, contract_id, name, paid, max_minutes
+		`,
+		input.ContractId,
+		input.Name,
+		input.Paid,
+		input.MaxMinutes,
+		claims.ProfileID,
+	).Scan(
+		&breakType.Id,
+		&breakType.ContractId,
+		&breakType.Name,
+		&breakType.Paid,
+		&breakType.MaxMinutes,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrContractNotFound
+		}
+		return nil, fmt.Errorf("CreateBreakType: db insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateBreakType: db commit: %w", err)
+	}
+
+	return &breakType, nil
+}
+
+func CreatePayRule(
+	ctx context.Context,
+	db *sql.DB,
+	input PayRuleCreate,
+) (*model.PayRule, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePayRule: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rule model.PayRule
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO pay_rule (contract_id, name, kind, days_mask, start_minute, end_minute, multiplier)
+		SELECT $1::int, $2, COALESCE(NULLIF($3, ''), 'window'), COALESCE($4, 127), COALESCE($5, 0), COALESCE($6, 1440), $7::float8
+		WHERE $1 IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(8)+`)
+		)
+		RETURNING id, contract_id, name, kind, days_mask, start_minute, end_minute, multiplier
+		`,
+		input.ContractId,
+		input.Name,
+		input.Kind,
+		input.DaysMask,
+		input.StartMinute,
+		input.EndMinute,
+		input.Multiplier,
+		claims.ProfileID,
+	).Scan(
+		&rule.Id,
+		&rule.ContractId,
+		&rule.Name,
+		&rule.Kind,
+		&rule.DaysMask,
+		&rule.StartMinute,
+		&rule.EndMinute,
+		&rule.Multiplier,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrContractNotFound
+		}
+		return nil, fmt.Errorf("CreatePayRule: db insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreatePayRule: db commit: %w", err)
+	}
+
+	return &rule, nil
+}
+
+func CreateHoliday(
+	ctx context.Context,
+	db *sql.DB,
+	input HolidayCreate,
+) (*model.Holiday, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreateHoliday: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var holiday model.Holiday
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO holiday (workspace_id, date, name)
+		SELECT $1::int, $2::date, $3
+		WHERE $1 IN (`+managedWorkspaces(4)+`)
+		RETURNING id, workspace_id, to_char(date, 'YYYY-MM-DD'), name
+		`,
+		input.WorkspaceId,
+		input.Date,
+		input.Name,
+		claims.ProfileID,
+	).Scan(
+		&holiday.Id,
+		&holiday.WorkspaceId,
+		&holiday.Date,
+		&holiday.Name,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrHolidayExists
+		}
+		return nil, fmt.Errorf("CreateHoliday: db insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreateHoliday: db commit: %w", err)
+	}
+
+	return &holiday, nil
+}
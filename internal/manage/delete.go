@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"test/internal/auth"
 )
 
 func DeleteWorkspace(
@@ -11,12 +12,17 @@ func DeleteWorkspace(
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM workspace WHERE id = $1
+		DELETE FROM workspace
+		WHERE id = $1
+		AND id IN (`+ownedWorkspaces(2)+`)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteWorkspace: db delete: %w", err)
@@ -27,6 +33,10 @@ func DeleteWorkspace(
 		return 0, fmt.Errorf("DeleteWorkspace: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		return 0, ErrWorkspaceNotFound
+	}
+
 	return rows, nil
 }
 
@@ -35,12 +45,17 @@ func DeleteCompany(
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM company WHERE id = $1
+		DELETE FROM company
+		WHERE id = $1
+		AND workspace_id IN (`+ownedWorkspaces(2)+`)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteCompany: db delete: %w", err)
@@ -51,6 +66,10 @@ func DeleteCompany(
 		return 0, fmt.Errorf("DeleteCompany: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		return 0, ErrCompanyNotFound
+	}
+
 	return rows, nil
 }
 
@@ -59,12 +78,17 @@ func DeleteLocation(
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM location WHERE id = $1
+		DELETE FROM location
+		WHERE id = $1
+		AND workspace_id IN (`+managedWorkspaces(2)+`)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteLocation: db delete: %w", err)
@@ -75,6 +99,10 @@ func DeleteLocation(
 		return 0, fmt.Errorf("DeleteLocation: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		return 0, ErrLocationNotFound
+	}
+
 	return rows, nil
 }
 
@@ -83,12 +111,17 @@ func DeleteTask(
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM task WHERE id = $1
+		DELETE FROM task
+		WHERE id = $1
+		AND company_id IN (`+managedCompanies(2)+`)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteTask: db delete: %w", err)
@@ -99,20 +132,37 @@ func DeleteTask(
 		return 0, fmt.Errorf("DeleteTask: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		return 0, ErrTaskNotFound
+	}
+
 	return rows, nil
 }
 
+// DeleteProfile deletes a profile employed at a company the caller manages.
+// Profiles also employed elsewhere are left alone, they aren't the caller's
+// to delete.
 func DeleteProfile(
 	ctx context.Context,
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM profile WHERE id = $1
+		DELETE FROM profile
+		WHERE id = $1
+		AND id IN (`+managedProfiles(2)+`)
+		AND NOT EXISTS (
+			SELECT 1 FROM employment
+			WHERE profile_id = $1
+			AND (company_id IS NULL OR company_id NOT IN (`+managedCompanies(2)+`))
+		)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteProfile: db delete: %w", err)
@@ -123,6 +173,25 @@ func DeleteProfile(
 		return 0, fmt.Errorf("DeleteProfile: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		var managed bool
+		err = db.QueryRowContext(
+			ctx,
+			`
+			SELECT $1 IN (`+managedProfiles(2)+`)
+			`,
+			id,
+			claims.ProfileID,
+		).Scan(&managed)
+		if err != nil {
+			return 0, fmt.Errorf("DeleteProfile: db select: %w", err)
+		}
+		if managed {
+			return 0, ErrProfileShared
+		}
+		return 0, ErrProfileNotFound
+	}
+
 	return rows, nil
 }
 
@@ -131,12 +200,17 @@ func DeleteContract(
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM contract WHERE id = $1
+		DELETE FROM contract
+		WHERE id = $1
+		AND workspace_id IN (`+ownedWorkspaces(2)+`)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteContract: db delete: %w", err)
@@ -147,6 +221,45 @@ func DeleteContract(
 		return 0, fmt.Errorf("DeleteContract: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		return 0, ErrContractNotFound
+	}
+
+	return rows, nil
+}
+
+func DeleteBreakType(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	result, err := db.ExecContext(
+		ctx,
+		`
+		DELETE FROM break_type
+		WHERE id = $1
+		AND contract_id IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(2)+`)
+		)
+		`,
+		id,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("DeleteBreakType: db delete: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("DeleteBreakType: rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return 0, ErrBreakTypeNotFound
+	}
+
 	return rows, nil
 }
 
@@ -155,12 +268,19 @@ func DeleteShift(
 	db *sql.DB,
 	id int,
 ) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	result, err := db.ExecContext(
 		ctx,
 		`
-		DELETE FROM shift WHERE id = $1
+		DELETE FROM shift
+		WHERE id = $1
+		AND task_id IN (
+			SELECT id FROM task WHERE company_id IN (`+managedCompanies(2)+`)
+		)
 		`,
 		id,
+		claims.ProfileID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("DeleteShift: db delete: %w", err)
@@ -171,5 +291,77 @@ func DeleteShift(
 		return 0, fmt.Errorf("DeleteShift: rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		return 0, ErrShiftNotFound
+	}
+
+	return rows, nil
+}
+
+func DeletePayRule(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	result, err := db.ExecContext(
+		ctx,
+		`
+		DELETE FROM pay_rule
+		WHERE id = $1
+		AND contract_id IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(2)+`)
+		)
+		`,
+		id,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("DeletePayRule: db delete: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("DeletePayRule: rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return 0, ErrPayRuleNotFound
+	}
+
+	return rows, nil
+}
+
+func DeleteHoliday(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	result, err := db.ExecContext(
+		ctx,
+		`
+		DELETE FROM holiday
+		WHERE id = $1
+		AND workspace_id IN (`+managedWorkspaces(2)+`)
+		`,
+		id,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("DeleteHoliday: db delete: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("DeleteHoliday: rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return 0, ErrHolidayNotFound
+	}
+
 	return rows, nil
 }
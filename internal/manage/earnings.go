@@ -0,0 +1,73 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/payroll"
+	"time"
+)
+
+// GetEmploymentEarnings reports hours and pay of an employment in one of the
+// caller's companies. from and to are dates in the workspace's time zone, to
+// being the first day not included, and default to the current month.
+func GetEmploymentEarnings(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	from *time.Time,
+	to *time.Time,
+) (*payroll.Report, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	var managed bool
+	err := db.QueryRowContext(
+		ctx,
+		`
+		SELECT EXISTS (
+			SELECT 1 FROM employment
+			WHERE id = $2 AND company_id IN (`+managedCompanies(1)+`)
+		)
+		`,
+		claims.ProfileID,
+		id,
+	).Scan(&managed)
+	if err != nil {
+		return nil, fmt.Errorf("GetEmploymentEarnings: db select: %w", err)
+	}
+	if !managed {
+		return nil, ErrEmploymentNotFound
+	}
+
+	calendar, err := payroll.GetCalendar(ctx, db, id)
+	if err != nil {
+		if errors.Is(err, payroll.ErrEmploymentNotFound) {
+			return nil, ErrEmploymentNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now().In(calendar.Location)
+	start, end := calendar.MonthRange(now.Year(), now.Month())
+	if from != nil {
+		start = calendar.Date(*from)
+	}
+	if to != nil {
+		end = calendar.Date(*to)
+	}
+	if !end.After(start) {
+		return nil, ErrInvalidEarningsRange
+	}
+
+	report, err := payroll.ComputeEmployment(ctx, db, id, start, end)
+	if err != nil {
+		if errors.Is(err, payroll.ErrEmploymentNotFound) {
+			return nil, ErrEmploymentNotFound
+		}
+		return nil, err
+	}
+
+	return report, nil
+}
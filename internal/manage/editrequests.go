@@ -0,0 +1,292 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/model"
+)
+
+// GetEditRequests lists edit requests on shifts in the companies the caller
+// manages, oldest first so the queue is worked in order.
+func GetEditRequests(
+	ctx context.Context,
+	db *sql.DB,
+	filter EditRequestFilter,
+	page PageParams,
+) (*Page[EditRequestDetail], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	status := model.Pending
+	if filter.Status != nil {
+		status = *filter.Status
+	}
+
+	return listPage(ctx, db, listQuery{
+		Columns: `
+			r.id, r.shift_id, r.profile_id, r.task_id, r.start_ts, r.end_ts, COALESCE(r.reason, ''), r.status,
+			r.reviewer_id, r.review_comment, r.reviewed_at, r.created,
+			s.id, s.profile_id, s.task_id, s.start_ts, s.end_ts, s.auto_closed, s.version,
+			p.id, p.kt, p.first_name, p.last_name
+		`,
+		From: `
+			edit_request r
+			JOIN shift s ON s.id = r.shift_id
+			JOIN task t ON t.id = s.task_id
+			JOIN profile p ON p.id = r.profile_id
+			WHERE t.company_id IN (`+managedCompanies(1)+`)
+			AND r.status = $2
+			AND ($3::int IS NULL OR r.profile_id = $3)
+			AND ($4::int IS NULL OR t.company_id = $4)
+		`,
+		Args: []any{claims.ProfileID, status, filter.ProfileId, filter.CompanyId},
+		Id: "r.id",
+		Sorts: map[string]sortKey{
+			"id":      {"r.id", "int"},
+			"created": {"r.created", "timestamptz"},
+		},
+		DefaultSort: "created",
+	}, page, func(detail *EditRequestDetail) []any {
+		return []any{
+			&detail.EditRequest.Id,
+			&detail.EditRequest.ShiftId,
+			&detail.EditRequest.ProfileId,
+			&detail.EditRequest.TaskId,
+			&detail.EditRequest.StartTs,
+			&detail.EditRequest.EndTs,
+			&detail.EditRequest.Reason,
+			&detail.EditRequest.Status,
+			&detail.EditRequest.ReviewerId,
+			&detail.EditRequest.ReviewComment,
+			&detail.EditRequest.ReviewedAt,
+			&detail.EditRequest.Created,
+			&detail.Shift.Id,
+			&detail.Shift.ProfileId,
+			&detail.Shift.TaskId,
+			&detail.Shift.StartTs,
+			&detail.Shift.EndTs,
+			&detail.Shift.AutoClosed,
+			&detail.Shift.Version,
+			&detail.Profile.ID,
+			&detail.Profile.KT,
+			&detail.Profile.FirstName,
+			&detail.Profile.LastName,
+		}
+	})
+}
+
+// ApproveEditRequest applies the requested changes to the shift and closes
+// the request in one transaction.
+func ApproveEditRequest(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	input EditRequestReview,
+) (*model.EditRequest, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ApproveEditRequest: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	edit_request, err := lockEditRequest(ctx, tx, claims.ProfileID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if edit_request.TaskId != nil {
+		var managed bool
+		err = tx.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1 FROM task
+				WHERE id = $2 AND company_id IN (`+managedCompanies(1)+`)
+			)
+			`,
+			claims.ProfileID,
+			*edit_request.TaskId,
+		).Scan(&managed)
+		if err != nil {
+			return nil, fmt.Errorf("ApproveEditRequest: select task: %w", err)
+		}
+		if !managed {
+			return nil, ErrTaskNotFound
+		}
+	}
+
+	var changed_task, negative bool
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		UPDATE shift s
+		SET task_id = COALESCE($1, s.task_id),
+			start_ts = COALESCE($2, s.start_ts),
+			end_ts = COALESCE($3, s.end_ts)
+		FROM shift prev
+		WHERE s.id = $4 AND prev.id = s.id
+		RETURNING s.task_id IS DISTINCT FROM prev.task_id, COALESCE(s.end_ts < s.start_ts, FALSE)
+		`,
+		edit_request.TaskId,
+		edit_request.StartTs,
+		edit_request.EndTs,
+		edit_request.ShiftId,
+	).Scan(
+		&changed_task,
+		&negative,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ApproveEditRequest: update shift: %w", err)
+	}
+	if negative {
+		return nil, ErrNegativeDuration
+	}
+
+	// a corrected task replaces whatever the worker switched between, while
+	// corrected times only trim the segments, see shift_fit_segments
+	if changed_task {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			DELETE FROM shift_segment WHERE shift_id = $1
+			`,
+			edit_request.ShiftId,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ApproveEditRequest: delete segments: %w", err)
+		}
+	}
+
+	reviewed, err := closeEditRequest(ctx, tx, claims.ProfileID, id, model.Approved, input.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ApproveEditRequest: db commit: %w", err)
+	}
+
+	return reviewed, nil
+}
+
+func RejectEditRequest(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	input EditRequestReview,
+) (*model.EditRequest, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("RejectEditRequest: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = lockEditRequest(ctx, tx, claims.ProfileID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewed, err := closeEditRequest(ctx, tx, claims.ProfileID, id, model.Rejected, input.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("RejectEditRequest: db commit: %w", err)
+	}
+
+	return reviewed, nil
+}
+
+// lockEditRequest loads a pending request the reviewer may decide on. Requests
+// outside the reviewer's companies look like missing ones.
+func lockEditRequest(
+	ctx context.Context,
+	tx *sql.Tx,
+	reviewer_id int,
+	id int,
+) (*model.EditRequest, error) {
+	var edit_request model.EditRequest
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT r.id, r.shift_id, r.task_id, r.start_ts, r.end_ts, r.status
+		FROM edit_request r
+		JOIN shift s ON s.id = r.shift_id
+		JOIN task t ON t.id = s.task_id
+		WHERE r.id = $2
+		AND t.company_id IN (`+managedCompanies(1)+`)
+		FOR UPDATE OF r, s
+		`,
+		reviewer_id,
+		id,
+	).Scan(
+		&edit_request.Id,
+		&edit_request.ShiftId,
+		&edit_request.TaskId,
+		&edit_request.StartTs,
+		&edit_request.EndTs,
+		&edit_request.Status,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEditRequestNotFound
+		}
+		return nil, fmt.Errorf("lockEditRequest: db select: %w", err)
+	}
+
+	if edit_request.Status != model.Pending {
+		return nil, ErrEditRequestNotPending
+	}
+
+	return &edit_request, nil
+}
+
+func closeEditRequest(
+	ctx context.Context,
+	tx *sql.Tx,
+	reviewer_id int,
+	id int,
+	status model.RequestStatus,
+	comment *string,
+) (*model.EditRequest, error) {
+	var edit_request model.EditRequest
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		UPDATE edit_request
+		SET status = $1, reviewer_id = $2, review_comment = $3, reviewed_at = now(), updated = now()
+		WHERE id = $4
+		RETURNING id, shift_id, profile_id, task_id, start_ts, end_ts, COALESCE(reason, ''), status,
+			reviewer_id, review_comment, reviewed_at, created
+		`,
+		status,
+		reviewer_id,
+		comment,
+		id,
+	).Scan(
+		&edit_request.Id,
+		&edit_request.ShiftId,
+		&edit_request.ProfileId,
+		&edit_request.TaskId,
+		&edit_request.StartTs,
+		&edit_request.EndTs,
+		&edit_request.Reason,
+		&edit_request.Status,
+		&edit_request.ReviewerId,
+		&edit_request.ReviewComment,
+		&edit_request.ReviewedAt,
+		&edit_request.Created,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("closeEditRequest: db update: %w", err)
+	}
+
+	return &edit_request, nil
+}
@@ -0,0 +1,123 @@
+package manage
+
+import (
+	"database/sql"
+	"errors"
+	"test/internal/db/dbtest"
+	"test/internal/model"
+	"testing"
+	"time"
+)
+
+// requestEdit files a pending request by the tenant's worker to move the end
+// of their shift.
+func requestEdit(t *testing.T, db *sql.DB, tn tenant, end time.Time) int {
+	t.Helper()
+	return dbtest.QueryInt(t, db, `
+		INSERT INTO edit_request (shift_id, profile_id, end_ts, reason)
+		VALUES ($1, $2, $3, 'forgot to clock out')
+		RETURNING id`, tn.shift_id, tn.worker_id, end)
+}
+
+func shiftEnd(t *testing.T, db *sql.DB, shift_id int) time.Time {
+	t.Helper()
+	var end time.Time
+	err := db.QueryRow(`SELECT end_ts FROM shift WHERE id = $1`, shift_id).Scan(&end)
+	if err != nil {
+		t.Fatalf("select shift: %v", err)
+	}
+	return end
+}
+
+func TestApproveEditRequest(t *testing.T) {
+	db := dbtest.Open(t)
+	tn := newTenant(t, db, 1)
+
+	end := shiftEnd(t, db, tn.shift_id).Add(time.Hour).Truncate(time.Second)
+	id := requestEdit(t, db, tn, end)
+
+	comment := "ok"
+	reviewed, err := ApproveEditRequest(tn.ctx, db, id, EditRequestReview{Comment: &comment})
+	if err != nil {
+		t.Fatalf("ApproveEditRequest: %v", err)
+	}
+	if reviewed.Status != model.Approved || reviewed.ReviewerId == nil || *reviewed.ReviewerId != tn.owner_id {
+		t.Errorf("got %+v, want approved by %d", reviewed, tn.owner_id)
+	}
+	if got := shiftEnd(t, db, tn.shift_id); !got.Equal(end) {
+		t.Errorf("shift ends at %v, want %v", got, end)
+	}
+
+	// a request is only decided once
+	_, err = RejectEditRequest(tn.ctx, db, id, EditRequestReview{})
+	if !errors.Is(err, ErrEditRequestNotPending) {
+		t.Errorf("reject after approval: got %v, want ErrEditRequestNotPending", err)
+	}
+}
+
+func TestRejectEditRequest(t *testing.T) {
+	db := dbtest.Open(t)
+	tn := newTenant(t, db, 1)
+
+	before := shiftEnd(t, db, tn.shift_id)
+	id := requestEdit(t, db, tn, before.Add(time.Hour))
+
+	comment := "you left at four"
+	reviewed, err := RejectEditRequest(tn.ctx, db, id, EditRequestReview{Comment: &comment})
+	if err != nil {
+		t.Fatalf("RejectEditRequest: %v", err)
+	}
+	if reviewed.Status != model.Rejected || reviewed.ReviewComment == nil || *reviewed.ReviewComment != comment {
+		t.Errorf("got %+v, want rejected with the comment", reviewed)
+	}
+	if got := shiftEnd(t, db, tn.shift_id); !got.Equal(before) {
+		t.Errorf("shift ends at %v, want it left at %v", got, before)
+	}
+
+	_, err = ApproveEditRequest(tn.ctx, db, id, EditRequestReview{})
+	if !errors.Is(err, ErrEditRequestNotPending) {
+		t.Errorf("approve after rejection: got %v, want ErrEditRequestNotPending", err)
+	}
+}
+
+func TestApproveEditRequestNegative(t *testing.T) {
+	db := dbtest.Open(t)
+	tn := newTenant(t, db, 1)
+
+	end := shiftEnd(t, db, tn.shift_id).Add(-24 * time.Hour)
+	id := requestEdit(t, db, tn, end)
+
+	_, err := ApproveEditRequest(tn.ctx, db, id, EditRequestReview{})
+	if !errors.Is(err, ErrNegativeDuration) {
+		t.Fatalf("got %v, want ErrNegativeDuration", err)
+	}
+
+	// nothing of the approval sticks
+	pending := dbtest.QueryInt(t, db, `SELECT count(*) FROM edit_request WHERE id = $1 AND status = 'pending'`, id)
+	if pending != 1 {
+		t.Error("the request is no longer pending")
+	}
+}
+
+func TestEditRequestOtherTenant(t *testing.T) {
+	db := dbtest.Open(t)
+	ours := newTenant(t, db, 1)
+	theirs := newTenant(t, db, 2)
+
+	id := requestEdit(t, db, theirs, shiftEnd(t, db, theirs.shift_id).Add(time.Hour))
+
+	tests := []struct {
+		name   string
+		review func() error
+	}{
+		{"approve", func() error { _, err := ApproveEditRequest(ours.ctx, db, id, EditRequestReview{}); return err }},
+		{"reject", func() error { _, err := RejectEditRequest(ours.ctx, db, id, EditRequestReview{}); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.review(); !errors.Is(err, ErrEditRequestNotFound) {
+				t.Errorf("got %v, want ErrEditRequestNotFound", err)
+			}
+		})
+	}
+}
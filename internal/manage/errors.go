@@ -1,12 +1,133 @@
 package manage
 
 import (
+	"errors"
 	"log"
 	"net/http"
 )
 
+var (
+	ErrInvalidGeofencePolicy = errors.New("geofence_policy must be reject, flag or ignore")
+	ErrInvalidGeofence       = errors.New("latitude and longitude must be valid coordinates and radius_m positive")
+	ErrInvalidMaxShiftMinutes = errors.New("max_shift_minutes cannot be negative")
+	ErrInvalidPayPeriodStartDay = errors.New("pay_period_start_day must be within 1-28")
+	ErrInvalidTimeZone       = errors.New("time_zone must be an IANA time zone like Atlantic/Reykjavik")
+	ErrInvalidEarningsRange  = errors.New("to must be after from")
+	ErrEditRequestNotFound   = errors.New("edit request not found")
+	ErrEditRequestNotPending = errors.New("edit request is no longer pending")
+	ErrTaskNotFound          = errors.New("task not found")
+	ErrNegativeDuration      = errors.New("shift duration cannot be negative")
+	ErrEmploymentNotFound    = errors.New("employment not found")
+	ErrInvalidOvertime       = errors.New("overtime thresholds cannot be negative and overtime_multiplier must be at least 1")
+	ErrInvalidPayRule        = errors.New("kind must be window or holiday, days_mask within 0-127, minutes within 0-1440 and multiplier at least 1")
+	ErrInvalidHoliday        = errors.New("date must be formatted as YYYY-MM-DD and name must not be empty")
+	ErrHolidayExists         = errors.New("workspace already has a holiday on that date")
+	ErrContractNotFound      = errors.New("contract not found")
+	ErrEffectiveFromInPast   = errors.New("effective_from must be in the future")
+	ErrContractVersionInEffect = errors.New("contract version has already taken effect")
+	ErrContractVersionNotFound = errors.New("contract version not found")
+	ErrWorkspaceNotFound     = errors.New("workspace not found")
+	ErrCompanyNotFound       = errors.New("company not found")
+	ErrLocationNotFound      = errors.New("location not found")
+	ErrProfileNotFound       = errors.New("profile not found")
+	ErrProfileShared         = errors.New("profile is employed outside the companies you manage")
+	ErrInvalidRole           = errors.New("role must be owner, admin, manager or worker")
+	ErrRoleNotGrantable      = errors.New("cannot grant a role above your own")
+	ErrShiftNotFound         = errors.New("shift not found")
+	ErrBreakTypeNotFound     = errors.New("break type not found")
+	ErrPayRuleNotFound       = errors.New("pay rule not found")
+	ErrHolidayNotFound       = errors.New("holiday not found")
+	ErrInvalidSort           = errors.New("sort is not one of the sort keys of this list")
+	ErrInvalidCursor         = errors.New("cursor is invalid or was made for another sort")
+	ErrInvalidLimit          = errors.New("limit must be between 1 and 500")
+	ErrInvalidPlannedShift   = errors.New("end_ts must be after start_ts, recurrence none, daily or weekly and repeat_until a date like 2006-01-02")
+	ErrPlannedShiftTooLong   = errors.New("a recurring shift has to end before it repeats")
+	ErrPlannedShiftNotFound  = errors.New("planned shift not found")
+	ErrProfileNotEmployed    = errors.New("profile is not employed at the task's company")
+	ErrInvalidRosterRange    = errors.New("to must be after from and at most 62 days later")
+	ErrNoFieldsToUpdate      = errors.New("no fields to update")
+)
+
 func WriteDomainError(w http.ResponseWriter, err error) {
 	switch {
+	case errors.Is(err, ErrInvalidGeofencePolicy):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidGeofence):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidMaxShiftMinutes):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidPayPeriodStartDay):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidTimeZone):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidEarningsRange):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrEditRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEditRequestNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrTaskNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNegativeDuration):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrEmploymentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidOvertime):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidPayRule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidHoliday):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrHolidayExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrContractNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEffectiveFromInPast):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrContractVersionInEffect):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrContractVersionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrWorkspaceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrCompanyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrLocationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrProfileNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrProfileShared):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrRoleNotGrantable):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrShiftNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrBreakTypeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrPayRuleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrHolidayNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidSort):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidCursor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidLimit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidPlannedShift):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrPlannedShiftTooLong):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrPlannedShiftNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrProfileNotEmployed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidRosterRange):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrNoFieldsToUpdate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
 		log.Printf("internal error: %+v", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
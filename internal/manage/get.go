@@ -3,192 +3,217 @@ package manage
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"test/internal/auth"
 	"test/internal/model"
 )
 
+var (
+	idSort   = sortKey{"id", "int"}
+	nameSort = sortKey{"name", "text"}
+
+	shiftSorts = map[string]sortKey{
+		"id":       {"s.id", "int"},
+		"start_ts": {"s.start_ts", "timestamptz"},
+		// open shifts last
+		"end_ts": {"COALESCE(s.end_ts, 'infinity')", "timestamptz"},
+	}
+)
+
 func GetWorkspaces(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Workspace, error) {
-	workspaces := []model.Workspace{}
-	rows, err := db.Query(
-		`
-		SELECT id, name
-		FROM workspace
+	page PageParams,
+) (*Page[model.Workspace], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, name, geofence_policy, max_shift_minutes, auto_close_edit_request, pay_period_start_day, time_zone`,
+		From: `
+			workspace
+			WHERE id IN (`+managedWorkspaces(1)+`)
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetWorkspaces: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var workspace model.Workspace
-		err = rows.Scan(
+		Args: []any{claims.ProfileID},
+		Id: "id",
+		Sorts: map[string]sortKey{"id": idSort, "name": nameSort},
+		DefaultSort: "id",
+	}, page, func(workspace *model.Workspace) []any {
+		return []any{
 			&workspace.Id,
 			&workspace.Name,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetWorkspaces: db scan: %w", err)
+			&workspace.GeofencePolicy,
+			&workspace.MaxShiftMinutes,
+			&workspace.AutoCloseEditRequest,
+			&workspace.PayPeriodStartDay,
+			&workspace.TimeZone,
 		}
-
-		workspaces = append(workspaces, workspace)
-	}
-
-	return &workspaces, nil
+	})
 }
 
 func GetCompanies(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Company, error) {
-	companies := []model.Company{}
-	rows, err := db.Query(
-		`
-		SELECT id, name, workspace_id
-		FROM company
+	filter CompanyFilter,
+	page PageParams,
+) (*Page[model.Company], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, name, workspace_id`,
+		From: `
+			company
+			WHERE id IN (`+managedCompanies(1)+`)
+			AND ($2::int IS NULL OR workspace_id = $2)
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetCompanies: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var company model.Company
-		err = rows.Scan(
+		Args: []any{claims.ProfileID, filter.WorkspaceId},
+		Id: "id",
+		Sorts: map[string]sortKey{"id": idSort, "name": nameSort},
+		DefaultSort: "id",
+	}, page, func(company *model.Company) []any {
+		return []any{
 			&company.Id,
 			&company.Name,
 			&company.WorkspaceId,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetCompanies: db scan: %w", err)
 		}
-
-		companies = append(companies, company)
-	}
-
-	return &companies, nil
+	})
 }
 
 func GetLocations(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Location, error) {
-	locations := []model.Location{}
-	rows, err := db.Query(
-		`
-		SELECT id, name, address, workspace_id
-		FROM location
+	filter LocationFilter,
+	page PageParams,
+) (*Page[model.Location], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, name, address, workspace_id, latitude, longitude, radius_m`,
+		From: `
+			location
+			WHERE workspace_id IN (`+managedWorkspaces(1)+`)
+			AND ($2::int IS NULL OR workspace_id = $2)
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetLocations: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var location model.Location
-		err = rows.Scan(
+		Args: []any{claims.ProfileID, filter.WorkspaceId},
+		Id: "id",
+		Sorts: map[string]sortKey{"id": idSort, "name": nameSort},
+		DefaultSort: "id",
+	}, page, func(location *model.Location) []any {
+		return []any{
 			&location.Id,
 			&location.Name,
 			&location.Address,
 			&location.WorkspaceId,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetLocations: db scan: %w", err)
+			&location.Latitude,
+			&location.Longitude,
+			&location.RadiusM,
 		}
-
-		locations = append(locations, location)
-	}
-
-	return &locations, nil
+	})
 }
 
 func GetTasks(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Task, error) {
-	tasks := []model.Task{}
-	rows, err := db.Query(
-		`
-		SELECT id, location_id, company_id, name, description, is_completed
-		FROM task
+	filter TaskFilter,
+	page PageParams,
+) (*Page[model.Task], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, location_id, company_id, name, description, is_completed`,
+		From: `
+			task
+			WHERE company_id IN (`+managedCompanies(1)+`)
+			AND ($2::int IS NULL OR company_id = $2)
+			AND ($3::int IS NULL OR location_id = $3)
+			AND ($4::boolean IS NULL OR is_completed = $4)
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetTasks: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var task model.Task
-		err = rows.Scan(
+		Args: []any{claims.ProfileID, filter.CompanyId, filter.LocationId, filter.IsCompleted},
+		Id: "id",
+		Sorts: map[string]sortKey{"id": idSort, "name": nameSort},
+		DefaultSort: "id",
+	}, page, func(task *model.Task) []any {
+		return []any{
 			&task.Id,
 			&task.LocationId,
 			&task.CompanyId,
 			&task.Name,
 			&task.Description,
 			&task.IsCompleted,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetTasks: db scan: %w", err)
 		}
-
-		tasks = append(tasks, task)
-	}
-
-	return &tasks, nil
+	})
 }
 
+// GetProfiles lists the profiles employed at the companies the caller
+// manages. Name matches the start of the first name, the last name or the
+// full name, ignoring case.
 func GetProfiles(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Profile, error) {
-	profiles := []model.Profile{}
-	rows, err := db.Query(
-		`
-		SELECT id, kt, first_name, last_name
-		FROM profile
+	filter ProfileFilter,
+	page PageParams,
+) (*Page[model.Profile], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, kt, first_name, last_name`,
+		From: `
+			profile
+			WHERE id IN (`+managedProfiles(1)+`)
+			AND ($2::text IS NULL
+				OR starts_with(lower(first_name), lower($2))
+				OR starts_with(lower(last_name), lower($2))
+				OR starts_with(lower(first_name || ' ' || last_name), lower($2)))
+			AND ($3::text IS NULL OR starts_with(kt, $3))
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetProfiles: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var profile model.Profile
-		err = rows.Scan(
+		Args: []any{claims.ProfileID, filter.Name, filter.KT},
+		Id: "id",
+		Sorts: map[string]sortKey{
+			"id":         idSort,
+			"kt":         {"kt", "text"},
+			"first_name": {"first_name", "text"},
+			"last_name":  {"last_name", "text"},
+		},
+		DefaultSort: "id",
+	}, page, func(profile *model.Profile) []any {
+		return []any{
 			&profile.ID,
 			&profile.KT,
 			&profile.FirstName,
 			&profile.LastName,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetProfiles: db scan: %w", err)
 		}
-
-		profiles = append(profiles, profile)
-	}
-
-	return &profiles, nil
+	})
 }
 
 func GetEmployments(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Employment, error) {
-	employments := []model.Employment{}
-	rows, err := db.Query(
-		`
-		SELECT id, profile_id, company_id, contract_id, role, start_date, end_date
-		FROM employment
+	filter EmploymentFilter,
+	page PageParams,
+) (*Page[model.Employment], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, profile_id, company_id, contract_id, role, start_date, end_date`,
+		From: `
+			employment
+			WHERE company_id IN (`+managedCompanies(1)+`)
+			AND ($2::int IS NULL OR profile_id = $2)
+			AND ($3::int IS NULL OR company_id = $3)
+			AND ($4::text IS NULL OR role = $4)
+			AND ($5::boolean IS NULL OR $5 = (
+				(start_date IS NULL OR start_date <= now())
+				AND (end_date IS NULL OR end_date > now())
+			))
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetEmployments: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var employment model.Employment
-		err = rows.Scan(
+		Args: []any{claims.ProfileID, filter.ProfileId, filter.CompanyId, filter.Role, filter.Active},
+		Id: "id",
+		Sorts: map[string]sortKey{
+			"id":         idSort,
+			"start_date": {"COALESCE(start_date, '-infinity')", "timestamptz"},
+			"end_date":   {"COALESCE(end_date, 'infinity')", "timestamptz"},
+		},
+		DefaultSort: "id",
+	}, page, func(employment *model.Employment) []any {
+		return []any{
 			&employment.Id,
 			&employment.ProfileId,
 			&employment.CompanyId,
@@ -196,67 +221,143 @@ func GetEmployments(
 			&employment.Role,
 			&employment.StartDate,
 			&employment.EndDate,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetEmployments: db scan: %w", err)
 		}
-
-		employments = append(employments, employment)
-	}
-
-	return &employments, nil
+	})
 }
 
+// GetContracts lists contracts with the terms in force now.
 func GetContracts(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Contract, error) {
-	contracts := []model.Contract{}
-	rows, err := db.Query(
-		`
-		SELECT id, hourly_rate, unpaid_lunch_minutes
-		FROM contract
+	filter ContractFilter,
+	page PageParams,
+) (*Page[model.Contract], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `
+			ct.id, ct.workspace_id, cv.id, cv.effective_from, cv.hourly_rate, cv.unpaid_lunch_minutes,
+			cv.daily_overtime_minutes, cv.weekly_overtime_minutes, cv.overtime_multiplier
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetContracts: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var contract model.Contract
-		err = rows.Scan(
+		From: `
+			contract ct
+			JOIN LATERAL contract_version_at(ct.id, now()) cv ON true
+			WHERE ct.workspace_id IN (`+managedWorkspaces(1)+`)
+			AND ($2::int IS NULL OR ct.workspace_id = $2)
+		`,
+		Args: []any{claims.ProfileID, filter.WorkspaceId},
+		Id: "ct.id",
+		Sorts: map[string]sortKey{
+			"id":          {"ct.id", "int"},
+			"hourly_rate": {"cv.hourly_rate", "int"},
+		},
+		DefaultSort: "id",
+	}, page, func(contract *model.Contract) []any {
+		return []any{
 			&contract.Id,
+			&contract.WorkspaceId,
+			&contract.VersionId,
+			&contract.EffectiveFrom,
 			&contract.HourlyRate,
 			&contract.UnpaidLunchMinutes,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetContracts: db scan: %w", err)
+			&contract.DailyOvertimeMinutes,
+			&contract.WeeklyOvertimeMinutes,
+			&contract.OvertimeMultiplier,
 		}
+	})
+}
 
-		contracts = append(contracts, contract)
-	}
+func GetBreakTypes(
+	ctx context.Context,
+	db *sql.DB,
+	filter BreakTypeFilter,
+	page PageParams,
+) (*Page[model.BreakType], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `bt.id, bt.contract_id, bt.name, bt.paid, bt.max_minutes`,
+		From: `
+			break_type bt
+			JOIN contract ct ON ct.id = bt.contract_id
+			WHERE ct.workspace_id IN (`+managedWorkspaces(1)+`)
+			AND ($2::int IS NULL OR bt.contract_id = $2)
+		`,
+		Args: []any{claims.ProfileID, filter.ContractId},
+		Id: "bt.id",
+		Sorts: map[string]sortKey{
+			"id":   {"bt.id", "int"},
+			"name": {"bt.name", "text"},
+		},
+		DefaultSort: "id",
+	}, page, func(breakType *model.BreakType) []any {
+		return []any{
+			&breakType.Id,
+			&breakType.ContractId,
+			&breakType.Name,
+			&breakType.Paid,
+			&breakType.MaxMinutes,
+		}
+	})
+}
 
-	return &contracts, nil
+// shiftFilters is the WHERE clause shared by the shift lists, with the
+// caller in $1 and the ShiftFilter from $2 on. Task and location also match
+// shifts that switched to them part way.
+const shiftFilters = `
+	AND ($2::int IS NULL OR s.profile_id = $2)
+	AND ($3::int IS NULL OR s.task_id = $3 OR EXISTS (
+		SELECT 1 FROM shift_segment g
+		WHERE g.shift_id = s.id AND g.task_id = $3
+	))
+	AND ($4::int IS NULL OR t.location_id = $4 OR EXISTS (
+		SELECT 1 FROM shift_segment g
+		JOIN task gt ON gt.id = g.task_id
+		WHERE g.shift_id = s.id AND gt.location_id = $4
+	))
+	AND ($5::int IS NULL OR t.company_id = $5)
+	AND ($6::timestamptz IS NULL OR s.start_ts >= $6)
+	AND ($7::timestamptz IS NULL OR s.start_ts < $7)
+	AND ($8::boolean IS NULL OR $8 = (s.end_ts IS NULL))
+`
+
+func shiftFilterArgs(profile_id int, filter ShiftFilter) []any {
+	return []any{
+		profile_id,
+		filter.ProfileId,
+		filter.TaskId,
+		filter.LocationId,
+		filter.CompanyId,
+		filter.From,
+		filter.To,
+		filter.Open,
+	}
 }
 
 func GetShifts(
 	ctx context.Context,
 	db *sql.DB,
-) (*[]model.Shift, error) {
-	shifts := []model.Shift{}
-	rows, err := db.Query(
-		`
-		SELECT id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude
-		FROM shift
+	filter ShiftFilter,
+	page PageParams,
+) (*Page[model.Shift], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `
+			s.id, s.profile_id, s.task_id, s.start_ts, s.end_ts, s.s_latitude, s.s_longitude, s.e_latitude, s.e_longitude,
+			s.s_accuracy, s.e_accuracy, s.s_flagged, s.e_flagged, s.auto_closed
 		`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetShifts: db select: %w", err)
-	}
-
-	for rows.Next() {
-		var shift model.Shift
-		err = rows.Scan(
+		From: `
+			shift s
+			JOIN task t ON t.id = s.task_id
+			WHERE t.company_id IN (`+managedCompanies(1)+`)
+		`+shiftFilters,
+		Args: shiftFilterArgs(claims.ProfileID, filter),
+		Id: "s.id",
+		Sorts: shiftSorts,
+		DefaultSort: "-start_ts",
+	}, page, func(shift *model.Shift) []any {
+		return []any{
 			&shift.Id,
 			&shift.ProfileId,
 			&shift.TaskId,
@@ -266,13 +367,143 @@ func GetShifts(
 			&shift.SLongitude,
 			&shift.ELatitude,
 			&shift.ELongitude,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("GetShifts: db scan: %w", err)
+			&shift.SAccuracy,
+			&shift.EAccuracy,
+			&shift.SFlagged,
+			&shift.EFlagged,
+			&shift.AutoClosed,
+		}
+	})
+}
+
+// GetFlaggedShifts lists the shifts that need a manager's review, newest
+// first. Those are shifts clocked in or out outside the geofence of their
+// location and shifts that were closed automatically.
+func GetFlaggedShifts(
+	ctx context.Context,
+	db *sql.DB,
+	filter ShiftFilter,
+	page PageParams,
+) (*Page[FlaggedShift], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `
+			s.id, s.profile_id, s.task_id, s.start_ts, s.end_ts, s.s_latitude, s.s_longitude, s.e_latitude, s.e_longitude,
+			s.s_accuracy, s.e_accuracy, s.s_flagged, s.e_flagged, s.auto_closed,
+			p.id, p.kt, p.first_name, p.last_name,
+			l.id, l.workspace_id, l.name, l.address, l.latitude, l.longitude, l.radius_m
+		`,
+		From: `
+			shift s
+			JOIN profile p ON p.id = s.profile_id
+			JOIN task t ON t.id = s.task_id
+			JOIN location l ON l.id = t.location_id
+			WHERE (s.s_flagged OR s.e_flagged OR s.auto_closed)
+			AND t.company_id IN (`+managedCompanies(1)+`)
+		`+shiftFilters,
+		Args: shiftFilterArgs(claims.ProfileID, filter),
+		Id: "s.id",
+		Sorts: shiftSorts,
+		DefaultSort: "-start_ts",
+	}, page, func(flagged *FlaggedShift) []any {
+		return []any{
+			&flagged.Shift.Id,
+			&flagged.Shift.ProfileId,
+			&flagged.Shift.TaskId,
+			&flagged.Shift.StartTs,
+			&flagged.Shift.EndTs,
+			&flagged.Shift.SLatitude,
+			&flagged.Shift.SLongitude,
+			&flagged.Shift.ELatitude,
+			&flagged.Shift.ELongitude,
+			&flagged.Shift.SAccuracy,
+			&flagged.Shift.EAccuracy,
+			&flagged.Shift.SFlagged,
+			&flagged.Shift.EFlagged,
+			&flagged.Shift.AutoClosed,
+			&flagged.Profile.ID,
+			&flagged.Profile.KT,
+			&flagged.Profile.FirstName,
+			&flagged.Profile.LastName,
+			&flagged.Location.Id,
+			&flagged.Location.WorkspaceId,
+			&flagged.Location.Name,
+			&flagged.Location.Address,
+			&flagged.Location.Latitude,
+			&flagged.Location.Longitude,
+			&flagged.Location.RadiusM,
 		}
+	})
+}
 
-		shifts = append(shifts, shift)
-	}
+func GetPayRules(
+	ctx context.Context,
+	db *sql.DB,
+	filter PayRuleFilter,
+	page PageParams,
+) (*Page[model.PayRule], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `pr.id, pr.contract_id, pr.name, pr.kind, pr.days_mask, pr.start_minute, pr.end_minute, pr.multiplier`,
+		From: `
+			pay_rule pr
+			JOIN contract ct ON ct.id = pr.contract_id
+			WHERE ct.workspace_id IN (`+managedWorkspaces(1)+`)
+			AND ($2::int IS NULL OR pr.contract_id = $2)
+		`,
+		Args: []any{claims.ProfileID, filter.ContractId},
+		Id: "pr.id",
+		Sorts: map[string]sortKey{
+			"id":   {"pr.id", "int"},
+			"name": {"pr.name", "text"},
+		},
+		DefaultSort: "id",
+	}, page, func(rule *model.PayRule) []any {
+		return []any{
+			&rule.Id,
+			&rule.ContractId,
+			&rule.Name,
+			&rule.Kind,
+			&rule.DaysMask,
+			&rule.StartMinute,
+			&rule.EndMinute,
+			&rule.Multiplier,
+		}
+	})
+}
 
-	return &shifts, nil
+func GetHolidays(
+	ctx context.Context,
+	db *sql.DB,
+	filter HolidayFilter,
+	page PageParams,
+) (*Page[model.Holiday], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: `id, workspace_id, to_char(date, 'YYYY-MM-DD'), name`,
+		From: `
+			holiday
+			WHERE workspace_id IN (`+managedWorkspaces(1)+`)
+			AND ($2::int IS NULL OR workspace_id = $2)
+			AND ($3::date IS NULL OR date >= $3)
+			AND ($4::date IS NULL OR date < $4)
+		`,
+		Args: []any{claims.ProfileID, filter.WorkspaceId, filter.From, filter.To},
+		Id: "id",
+		Sorts: map[string]sortKey{
+			"id":   idSort,
+			"date": {"date", "date"},
+		},
+		DefaultSort: "date",
+	}, page, func(holiday *model.Holiday) []any {
+		return []any{
+			&holiday.Id,
+			&holiday.WorkspaceId,
+			&holiday.Date,
+			&holiday.Name,
+		}
+	})
 }
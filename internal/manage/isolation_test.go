@@ -0,0 +1,360 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"test/internal/auth"
+	"test/internal/db/dbtest"
+	"test/internal/model"
+	"testing"
+	"time"
+)
+
+// tenant is a workspace with one of everything, run by its owner.
+type tenant struct {
+	ctx context.Context
+
+	owner_id      int
+	workspace_id  int
+	company_id    int
+	location_id   int
+	task_id       int
+	contract_id   int
+	worker_id     int
+	employment_id int
+	shift_id      int
+}
+
+// newTenant sets up a workspace the way kronosctl and the manage API would.
+func newTenant(t *testing.T, db *sql.DB, n int) tenant {
+	t.Helper()
+	ctx := context.Background()
+	var tn tenant
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer tx.Rollback()
+
+	owner, err := auth.InsertProfile(ctx, tx, auth.ProfileCreate{
+		KT:        fmt.Sprintf("01013029%02d", n),
+		FirstName: "Owner",
+		LastName:  fmt.Sprint(n),
+	})
+	if err != nil {
+		t.Fatalf("InsertProfile: %v", err)
+	}
+	workspace, err := InsertWorkspace(ctx, tx, WorkspaceCreate{Name: fmt.Sprint("Workspace ", n)}, owner.ID)
+	if err != nil {
+		t.Fatalf("InsertWorkspace: %v", err)
+	}
+	company, err := InsertCompany(ctx, tx, CompanyCreate{Name: fmt.Sprint("Company ", n), WorkspaceId: workspace.Id}, owner.ID)
+	if err != nil {
+		t.Fatalf("InsertCompany: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	tn.ctx = context.WithValue(ctx, auth.ClaimsKey, &auth.Claims{ProfileID: owner.ID})
+	tn.owner_id = owner.ID
+	tn.workspace_id = workspace.Id
+	tn.company_id = company.Id
+
+	location, err := CreateLocation(tn.ctx, db, LocationCreate{Name: "Site", Address: "Street 1", WorkspaceId: tn.workspace_id})
+	if err != nil {
+		t.Fatalf("CreateLocation: %v", err)
+	}
+	tn.location_id = location.Id
+
+	task, err := CreateTask(tn.ctx, db, TaskCreate{Name: "Task", LocationId: tn.location_id, CompanyId: tn.company_id})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	tn.task_id = task.Id
+
+	contract, err := CreateContract(tn.ctx, db, ContractCreate{WorkspaceId: tn.workspace_id, HourlyRate: 100})
+	if err != nil {
+		t.Fatalf("CreateContract: %v", err)
+	}
+	tn.contract_id = contract.Id
+
+	pin := "1234"
+	worker, err := CreateProfile(tn.ctx, db, ProfileHire{
+		ProfileCreate: auth.ProfileCreate{
+			KT:        fmt.Sprintf("02023029%02d", n),
+			FirstName: "Worker",
+			LastName:  fmt.Sprint(n),
+			Pin:       &pin,
+		},
+		CompanyId:  tn.company_id,
+		ContractId: tn.contract_id,
+		Role:       model.RoleWorker,
+	})
+	if err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+	tn.worker_id = worker.ID
+	tn.employment_id = worker.Employment.Id
+
+	start := time.Now().Add(-8 * time.Hour)
+	tn.shift_id = dbtest.QueryInt(t, db, `
+		INSERT INTO shift (profile_id, task_id, start_ts, end_ts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`, tn.worker_id, tn.task_id, start, start.Add(4*time.Hour))
+
+	return tn
+}
+
+func ids[T any](t *testing.T, page *Page[T], err error, id func(T) int) []int {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	got := []int{}
+	for _, item := range page.Items {
+		got = append(got, id(item))
+	}
+	return got
+}
+
+func TestTenantIsolationLists(t *testing.T) {
+	db := dbtest.Open(t)
+	ours := newTenant(t, db, 1)
+	theirs := newTenant(t, db, 2)
+	ctx := ours.ctx
+
+	tests := []struct {
+		name        string
+		list        func() []int
+		ours, their int
+	}{
+		{"workspaces", func() []int {
+			page, err := GetWorkspaces(ctx, db, PageParams{})
+			return ids(t, page, err, func(w model.Workspace) int { return w.Id })
+		}, ours.workspace_id, theirs.workspace_id},
+		{"companies", func() []int {
+			page, err := GetCompanies(ctx, db, CompanyFilter{}, PageParams{})
+			return ids(t, page, err, func(c model.Company) int { return c.Id })
+		}, ours.company_id, theirs.company_id},
+		{"locations", func() []int {
+			page, err := GetLocations(ctx, db, LocationFilter{}, PageParams{})
+			return ids(t, page, err, func(l model.Location) int { return l.Id })
+		}, ours.location_id, theirs.location_id},
+		{"tasks", func() []int {
+			page, err := GetTasks(ctx, db, TaskFilter{}, PageParams{})
+			return ids(t, page, err, func(task model.Task) int { return task.Id })
+		}, ours.task_id, theirs.task_id},
+		{"profiles", func() []int {
+			page, err := GetProfiles(ctx, db, ProfileFilter{}, PageParams{})
+			return ids(t, page, err, func(p model.Profile) int { return p.ID })
+		}, ours.worker_id, theirs.worker_id},
+		{"employments", func() []int {
+			page, err := GetEmployments(ctx, db, EmploymentFilter{}, PageParams{})
+			return ids(t, page, err, func(e model.Employment) int { return e.Id })
+		}, ours.employment_id, theirs.employment_id},
+		{"contracts", func() []int {
+			page, err := GetContracts(ctx, db, ContractFilter{}, PageParams{})
+			return ids(t, page, err, func(c model.Contract) int { return c.Id })
+		}, ours.contract_id, theirs.contract_id},
+		{"shifts", func() []int {
+			page, err := GetShifts(ctx, db, ShiftFilter{}, PageParams{})
+			return ids(t, page, err, func(s model.Shift) int { return s.Id })
+		}, ours.shift_id, theirs.shift_id},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.list()
+			if !slices.Contains(got, tt.ours) {
+				t.Errorf("%v is missing our %d", got, tt.ours)
+			}
+			if slices.Contains(got, tt.their) {
+				t.Errorf("%v has their %d", got, tt.their)
+			}
+		})
+	}
+
+	// filtering on their workspace doesn't get around the scope
+	page, err := GetCompanies(ctx, db, CompanyFilter{WorkspaceId: &theirs.workspace_id}, PageParams{})
+	if got := ids(t, page, err, func(c model.Company) int { return c.Id }); len(got) != 0 {
+		t.Errorf("companies of their workspace = %v, want none", got)
+	}
+}
+
+func TestTenantIsolationWrites(t *testing.T) {
+	db := dbtest.Open(t)
+	ours := newTenant(t, db, 1)
+	theirs := newTenant(t, db, 2)
+	ctx := ours.ctx
+
+	name := "Taken over"
+	rate := 1
+	role := model.RoleManager
+	task_completed := true
+
+	tests := []struct {
+		name  string
+		write func() error
+		want  error
+	}{
+		{"patch workspace", func() error {
+			_, err := PatchWorkspace(ctx, db, theirs.workspace_id, WorkspacePatch{Name: &name})
+			return err
+		}, ErrWorkspaceNotFound},
+		{"patch company", func() error {
+			_, err := PatchCompany(ctx, db, theirs.company_id, CompanyPatch{Name: &name})
+			return err
+		}, ErrCompanyNotFound},
+		{"patch location", func() error {
+			_, err := PatchLocation(ctx, db, theirs.location_id, LocationPatch{Name: &name})
+			return err
+		}, ErrLocationNotFound},
+		{"patch task", func() error {
+			_, err := PatchTask(ctx, db, theirs.task_id, TaskPatch{IsCompleted: &task_completed})
+			return err
+		}, ErrTaskNotFound},
+		{"move our task to their location", func() error {
+			_, err := PatchTask(ctx, db, ours.task_id, TaskPatch{LocationId: &theirs.location_id})
+			return err
+		}, ErrLocationNotFound},
+		{"patch contract", func() error {
+			_, err := PatchContract(ctx, db, theirs.contract_id, ContractPatch{HourlyRate: &rate})
+			return err
+		}, ErrContractNotFound},
+		{"patch employment", func() error {
+			_, err := PatchEmployment(ctx, db, theirs.employment_id, EmploymentPatch{Role: &role})
+			return err
+		}, ErrEmploymentNotFound},
+		{"patch profile", func() error {
+			_, err := PatchProfile(ctx, db, theirs.worker_id, ProfilePatch{FirstName: &name})
+			return err
+		}, ErrProfileNotFound},
+		{"patch shift", func() error {
+			_, err := PatchShift(ctx, db, theirs.shift_id, ShiftPatch{SFlagged: &task_completed})
+			return err
+		}, ErrShiftNotFound},
+		{"delete workspace", func() error {
+			_, err := DeleteWorkspace(ctx, db, theirs.workspace_id)
+			return err
+		}, ErrWorkspaceNotFound},
+		{"delete company", func() error {
+			_, err := DeleteCompany(ctx, db, theirs.company_id)
+			return err
+		}, ErrCompanyNotFound},
+		{"delete location", func() error {
+			_, err := DeleteLocation(ctx, db, theirs.location_id)
+			return err
+		}, ErrLocationNotFound},
+		{"delete task", func() error {
+			_, err := DeleteTask(ctx, db, theirs.task_id)
+			return err
+		}, ErrTaskNotFound},
+		{"delete contract", func() error {
+			_, err := DeleteContract(ctx, db, theirs.contract_id)
+			return err
+		}, ErrContractNotFound},
+		{"delete profile", func() error {
+			_, err := DeleteProfile(ctx, db, theirs.worker_id)
+			return err
+		}, ErrProfileNotFound},
+		{"delete shift", func() error {
+			_, err := DeleteShift(ctx, db, theirs.shift_id)
+			return err
+		}, ErrShiftNotFound},
+		{"employ their worker", func() error {
+			_, err := CreateEmployment(ctx, db, EmploymentCreate{
+				ProfileId:  theirs.worker_id,
+				CompanyId:  ours.company_id,
+				ContractId: ours.contract_id,
+				Role:       model.RoleWorker,
+			})
+			return err
+		}, ErrProfileNotFound},
+		{"employ at their company", func() error {
+			_, err := CreateEmployment(ctx, db, EmploymentCreate{
+				ProfileId:  ours.worker_id,
+				CompanyId:  theirs.company_id,
+				ContractId: theirs.contract_id,
+				Role:       model.RoleWorker,
+			})
+			return err
+		}, ErrCompanyNotFound},
+		{"hire into their company", func() error {
+			_, err := CreateProfile(ctx, db, ProfileHire{
+				ProfileCreate: auth.ProfileCreate{KT: "0303302999", FirstName: "New", LastName: "Hire"},
+				CompanyId:     theirs.company_id,
+				ContractId:    theirs.contract_id,
+				Role:          model.RoleWorker,
+			})
+			return err
+		}, ErrCompanyNotFound},
+		{"grant a role above our own", func() error {
+			// the worker, made a manager, tries to make themselves an admin
+			manager_ctx := context.WithValue(context.Background(), auth.ClaimsKey, &auth.Claims{ProfileID: ours.worker_id})
+			dbtest.Exec(t, db, `UPDATE employment SET role = 'manager' WHERE id = $1`, ours.employment_id)
+			defer dbtest.Exec(t, db, `UPDATE employment SET role = 'worker' WHERE id = $1`, ours.employment_id)
+
+			_, err := CreateEmployment(manager_ctx, db, EmploymentCreate{
+				ProfileId:  ours.worker_id,
+				CompanyId:  ours.company_id,
+				ContractId: ours.contract_id,
+				Role:       model.RoleAdmin,
+			})
+			return err
+		}, ErrRoleNotGrantable},
+		{"reset their PIN", func() error {
+			_, err := auth.ResetPin(ctx, db, theirs.worker_id)
+			return err
+		}, auth.ErrProfileNotFound},
+		{"unlock their worker", func() error {
+			_, err := auth.UnlockProfile(ctx, db, theirs.worker_id)
+			return err
+		}, auth.ErrProfileNotFound},
+		{"revoke their sessions", func() error {
+			_, err := auth.RevokeProfileSessions(ctx, db, theirs.worker_id)
+			return err
+		}, auth.ErrProfileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// none of their rows were changed or deleted
+	unchanged := []struct {
+		query string
+		args  []any
+	}{
+		{`SELECT count(*) FROM workspace WHERE id = $1 AND name = 'Workspace 2'`, []any{theirs.workspace_id}},
+		{`SELECT count(*) FROM company WHERE id = $1 AND name = 'Company 2'`, []any{theirs.company_id}},
+		{`SELECT count(*) FROM location WHERE id = $1 AND name = 'Site'`, []any{theirs.location_id}},
+		{`SELECT count(*) FROM task WHERE id = $1 AND NOT is_completed`, []any{theirs.task_id}},
+		{`SELECT count(*) FROM task WHERE id = $1 AND location_id = $2`, []any{ours.task_id, ours.location_id}},
+		{`SELECT count(*) FROM contract_version WHERE contract_id = $1 AND hourly_rate = 100`, []any{theirs.contract_id}},
+		{`SELECT count(*) FROM employment WHERE id = $1 AND role = 'worker'`, []any{theirs.employment_id}},
+		{`SELECT count(*) FROM profile WHERE id = $1 AND first_name = 'Worker'`, []any{theirs.worker_id}},
+		{`SELECT count(*) FROM profile_pin_auth WHERE profile_id = $1 AND NOT must_change`, []any{theirs.worker_id}},
+		{`SELECT count(*) FROM shift WHERE id = $1 AND NOT s_flagged`, []any{theirs.shift_id}},
+		{`SELECT count(*) FROM employment WHERE profile_id = $1`, []any{theirs.worker_id}},
+		{`SELECT count(*) FROM employment WHERE company_id = $1`, []any{theirs.company_id}},
+	}
+	for _, u := range unchanged {
+		if n := dbtest.QueryInt(t, db, u.query, u.args...); n != 1 {
+			t.Errorf("%s %v: %d rows, want 1", u.query, u.args, n)
+		}
+	}
+
+	// and the hire into their company was rolled back with its profile
+	if n := dbtest.QueryInt(t, db, `SELECT count(*) FROM profile WHERE kt = '0303302999'`); n != 0 {
+		t.Errorf("hired profile was kept")
+	}
+}
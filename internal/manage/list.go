@@ -0,0 +1,175 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Page is one page of a list. NextCursor is passed back as cursor to get the
+// page after it and is nil on the last page. Total counts everything matching
+// the filters, not only this page.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int     `json:"total"`
+}
+
+// PageParams are the paging and sorting parameters every list takes. Sort is
+// one of the list's sort keys, prefixed with - for descending order.
+type PageParams struct {
+	Limit  int
+	Cursor string
+	Sort   string
+}
+
+// sortKey is an expression a list can be sorted by and the type its value is
+// cast back to from a cursor.
+type sortKey struct {
+	expr string
+	typ  string
+}
+
+// listQuery describes a list. From is everything after FROM, including a
+// WHERE clause using Args. Rows are sorted by the sort key and then by Id so
+// the order is total and a page can pick up right after the last row of the
+// page before it.
+type listQuery struct {
+	Columns     string
+	From        string
+	Args        []any
+	Id          string
+	Sorts       map[string]sortKey
+	DefaultSort string
+}
+
+// cursor marks the last row of a page, as the text of its sort value and its
+// id. The sort is kept so a cursor can't be used with another order.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Id    int    `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// listPage runs q for one page. fields returns the scan destinations of an
+// item, in the order of q.Columns.
+func listPage[T any](
+	ctx context.Context,
+	db *sql.DB,
+	q listQuery,
+	params PageParams,
+	fields func(*T) []any,
+) (*Page[T], error) {
+	sort := params.Sort
+	if sort == "" {
+		sort = q.DefaultSort
+	}
+	desc := strings.HasPrefix(sort, "-")
+	key, ok := q.Sorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		return nil, ErrInvalidLimit
+	}
+
+	page := Page[T]{Items: []T{}}
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT count(*) FROM `+q.From,
+		q.Args...,
+	).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("listPage: db count: %w", err)
+	}
+
+	direction, compare := "ASC", ">"
+	if desc {
+		direction, compare = "DESC", "<"
+	}
+
+	args := append([]any{}, q.Args...)
+	query := fmt.Sprintf(
+		`SELECT %s, (%s)::text, %s FROM %s`,
+		q.Columns, key.expr, q.Id, q.From,
+	)
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != sort {
+			return nil, ErrInvalidCursor
+		}
+		query += fmt.Sprintf(
+			` AND ((%s), %s) %s ($%d::%s, $%d)`,
+			key.expr, q.Id, compare, len(args)+1, key.typ, len(args)+2,
+		)
+		args = append(args, c.Value, c.Id)
+	}
+	// one more than asked for tells whether there is a next page
+	query += fmt.Sprintf(
+		` ORDER BY (%s) %s, %s %s LIMIT %d`,
+		key.expr, direction, q.Id, direction, limit+1,
+	)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listPage: db select: %w", err)
+	}
+	defer rows.Close()
+
+	var last cursor
+	for rows.Next() {
+		if len(page.Items) == limit {
+			next := encodeCursor(last)
+			page.NextCursor = &next
+			break
+		}
+
+		var item T
+		last = cursor{Sort: sort}
+		err = rows.Scan(append(fields(&item), &last.Value, &last.Id)...)
+		if err != nil {
+			return nil, fmt.Errorf("listPage: db scan: %w", err)
+		}
+
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listPage: rows: %w", err)
+	}
+
+	return &page, nil
+}
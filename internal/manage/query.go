@@ -0,0 +1,88 @@
+package manage
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// queryParams reads optional filters from a query string. Parameters left out
+// read as nil, and the first one that doesn't parse is kept in err.
+type queryParams struct {
+	values url.Values
+	err    error
+}
+
+func newQueryParams(r *http.Request) *queryParams {
+	return &queryParams{values: r.URL.Query()}
+}
+
+func (q *queryParams) String(name string) *string {
+	s := q.values.Get(name)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (q *queryParams) Int(name string) *int {
+	s := q.values.Get(name)
+	if s == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(s)
+	if err != nil {
+		q.fail(fmt.Errorf("%s must be an integer", name))
+		return nil
+	}
+	return &parsed
+}
+
+func (q *queryParams) Bool(name string) *bool {
+	s := q.values.Get(name)
+	if s == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(s)
+	if err != nil {
+		q.fail(fmt.Errorf("%s must be true or false", name))
+		return nil
+	}
+	return &parsed
+}
+
+func (q *queryParams) Date(name string) *time.Time {
+	s := q.values.Get(name)
+	if s == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		q.fail(fmt.Errorf("%s must be a date like 2006-01-02", name))
+		return nil
+	}
+	return &parsed
+}
+
+// Page reads limit, cursor and sort.
+func (q *queryParams) Page() PageParams {
+	page := PageParams{
+		Cursor: q.values.Get("cursor"),
+		Sort:   q.values.Get("sort"),
+	}
+	if limit := q.Int("limit"); limit != nil {
+		page.Limit = *limit
+		if *limit <= 0 {
+			q.fail(ErrInvalidLimit)
+		}
+	}
+	return page
+}
+
+func (q *queryParams) fail(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
@@ -0,0 +1,417 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"test/internal/auth"
+	"test/internal/model"
+	"test/internal/roster"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxRosterDays is the longest range a roster can cover.
+const maxRosterDays = 62
+
+// plannedShiftColumns are the columns of a planned shift p joined with its
+// task t, in the order of plannedShiftFields.
+const plannedShiftColumns = `
+	p.id, p.profile_id, p.task_id, t.location_id, p.start_ts, p.end_ts,
+	p.recurrence, to_char(p.repeat_until, 'YYYY-MM-DD'), p.note
+`
+
+func plannedShiftFields(plan *model.PlannedShift) []any {
+	return []any{
+		&plan.Id,
+		&plan.ProfileId,
+		&plan.TaskId,
+		&plan.LocationId,
+		&plan.StartTs,
+		&plan.EndTs,
+		&plan.Recurrence,
+		&plan.RepeatUntil,
+		&plan.Note,
+	}
+}
+
+// plannedShiftCheckError returns the domain error for a check violation of
+// planned_shift, or nil when err is something else.
+func plannedShiftCheckError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23514" {
+		return ErrInvalidPlannedShift
+	}
+	return nil
+}
+
+// checkFitsRepeat checks that the planned shift ends before it repeats, in
+// the time zone of its task's workspace.
+func checkFitsRepeat(ctx context.Context, tx *sql.Tx, plan model.PlannedShift) error {
+	var time_zone string
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT COALESCE(w.time_zone, 'UTC')
+		FROM task t
+		JOIN location l ON l.id = t.location_id
+		LEFT JOIN workspace w ON w.id = l.workspace_id
+		WHERE t.id = $1
+		`,
+		plan.TaskId,
+	).Scan(&time_zone)
+	if err != nil {
+		return fmt.Errorf("checkFitsRepeat: db select: %w", err)
+	}
+
+	loc, err := time.LoadLocation(time_zone)
+	if err != nil {
+		return fmt.Errorf("checkFitsRepeat: load location: %w", err)
+	}
+	if !roster.FitsRepeat(plan, loc) {
+		return ErrPlannedShiftTooLong
+	}
+	return nil
+}
+
+// checkPlannedTask checks that the task is in one of the caller's companies
+// and that the profile is employed at its company.
+func checkPlannedTask(
+	ctx context.Context,
+	tx *sql.Tx,
+	task_id int,
+	profile_id int,
+	manager_id int,
+) error {
+	var task_ok, employed bool
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT
+			EXISTS (
+				SELECT 1 FROM task
+				WHERE id = $1 AND company_id IN (`+managedCompanies(3)+`)
+			),
+			EXISTS (
+				SELECT 1
+				FROM employment e
+				JOIN task t ON t.company_id = e.company_id
+				WHERE t.id = $1
+				AND e.profile_id = $2
+				AND (e.end_date IS NULL OR e.end_date > now())
+			)
+		`,
+		task_id,
+		profile_id,
+		manager_id,
+	).Scan(&task_ok, &employed)
+	if err != nil {
+		return fmt.Errorf("checkPlannedTask: db select: %w", err)
+	}
+	if !task_ok {
+		return ErrTaskNotFound
+	}
+	if !employed {
+		return ErrProfileNotEmployed
+	}
+	return nil
+}
+
+func CreatePlannedShift(
+	ctx context.Context,
+	db *sql.DB,
+	input PlannedShiftCreate,
+) (*model.PlannedShift, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePlannedShift: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = checkPlannedTask(ctx, tx, input.TaskId, input.ProfileId, claims.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan model.PlannedShift
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		WITH p AS (
+			INSERT INTO planned_shift (profile_id, task_id, start_ts, end_ts, recurrence, repeat_until, note)
+			VALUES ($1, $2, $3, $4, COALESCE($5, 'none'), $6::date, $7)
+			RETURNING *
+		)
+		SELECT `+plannedShiftColumns+`
+		FROM p
+		JOIN task t ON t.id = p.task_id
+		`,
+		input.ProfileId,
+		input.TaskId,
+		input.StartTs,
+		input.EndTs,
+		input.Recurrence,
+		input.RepeatUntil,
+		input.Note,
+	).Scan(plannedShiftFields(&plan)...)
+	if err != nil {
+		if check := plannedShiftCheckError(err); check != nil {
+			return nil, check
+		}
+		return nil, fmt.Errorf("CreatePlannedShift: db insert: %w", err)
+	}
+
+	if err := checkFitsRepeat(ctx, tx, plan); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("CreatePlannedShift: db commit: %w", err)
+	}
+
+	return &plan, nil
+}
+
+func GetPlannedShifts(
+	ctx context.Context,
+	db *sql.DB,
+	filter PlannedShiftFilter,
+	page PageParams,
+) (*Page[model.PlannedShift], error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	return listPage(ctx, db, listQuery{
+		Columns: plannedShiftColumns,
+		From: `
+			planned_shift p
+			JOIN task t ON t.id = p.task_id
+			WHERE t.company_id IN (` + managedCompanies(1) + `)
+			AND ($2::int IS NULL OR p.profile_id = $2)
+			AND ($3::int IS NULL OR p.task_id = $3)
+			AND ($4::int IS NULL OR t.location_id = $4)
+			AND ($5::int IS NULL OR t.company_id = $5)
+		`,
+		Args: []any{
+			claims.ProfileID,
+			filter.ProfileId,
+			filter.TaskId,
+			filter.LocationId,
+			filter.CompanyId,
+		},
+		Id: "p.id",
+		Sorts: map[string]sortKey{
+			"id":       {"p.id", "int"},
+			"start_ts": {"p.start_ts", "timestamptz"},
+		},
+		DefaultSort: "start_ts",
+	}, page, plannedShiftFields)
+}
+
+func PatchPlannedShift(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	patch PlannedShiftPatch,
+) (*model.PlannedShift, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if err := validateRecurrence(patch.Recurrence, patch.RepeatUntil); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PatchPlannedShift: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if patch.TaskId != nil {
+		var profile_id int
+		err = tx.QueryRowContext(
+			ctx,
+			`SELECT profile_id FROM planned_shift WHERE id = $1`,
+			id,
+		).Scan(&profile_id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrPlannedShiftNotFound
+			}
+			return nil, fmt.Errorf("PatchPlannedShift: db select: %w", err)
+		}
+
+		err = checkPlannedTask(ctx, tx, *patch.TaskId, profile_id, claims.ProfileID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := "UPDATE planned_shift SET "
+	args := []any{}
+	i := 1
+
+	if patch.TaskId != nil {
+		query += fmt.Sprintf("task_id = $%d,", i)
+		args = append(args, *patch.TaskId)
+		i++
+	}
+	if patch.StartTs != nil {
+		query += fmt.Sprintf("start_ts = $%d,", i)
+		args = append(args, *patch.StartTs)
+		i++
+	}
+	if patch.EndTs != nil {
+		query += fmt.Sprintf("end_ts = $%d,", i)
+		args = append(args, *patch.EndTs)
+		i++
+	}
+	if patch.Recurrence != nil {
+		query += fmt.Sprintf("recurrence = $%d,", i)
+		args = append(args, *patch.Recurrence)
+		i++
+	}
+	if patch.RepeatUntil != nil {
+		query += fmt.Sprintf("repeat_until = NULLIF($%d, '')::date,", i)
+		args = append(args, *patch.RepeatUntil)
+		i++
+	}
+	if patch.Note != nil {
+		query += fmt.Sprintf("note = $%d,", i)
+		args = append(args, *patch.Note)
+		i++
+	}
+
+	if len(args) == 0 {
+		return nil, ErrNoFieldsToUpdate
+	}
+
+	query = strings.TrimSuffix(query, ",")
+	query = fmt.Sprintf(`
+		WITH p AS (
+			%s
+			WHERE id = $%d
+			AND task_id IN (
+				SELECT id FROM task WHERE company_id IN (`+managedCompanies(i+1)+`)
+			)
+			RETURNING *
+		)
+		SELECT `+plannedShiftColumns+`
+		FROM p
+		JOIN task t ON t.id = p.task_id
+	`, query, i)
+	args = append(args, id, claims.ProfileID)
+
+	plan := model.PlannedShift{}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(plannedShiftFields(&plan)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPlannedShiftNotFound
+		}
+		if check := plannedShiftCheckError(err); check != nil {
+			return nil, check
+		}
+		return nil, fmt.Errorf("PatchPlannedShift: %w", err)
+	}
+
+	if err := checkFitsRepeat(ctx, tx, plan); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PatchPlannedShift: db commit: %w", err)
+	}
+
+	return &plan, nil
+}
+
+func DeletePlannedShift(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) (int64, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	result, err := db.ExecContext(
+		ctx,
+		`
+		DELETE FROM planned_shift
+		WHERE id = $1
+		AND task_id IN (
+			SELECT id FROM task WHERE company_id IN (`+managedCompanies(2)+`)
+		)
+		`,
+		id,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("DeletePlannedShift: db delete: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("DeletePlannedShift: rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return 0, ErrPlannedShiftNotFound
+	}
+
+	return rows, nil
+}
+
+// GetRoster returns the occurrences of the planned shifts in the caller's
+// companies between the dates of the filter, a week from today by default,
+// next to the shifts actually worked for them.
+func GetRoster(
+	ctx context.Context,
+	db *sql.DB,
+	filter RosterFilter,
+) (*RosterResponse, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today, today.AddDate(0, 0, 7)
+	if filter.From != nil {
+		from = *filter.From
+		to = from.AddDate(0, 0, 7)
+	}
+	if filter.To != nil {
+		to = *filter.To
+	}
+	if !to.After(from) || to.After(from.AddDate(0, 0, maxRosterDays)) {
+		return nil, ErrInvalidRosterRange
+	}
+
+	occurrences, err := roster.GetOccurrences(
+		ctx,
+		db,
+		from,
+		to,
+		`
+		t.company_id IN (`+managedCompanies(3)+`)
+		AND ($4::int IS NULL OR p.profile_id = $4)
+		AND ($5::int IS NULL OR p.task_id = $5)
+		AND ($6::int IS NULL OR t.location_id = $6)
+		AND ($7::int IS NULL OR t.company_id = $7)
+		`,
+		claims.ProfileID,
+		filter.ProfileId,
+		filter.TaskId,
+		filter.LocationId,
+		filter.CompanyId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RosterResponse{
+		From:        from,
+		To:          to,
+		Occurrences: occurrences,
+	}, nil
+}
@@ -1,18 +1,23 @@
 package manage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"test/internal/abstractions"
+	"test/internal/auth"
+	"test/internal/model"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func CreateWorkspaceHandler(db *sql.DB) http.HandlerFunc {
-	return abstractions.CreateJSONHandler(db, CreateWorkspace, WriteDomainError)
+	return abstractions.CreateJSONHandler(db, CreateWorkspace, WriteDomainError, ValidateWorkspaceCreate)
 }
 
 func CreateCompanyHandler(db *sql.DB) http.HandlerFunc {
@@ -20,7 +25,7 @@ func CreateCompanyHandler(db *sql.DB) http.HandlerFunc {
 }
 
 func CreateLocationHandler(db *sql.DB) http.HandlerFunc {
-	return abstractions.CreateJSONHandler(db, CreateLocation, WriteDomainError)
+	return abstractions.CreateJSONHandler(db, CreateLocation, WriteDomainError, ValidateLocationCreate)
 }
 
 func CreateTaskHandler(db *sql.DB) http.HandlerFunc {
@@ -31,13 +36,50 @@ func CreateEmploymentHandler(db *sql.DB) http.HandlerFunc {
 	return abstractions.CreateJSONHandler(db, CreateEmployment, WriteDomainError)
 }
 
+func CreateProfileHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, CreateProfile, writeProfileError, ValidateProfileHire)
+}
+
+// writeProfileError also knows the errors of the auth package, which checks
+// the PIN and email of a new profile.
+func writeProfileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrInvalidPin) || errors.Is(err, auth.ErrEmailRequired) {
+		auth.WriteDomainError(w, err)
+		return
+	}
+	WriteDomainError(w, err)
+}
+
 func CreateContractHandler(db *sql.DB) http.HandlerFunc {
-	return abstractions.CreateJSONHandler(db, CreateContract, WriteDomainError)
+	return abstractions.CreateJSONHandler(db, CreateContract, WriteDomainError, ValidateContractCreate)
+}
+
+func CreatePlannedShiftHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, CreatePlannedShift, WriteDomainError, ValidatePlannedShiftCreate)
+}
+
+func CreatePayRuleHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, CreatePayRule, WriteDomainError, ValidatePayRuleCreate)
+}
+
+func CreateHolidayHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, CreateHoliday, WriteDomainError, ValidateHolidayCreate)
+}
+
+func CreateBreakTypeHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(db, CreateBreakType, WriteDomainError)
 }
 
 func GetWorkspacesHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetWorkspaces(r.Context(), db)
+		params := newQueryParams(r)
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetWorkspaces(r.Context(), db, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -50,7 +92,17 @@ func GetWorkspacesHandler(db *sql.DB) http.HandlerFunc {
 
 func GetCompaniesHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetCompanies(r.Context(), db)
+		params := newQueryParams(r)
+		filter := CompanyFilter{
+			WorkspaceId: params.Int("workspace_id"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetCompanies(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -63,7 +115,17 @@ func GetCompaniesHandler(db *sql.DB) http.HandlerFunc {
 
 func GetLocationsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetLocations(r.Context(), db)
+		params := newQueryParams(r)
+		filter := LocationFilter{
+			WorkspaceId: params.Int("workspace_id"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetLocations(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -76,7 +138,19 @@ func GetLocationsHandler(db *sql.DB) http.HandlerFunc {
 
 func GetTasksHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetTasks(r.Context(), db)
+		params := newQueryParams(r)
+		filter := TaskFilter{
+			CompanyId: params.Int("company_id"),
+			LocationId: params.Int("location_id"),
+			IsCompleted: params.Bool("is_completed"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetTasks(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -89,7 +163,18 @@ func GetTasksHandler(db *sql.DB) http.HandlerFunc {
 
 func GetProfilesHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetProfiles(r.Context(), db)
+		params := newQueryParams(r)
+		filter := ProfileFilter{
+			Name: params.String("name"),
+			KT: params.String("kt"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetProfiles(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -102,7 +187,22 @@ func GetProfilesHandler(db *sql.DB) http.HandlerFunc {
 
 func GetEmploymentsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetEmployments(r.Context(), db)
+		params := newQueryParams(r)
+		filter := EmploymentFilter{
+			ProfileId: params.Int("profile_id"),
+			CompanyId: params.Int("company_id"),
+			Active: params.Bool("active"),
+		}
+		if role := params.String("role"); role != nil {
+			filter.Role = (*model.Role)(role)
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetEmployments(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -115,7 +215,40 @@ func GetEmploymentsHandler(db *sql.DB) http.HandlerFunc {
 
 func GetContractsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetContracts(r.Context(), db)
+		params := newQueryParams(r)
+		filter := ContractFilter{
+			WorkspaceId: params.Int("workspace_id"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetContracts(r.Context(), db, filter, page)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetBreakTypesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := BreakTypeFilter{
+			ContractId: params.Int("contract_id"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetBreakTypes(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -128,7 +261,15 @@ func GetContractsHandler(db *sql.DB) http.HandlerFunc {
 
 func GetShiftsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetShifts(r.Context(), db)
+		params := newQueryParams(r)
+		filter := parseShiftFilter(params)
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetShifts(r.Context(), db, filter, page)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -139,6 +280,54 @@ func GetShiftsHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+func GetFlaggedShiftsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := parseShiftFilter(params)
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetFlaggedShifts(r.Context(), db, filter, page)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// parseShiftFilter reads the filters of the shift lists. status is open or
+// closed, from and to are dates bounding the start of the shift.
+func parseShiftFilter(params *queryParams) ShiftFilter {
+	filter := ShiftFilter{
+		ProfileId: params.Int("profile_id"),
+		TaskId: params.Int("task_id"),
+		LocationId: params.Int("location_id"),
+		CompanyId: params.Int("company_id"),
+		From: params.Date("from"),
+		To: params.Date("to"),
+	}
+
+	switch params.values.Get("status") {
+	case "":
+	case "open":
+		open := true
+		filter.Open = &open
+	case "closed":
+		open := false
+		filter.Open = &open
+	default:
+		params.fail(fmt.Errorf("status must be open or closed"))
+	}
+
+	return filter
+}
+
 func DeleteWorkspaceHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
@@ -253,6 +442,25 @@ func DeleteContractHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+func DeleteBreakTypeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := DeleteBreakType(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
 func DeleteShiftHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
@@ -434,6 +642,33 @@ func PatchContractHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+func PatchBreakTypeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var input BreakTypePatch
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			fmt.Printf("Decode error: %v\n", err)
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		result, err := PatchBreakType(r.Context(), db, id, input)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
 func PatchProfileHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
@@ -487,3 +722,390 @@ func PatchShiftHandler(db *sql.DB) http.HandlerFunc {
 		json.NewEncoder(w).Encode(result)
 	}
 }
+
+func GetEditRequestsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := EditRequestFilter{
+			ProfileId: params.Int("profile_id"),
+			CompanyId: params.Int("company_id"),
+		}
+		if status := params.String("status"); status != nil {
+			filter.Status = (*model.RequestStatus)(status)
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetEditRequests(r.Context(), db, filter, page)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func ApproveEditRequestHandler(db *sql.DB) http.HandlerFunc {
+	return reviewEditRequestHandler(db, ApproveEditRequest)
+}
+
+func RejectEditRequestHandler(db *sql.DB) http.HandlerFunc {
+	return reviewEditRequestHandler(db, RejectEditRequest)
+}
+
+func reviewEditRequestHandler(
+	db *sql.DB,
+	review func(context.Context, *sql.DB, int, EditRequestReview) (*model.EditRequest, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		// the comment is optional, so is the body
+		var input EditRequestReview
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+				fmt.Printf("Decode error: %v\n", err)
+				http.Error(w, "invalid body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		result, err := review(r.Context(), db, id, input)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetEmploymentEarningsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var from, to *time.Time
+
+		if s_from := r.URL.Query().Get("from"); s_from != "" {
+			parsed, err := time.Parse(time.DateOnly, s_from)
+			if err != nil {
+				http.Error(w, "from must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			from = &parsed
+		}
+
+		// to is exclusive
+		if s_to := r.URL.Query().Get("to"); s_to != "" {
+			parsed, err := time.Parse(time.DateOnly, s_to)
+			if err != nil {
+				http.Error(w, "to must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			to = &parsed
+		}
+
+		result, err := GetEmploymentEarnings(r.Context(), db, id, from, to)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetPayRulesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := PayRuleFilter{
+			ContractId: params.Int("contract_id"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetPayRules(r.Context(), db, filter, page)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetHolidaysHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := HolidayFilter{
+			WorkspaceId: params.Int("workspace_id"),
+			From: params.Date("from"),
+			To: params.Date("to"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetHolidays(r.Context(), db, filter, page)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func DeletePayRuleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := DeletePayRule(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func DeleteHolidayHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := DeleteHoliday(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func PatchPayRuleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var input PayRulePatch
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			fmt.Printf("Decode error: %v\n", err)
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		result, err := PatchPayRule(r.Context(), db, id, input)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func ScheduleContractVersionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var input ContractVersionCreate
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			fmt.Printf("Decode error: %v\n", err)
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		result, err := ScheduleContractVersion(r.Context(), db, id, input)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetContractVersionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetContractVersions(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func DeleteContractVersionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := DeleteContractVersion(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetPlannedShiftsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := PlannedShiftFilter{
+			ProfileId: params.Int("profile_id"),
+			TaskId: params.Int("task_id"),
+			LocationId: params.Int("location_id"),
+			CompanyId: params.Int("company_id"),
+		}
+		page := params.Page()
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetPlannedShifts(r.Context(), db, filter, page)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func GetRosterHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := newQueryParams(r)
+		filter := RosterFilter{
+			ProfileId: params.Int("profile_id"),
+			TaskId: params.Int("task_id"),
+			LocationId: params.Int("location_id"),
+			CompanyId: params.Int("company_id"),
+			From: params.Date("from"),
+			To: params.Date("to"),
+		}
+		if params.err != nil {
+			http.Error(w, params.err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := GetRoster(r.Context(), db, filter)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func DeletePlannedShiftHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		result, err := DeletePlannedShift(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func PatchPlannedShiftHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var input PlannedShiftPatch
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			fmt.Printf("Decode error: %v\n", err)
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		result, err := PatchPlannedShift(r.Context(), db, id, input)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
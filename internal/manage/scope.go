@@ -0,0 +1,89 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/model"
+)
+
+// Every manage query is limited to what the caller may manage, see auth's
+// scope subqueries.
+var (
+	managedWorkspaces = auth.ManagedWorkspaces
+	ownedWorkspaces   = auth.OwnedWorkspaces
+	managedCompanies  = auth.ManagedCompanies
+	managedProfiles   = auth.ManagedProfiles
+)
+
+// roleRank orders the roles, a higher rank may do more.
+var roleRank = map[model.Role]int{
+	model.RoleWorker:  1,
+	model.RoleManager: 2,
+	model.RoleAdmin:   3,
+	model.RoleOwner:   4,
+}
+
+// callerRole returns the highest role the profile holds over the company:
+// its own role at the company, or an owner or admin role in its workspace.
+// It returns ErrCompanyNotFound when the profile doesn't manage the company.
+func callerRole(
+	ctx context.Context,
+	tx *sql.Tx,
+	company_id int,
+	profile_id int,
+) (model.Role, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`
+		SELECT e.role
+		FROM employment e
+		LEFT JOIN company ec ON ec.id = e.company_id
+		JOIN company c ON c.id = $1
+		WHERE e.profile_id = $2
+		AND e.role IN ('owner', 'admin', 'manager')
+		AND (e.company_id = c.id
+			OR (e.role IN ('owner', 'admin') AND c.workspace_id = COALESCE(ec.workspace_id, e.workspace_id)))
+		AND (e.start_date IS NULL OR e.start_date <= now())
+		AND (e.end_date IS NULL OR e.end_date > now())
+		`,
+		company_id,
+		profile_id,
+	)
+	if err != nil {
+		return "", fmt.Errorf("callerRole: db select: %w", err)
+	}
+	defer rows.Close()
+
+	var role model.Role
+	for rows.Next() {
+		var r model.Role
+		if err := rows.Scan(&r); err != nil {
+			return "", fmt.Errorf("callerRole: db scan: %w", err)
+		}
+		if roleRank[r] > roleRank[role] {
+			role = r
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("callerRole: rows: %w", err)
+	}
+
+	if role == "" {
+		return "", ErrCompanyNotFound
+	}
+	return role, nil
+}
+
+// checkGrantableRole checks that role is a role at all and that the caller,
+// holding caller_role, may grant it: no one hands out more than they have.
+func checkGrantableRole(role model.Role, caller_role model.Role) error {
+	if roleRank[role] == 0 {
+		return ErrInvalidRole
+	}
+	if roleRank[role] > roleRank[caller_role] {
+		return ErrRoleNotGrantable
+	}
+	return nil
+}
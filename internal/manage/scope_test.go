@@ -0,0 +1,32 @@
+package manage
+
+import (
+	"errors"
+	"test/internal/model"
+	"testing"
+)
+
+func TestCheckGrantableRole(t *testing.T) {
+	tests := []struct {
+		role   model.Role
+		caller model.Role
+		want   error
+	}{
+		{model.RoleWorker, model.RoleManager, nil},
+		{model.RoleManager, model.RoleManager, nil},
+		{model.RoleAdmin, model.RoleManager, ErrRoleNotGrantable},
+		{model.RoleOwner, model.RoleManager, ErrRoleNotGrantable},
+		{model.RoleOwner, model.RoleAdmin, ErrRoleNotGrantable},
+		{model.RoleAdmin, model.RoleAdmin, nil},
+		{model.RoleOwner, model.RoleOwner, nil},
+		{"superuser", model.RoleOwner, ErrInvalidRole},
+		{"", model.RoleOwner, ErrInvalidRole},
+	}
+
+	for _, tt := range tests {
+		err := checkGrantableRole(tt.role, tt.caller)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("checkGrantableRole(%q, %q) = %v, want %v", tt.role, tt.caller, err, tt.want)
+		}
+	}
+}
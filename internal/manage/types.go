@@ -1,12 +1,19 @@
 package manage
 
 import (
+	"test/internal/auth"
 	"test/internal/model"
+	"test/internal/roster"
 	"time"
 )
 
 type WorkspaceCreate struct {
 	Name string `json:"name"`
+	GeofencePolicy *model.GeofencePolicy `json:"geofence_policy"`
+	MaxShiftMinutes *int `json:"max_shift_minutes"`
+	AutoCloseEditRequest bool `json:"auto_close_edit_request"`
+	PayPeriodStartDay *int `json:"pay_period_start_day"`
+	TimeZone *string `json:"time_zone"`
 }
 
 type CompanyCreate struct {
@@ -18,6 +25,9 @@ type LocationCreate struct {
 	Name        string `json:"name"`
 	Address     string `json:"address"`
 	WorkspaceId int `json:"workspace_id"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	RadiusM     *int `json:"radius_m"`
 }
 
 type TaskCreate struct {
@@ -35,13 +45,68 @@ type EmploymentCreate struct {
 	Role 	   model.Role `json:"role"`
 }
 
+// ProfileHire is a new profile along with the employment it is hired into.
+type ProfileHire struct {
+	auth.ProfileCreate
+	CompanyId  int        `json:"company_id"`
+	ContractId int        `json:"contract_id"`
+	Role       model.Role `json:"role"`
+}
+
+type HiredProfile struct {
+	model.Profile
+	Employment model.Employment `json:"employment"`
+}
+
 type ContractCreate struct {
-	HourlyRate         int `json:"hourly_rate"`
-	UnpaidLunchMinutes int `json:"unpaid_lunch_minutes"`
+	WorkspaceId           int      `json:"workspace_id"`
+	HourlyRate            int      `json:"hourly_rate"`
+	UnpaidLunchMinutes    int      `json:"unpaid_lunch_minutes"`
+	DailyOvertimeMinutes  *int     `json:"daily_overtime_minutes"`
+	WeeklyOvertimeMinutes *int     `json:"weekly_overtime_minutes"`
+	OvertimeMultiplier    *float64 `json:"overtime_multiplier"`
+}
+
+type PayRuleCreate struct {
+	ContractId  int               `json:"contract_id"`
+	Name        string            `json:"name"`
+	Kind        model.PayRuleKind `json:"kind"`
+	DaysMask    *int              `json:"days_mask"`
+	StartMinute *int              `json:"start_minute"`
+	EndMinute   *int              `json:"end_minute"`
+	Multiplier  float64           `json:"multiplier"`
+}
+
+type HolidayCreate struct {
+	WorkspaceId int    `json:"workspace_id"`
+	Date        string `json:"date"`
+	Name        string `json:"name"`
+}
+
+type PlannedShiftCreate struct {
+	ProfileId   int               `json:"profile_id"`
+	TaskId      int               `json:"task_id"`
+	StartTs     time.Time         `json:"start_ts"`
+	EndTs       time.Time         `json:"end_ts"`
+	Recurrence  *model.Recurrence `json:"recurrence"`
+	RepeatUntil *string           `json:"repeat_until"`
+	Note        *string           `json:"note"`
+}
+
+type BreakTypeCreate struct {
+	ContractId  int    `json:"contract_id"`
+	Name        string `json:"name"`
+	Paid        bool   `json:"paid"`
+	MaxMinutes *int    `json:"max_minutes"`
 }
 
 type WorkspacePatch struct {
 	Name *string `json:"name"`
+	GeofencePolicy *model.GeofencePolicy `json:"geofence_policy"`
+	MaxShiftMinutes *int `json:"max_shift_minutes"`
+	AutoCloseEditRequest *bool `json:"auto_close_edit_request"`
+	PayPeriodStartDay *int `json:"pay_period_start_day"`
+	TimeZone *string `json:"time_zone"`
 }
 
 type CompanyPatch struct {
@@ -51,6 +116,9 @@ type CompanyPatch struct {
 type LocationPatch struct {
     Name    *string `json:"name"`
     Address *string `json:"address"`
+    Latitude  *float64 `json:"latitude"`
+    Longitude *float64 `json:"longitude"`
+    RadiusM   *int `json:"radius_m"`
 }
 
 type TaskPatch struct {
@@ -68,12 +136,50 @@ type EmploymentPatch struct {
 type ContractPatch struct {
     HourlyRate         *int `json:"hourly_rate"`
     UnpaidLunchMinutes *int `json:"unpaid_lunch_minutes"`
+    DailyOvertimeMinutes  *int     `json:"daily_overtime_minutes"`
+    WeeklyOvertimeMinutes *int     `json:"weekly_overtime_minutes"`
+    OvertimeMultiplier    *float64 `json:"overtime_multiplier"`
+}
+
+// ContractVersionCreate schedules new terms for a contract. Terms left out
+// are kept as they are at effective_from.
+type ContractVersionCreate struct {
+	EffectiveFrom time.Time `json:"effective_from"`
+	ContractPatch
+}
+
+type PayRulePatch struct {
+	Name        *string  `json:"name"`
+	DaysMask    *int     `json:"days_mask"`
+	StartMinute *int     `json:"start_minute"`
+	EndMinute   *int     `json:"end_minute"`
+	Multiplier  *float64 `json:"multiplier"`
+}
+
+// PlannedShiftPatch changes a planned shift. An empty repeat_until makes a
+// recurring shift repeat forever.
+type PlannedShiftPatch struct {
+	TaskId      *int              `json:"task_id"`
+	StartTs     *time.Time        `json:"start_ts"`
+	EndTs       *time.Time        `json:"end_ts"`
+	Recurrence  *model.Recurrence `json:"recurrence"`
+	RepeatUntil *string           `json:"repeat_until"`
+	Note        *string           `json:"note"`
+}
+
+type BreakTypePatch struct {
+	Name       *string `json:"name"`
+	Paid       *bool   `json:"paid"`
+	MaxMinutes *int    `json:"max_minutes"`
 }
 
 type ShiftPatch struct {
 	StartTs *time.Time `json:"start_ts"`
 	EndTs   *time.Time `json:"end_ts"`
 	TaskId  *int       `json:"task_id"`
+	SFlagged *bool     `json:"s_flagged"`
+	EFlagged *bool     `json:"e_flagged"`
+	AutoClosed *bool   `json:"auto_closed"`
 }
 
 type ProfilePatch struct {
@@ -81,3 +187,107 @@ type ProfilePatch struct {
 	FirstName *string `json:"first_name"`
 	LastName  *string `json:"last_name"`
 }
+
+
+type FlaggedShift struct {
+	Shift    model.Shift    `json:"shift"`
+	Profile  model.Profile  `json:"profile"`
+	Location model.Location `json:"location"`
+}
+
+type EditRequestFilter struct {
+	Status    *model.RequestStatus
+	ProfileId *int
+	CompanyId *int
+}
+
+type EditRequestReview struct {
+	Comment *string `json:"comment"`
+}
+
+type EditRequestDetail struct {
+	EditRequest model.EditRequest `json:"edit_request"`
+	Shift       model.Shift       `json:"shift"`
+	Profile     model.Profile     `json:"profile"`
+}
+
+type CompanyFilter struct {
+	WorkspaceId *int
+}
+
+type LocationFilter struct {
+	WorkspaceId *int
+}
+
+type TaskFilter struct {
+	CompanyId   *int
+	LocationId  *int
+	IsCompleted *bool
+}
+
+type ProfileFilter struct {
+	Name *string
+	KT   *string
+}
+
+type EmploymentFilter struct {
+	ProfileId *int
+	CompanyId *int
+	Role      *model.Role
+	Active    *bool
+}
+
+type ContractFilter struct {
+	WorkspaceId *int
+}
+
+type BreakTypeFilter struct {
+	ContractId *int
+}
+
+type PayRuleFilter struct {
+	ContractId *int
+}
+
+type HolidayFilter struct {
+	WorkspaceId *int
+	From        *time.Time
+	To          *time.Time
+}
+
+type PlannedShiftFilter struct {
+	ProfileId  *int
+	TaskId     *int
+	LocationId *int
+	CompanyId  *int
+}
+
+// RosterFilter picks the planned shifts of the roster and the dates it
+// covers, To being the first day not included.
+type RosterFilter struct {
+	ProfileId  *int
+	TaskId     *int
+	LocationId *int
+	CompanyId  *int
+	From       *time.Time
+	To         *time.Time
+}
+
+// ShiftFilter narrows the shift lists. From and To bound the start of the
+// shift, Open picks only open or only closed shifts.
+type ShiftFilter struct {
+	ProfileId  *int
+	TaskId     *int
+	LocationId *int
+	CompanyId  *int
+	From       *time.Time
+	To         *time.Time
+	Open       *bool
+}
+
+// RosterResponse is the roster between From and To, To not included.
+type RosterResponse struct {
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	Occurrences []roster.Occurrence `json:"occurrences"`
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"test/internal/auth"
 	"test/internal/model"
+	"time"
 )
 
 func PatchWorkspace(
@@ -15,6 +17,21 @@ func PatchWorkspace(
 	id int,
 	patch WorkspacePatch,
 ) (*model.Workspace, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if err := validateGeofencePolicy(patch.GeofencePolicy); err != nil {
+		return nil, err
+	}
+	if err := validateMaxShiftMinutes(patch.MaxShiftMinutes); err != nil {
+		return nil, err
+	}
+	if err := validatePayPeriodStartDay(patch.PayPeriodStartDay); err != nil {
+		return nil, err
+	}
+	if err := validateTimeZone(patch.TimeZone); err != nil {
+		return nil, err
+	}
+
 	query := "UPDATE workspace SET "
 	args := []any{}
 	i := 1
@@ -24,27 +41,59 @@ func PatchWorkspace(
 		args = append(args, *patch.Name)
 		i++
 	}
+	if patch.GeofencePolicy != nil {
+		query += fmt.Sprintf("geofence_policy = $%d,", i)
+		args = append(args, *patch.GeofencePolicy)
+		i++
+	}
+	if patch.MaxShiftMinutes != nil {
+		// 0 removes the limit
+		query += fmt.Sprintf("max_shift_minutes = NULLIF($%d, 0),", i)
+		args = append(args, *patch.MaxShiftMinutes)
+		i++
+	}
+	if patch.AutoCloseEditRequest != nil {
+		query += fmt.Sprintf("auto_close_edit_request = $%d,", i)
+		args = append(args, *patch.AutoCloseEditRequest)
+		i++
+	}
+	if patch.PayPeriodStartDay != nil {
+		query += fmt.Sprintf("pay_period_start_day = $%d,", i)
+		args = append(args, *patch.PayPeriodStartDay)
+		i++
+	}
+	if patch.TimeZone != nil {
+		query += fmt.Sprintf("time_zone = $%d,", i)
+		args = append(args, *patch.TimeZone)
+		i++
+	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
-		RETURNING id, name
+		AND id IN (`+ownedWorkspaces(i+1)+`)
+		RETURNING id, name, geofence_policy, max_shift_minutes, auto_close_edit_request, pay_period_start_day, time_zone
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
 	workspace := model.Workspace{}
 	err := db.QueryRowContext(ctx, query, args...).Scan(
 		&workspace.Id,
 		&workspace.Name,
+		&workspace.GeofencePolicy,
+		&workspace.MaxShiftMinutes,
+		&workspace.AutoCloseEditRequest,
+		&workspace.PayPeriodStartDay,
+		&workspace.TimeZone,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("workspace not found")
+			return nil, ErrWorkspaceNotFound
 		}
 		return nil, fmt.Errorf("PatchWorkspace: %w", err)
 	}
@@ -58,6 +107,8 @@ func PatchCompany(
 	id int,
 	patch CompanyPatch,
 ) (*model.Company, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	query := "UPDATE company SET "
 	args := []any{}
 	i := 1
@@ -69,15 +120,16 @@ func PatchCompany(
 	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
+		AND id IN (`+managedCompanies(i+1)+`)
 		RETURNING id, name, workspace_id
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
 	company := model.Company{}
 	err := db.QueryRowContext(ctx, query, args...).Scan(
@@ -88,7 +140,7 @@ func PatchCompany(
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("company not found")
+			return nil, ErrCompanyNotFound
 		}
 		return nil, fmt.Errorf("PatchCompany: %w", err)
 	}
@@ -102,6 +154,12 @@ func PatchLocation(
 	id int,
 	patch LocationPatch,
 ) (*model.Location, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if err := validateGeofence(patch.Latitude, patch.Longitude, patch.RadiusM); err != nil {
+		return nil, err
+	}
+
 	query := "UPDATE location SET "
 	args := []any{}
 	i := 1
@@ -116,17 +174,33 @@ func PatchLocation(
 		args = append(args, *patch.Address)
 		i++
 	}
+	if patch.Latitude != nil {
+		query += fmt.Sprintf("latitude = $%d,", i)
+		args = append(args, *patch.Latitude)
+		i++
+	}
+	if patch.Longitude != nil {
+		query += fmt.Sprintf("longitude = $%d,", i)
+		args = append(args, *patch.Longitude)
+		i++
+	}
+	if patch.RadiusM != nil {
+		query += fmt.Sprintf("radius_m = $%d,", i)
+		args = append(args, *patch.RadiusM)
+		i++
+	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
-		RETURNING id, name, address, workspace_id
+		AND workspace_id IN (`+managedWorkspaces(i+1)+`)
+		RETURNING id, name, address, workspace_id, latitude, longitude, radius_m
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
 	location := model.Location{}
 	err := db.QueryRowContext(ctx, query, args...).Scan(
@@ -134,11 +208,14 @@ func PatchLocation(
 		&location.Name,
 		&location.Address,
 		&location.WorkspaceId,
+		&location.Latitude,
+		&location.Longitude,
+		&location.RadiusM,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("location not found")
+			return nil, ErrLocationNotFound
 		}
 		return nil, fmt.Errorf("PatchLocation: %w", err)
 	}
@@ -152,6 +229,32 @@ func PatchTask(
 	id int,
 	patch TaskPatch,
 ) (*model.Task, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if patch.LocationId != nil {
+		var ok bool
+		err := db.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1
+				FROM location l
+				JOIN company c ON c.workspace_id = l.workspace_id
+				JOIN task t ON t.company_id = c.id
+				WHERE l.id = $1 AND t.id = $2
+			)
+			`,
+			*patch.LocationId,
+			id,
+		).Scan(&ok)
+		if err != nil {
+			return nil, fmt.Errorf("PatchTask: db select: %w", err)
+		}
+		if !ok {
+			return nil, ErrLocationNotFound
+		}
+	}
+
 	query := "UPDATE task SET "
 	args := []any{}
 	i := 1
@@ -178,15 +281,16 @@ func PatchTask(
 	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
+		AND company_id IN (`+managedCompanies(i+1)+`)
 		RETURNING id, name, description, location_id, company_id, is_completed
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
 	task := model.Task{}
 	err := db.QueryRowContext(ctx, query, args...).Scan(
@@ -200,7 +304,7 @@ func PatchTask(
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("task not found")
+			return nil, ErrTaskNotFound
 		}
 		return nil, fmt.Errorf("PatchTask: %w", err)
 	}
@@ -214,6 +318,74 @@ func PatchEmployment(
 	id int,
 	patch EmploymentPatch,
 ) (*model.Employment, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PatchEmployment: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// neither the role the employment has nor the one it gets may be above
+	// the caller's
+	if patch.Role != nil {
+		var company_id int
+		var role model.Role
+		err = tx.QueryRowContext(
+			ctx,
+			`
+			SELECT company_id, role
+			FROM employment
+			WHERE id = $1
+			AND company_id IN (`+managedCompanies(2)+`)
+			FOR UPDATE
+			`,
+			id,
+			claims.ProfileID,
+		).Scan(&company_id, &role)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrEmploymentNotFound
+			}
+			return nil, fmt.Errorf("PatchEmployment: db select: %w", err)
+		}
+
+		manager_role, err := callerRole(ctx, tx, company_id, claims.ProfileID)
+		if err != nil {
+			return nil, err
+		}
+		if roleRank[role] > roleRank[manager_role] {
+			return nil, ErrRoleNotGrantable
+		}
+		if err := checkGrantableRole(*patch.Role, manager_role); err != nil {
+			return nil, err
+		}
+	}
+
+	if patch.ContractId != nil {
+		var ok bool
+		err := tx.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1
+				FROM contract ct
+				JOIN company c ON c.workspace_id = ct.workspace_id
+				JOIN employment e ON e.company_id = c.id
+				WHERE ct.id = $1 AND e.id = $2
+			)
+			`,
+			*patch.ContractId,
+			id,
+		).Scan(&ok)
+		if err != nil {
+			return nil, fmt.Errorf("PatchEmployment: db select: %w", err)
+		}
+		if !ok {
+			return nil, ErrContractNotFound
+		}
+	}
+
 	query := "UPDATE employment SET "
 	args := []any{}
 	i := 1
@@ -230,18 +402,19 @@ func PatchEmployment(
 	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
+		AND company_id IN (`+managedCompanies(i+1)+`)
 		RETURNING id, profile_id, company_id, contract_id, role, start_date, end_date
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
 	employment := model.Employment{}
-	err := db.QueryRowContext(ctx, query, args...).Scan(
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&employment.Id,
 		&employment.ProfileId,
 		&employment.CompanyId,
@@ -253,61 +426,108 @@ func PatchEmployment(
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("employment not found")
+			return nil, ErrEmploymentNotFound
 		}
 		return nil, fmt.Errorf("PatchEmployment: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PatchEmployment: db commit: %w", err)
+	}
+
 	return &employment, nil
 }
 
+// PatchContract changes the terms of a contract from now on. The terms in
+// force so far are kept as an earlier version, so pay for past shifts
+// doesn't change.
 func PatchContract(
 	ctx context.Context,
 	db *sql.DB,
 	id int,
 	patch ContractPatch,
 ) (*model.Contract, error) {
-	query := "UPDATE contract SET "
+	if err := validateOvertime(patch.DailyOvertimeMinutes, patch.WeeklyOvertimeMinutes, patch.OvertimeMultiplier); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PatchContract: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	contract, err := insertContractVersion(ctx, tx, id, time.Now(), patch)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PatchContract: db commit: %w", err)
+	}
+
+	return contract, nil
+}
+
+func PatchBreakType(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	patch BreakTypePatch,
+) (*model.BreakType, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	query := "UPDATE break_type SET "
 	args := []any{}
 	i := 1
 
-	if patch.HourlyRate != nil {
-		query += fmt.Sprintf("hourly_rate = $%d,", i)
-		args = append(args, *patch.HourlyRate)
+	if patch.Name != nil {
+		query += fmt.Sprintf("name = $%d,", i)
+		args = append(args, *patch.Name)
 		i++
 	}
-	if patch.UnpaidLunchMinutes != nil {
-		query += fmt.Sprintf("unpaid_lunch_minutes = $%d,", i)
-		args = append(args, *patch.UnpaidLunchMinutes)
+	if patch.Paid != nil {
+		query += fmt.Sprintf("paid = $%d,", i)
+		args = append(args, *patch.Paid)
+		i++
+	}
+	if patch.MaxMinutes != nil {
+		query += fmt.Sprintf("max_minutes = $%d,", i)
+		args = append(args, *patch.MaxMinutes)
 		i++
 	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
-		RETURNING id, hourly_rate, unpaid_lunch_minutes
+		AND contract_id IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(i+1)+`)
+		)
+		RETURNING id, contract_id, name, paid, max_minutes
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
-	contract := model.Contract{}
+	breakType := model.BreakType{}
 	err := db.QueryRowContext(ctx, query, args...).Scan(
-		&contract.Id,
-		&contract.HourlyRate,
-		&contract.UnpaidLunchMinutes,
+		&breakType.Id,
+		&breakType.ContractId,
+		&breakType.Name,
+		&breakType.Paid,
+		&breakType.MaxMinutes,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("contract not found")
+			return nil, ErrBreakTypeNotFound
 		}
-		return nil, fmt.Errorf("PatchContract: %w", err)
+		return nil, fmt.Errorf("PatchBreakType: %w", err)
 	}
 
-	return &contract, nil
+	return &breakType, nil
 }
 
 func PatchProfile(
@@ -316,6 +536,8 @@ func PatchProfile(
 	id int,
 	patch ProfilePatch,
 ) (*model.Profile, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
 	query := "UPDATE profile SET "
 	args := []any{}
 	i := 1
@@ -337,15 +559,16 @@ func PatchProfile(
 	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
+		AND id IN (`+managedProfiles(i+1)+`)
 		RETURNING id, kt, first_name, last_name
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
 
 	profile := model.Profile{}
 	err := db.QueryRowContext(ctx, query, args...).Scan(
@@ -357,7 +580,7 @@ func PatchProfile(
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("profile not found")
+			return nil, ErrProfileNotFound
 		}
 		return nil, fmt.Errorf("PatchProfile: %w", err)
 	}
@@ -371,6 +594,29 @@ func PatchShift(
 	id int,
 	patch ShiftPatch,
 ) (*model.Shift, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if patch.TaskId != nil {
+		var ok bool
+		err := db.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1 FROM task
+				WHERE id = $1 AND company_id IN (`+managedCompanies(2)+`)
+			)
+			`,
+			*patch.TaskId,
+			claims.ProfileID,
+		).Scan(&ok)
+		if err != nil {
+			return nil, fmt.Errorf("PatchShift: db select: %w", err)
+		}
+		if !ok {
+			return nil, ErrTaskNotFound
+		}
+	}
+
 	query := "UPDATE shift SET "
 	args := []any{}
 	i := 1
@@ -390,33 +636,165 @@ func PatchShift(
 		args = append(args, *patch.EndTs)
 		i++
 	}
+	if patch.SFlagged != nil {
+		query += fmt.Sprintf("s_flagged = $%d,", i)
+		args = append(args, *patch.SFlagged)
+		i++
+	}
+	if patch.EFlagged != nil {
+		query += fmt.Sprintf("e_flagged = $%d,", i)
+		args = append(args, *patch.EFlagged)
+		i++
+	}
+	if patch.AutoClosed != nil {
+		query += fmt.Sprintf("auto_closed = $%d,", i)
+		args = append(args, *patch.AutoClosed)
+		i++
+	}
 
 	if len(args) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+		return nil, ErrNoFieldsToUpdate
 	}
 
 	query = strings.TrimSuffix(query, ",")
 	query += fmt.Sprintf(` 
 		WHERE id = $%d
-		RETURNING id, profile_id, task_id, start_ts, end_ts
+		AND task_id IN (
+			SELECT id FROM task WHERE company_id IN (`+managedCompanies(i+1)+`)
+		)
+		RETURNING id, profile_id, task_id, start_ts, end_ts, s_flagged, e_flagged, auto_closed
 	`, i)
-	args = append(args, id)
+	args = append(args, id, claims.ProfileID)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PatchShift: begin tx: %w", err)
+	}
+	defer tx.Rollback()
 
 	shift := model.Shift{}
-	err := db.QueryRowContext(ctx, query, args...).Scan(
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&shift.Id,
 		&shift.ProfileId,
 		&shift.TaskId,
 		&shift.StartTs,
 		&shift.EndTs,
+		&shift.SFlagged,
+		&shift.EFlagged,
+		&shift.AutoClosed,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("shift not found")
+			return nil, ErrShiftNotFound
 		}
 		return nil, fmt.Errorf("PatchShift: %w", err)
 	}
 
+	// a corrected task replaces whatever the worker switched between, unless
+	// it is the task they ended up on
+	if patch.TaskId != nil {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			DELETE FROM shift_segment
+			WHERE shift_id = $1
+			AND $2 IS DISTINCT FROM (
+				SELECT task_id FROM shift_segment
+				WHERE shift_id = $1
+				ORDER BY start_ts DESC
+				LIMIT 1
+			)
+			`,
+			shift.Id,
+			*patch.TaskId,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("PatchShift: delete segments: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PatchShift: db commit: %w", err)
+	}
+
 	return &shift, nil
 }
+
+func PatchPayRule(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+	patch PayRulePatch,
+) (*model.PayRule, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if err := validatePayRule(nil, patch.DaysMask, patch.StartMinute, patch.EndMinute, patch.Multiplier); err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE pay_rule SET "
+	args := []any{}
+	i := 1
+
+	if patch.Name != nil {
+		query += fmt.Sprintf("name = $%d,", i)
+		args = append(args, *patch.Name)
+		i++
+	}
+	if patch.DaysMask != nil {
+		query += fmt.Sprintf("days_mask = $%d,", i)
+		args = append(args, *patch.DaysMask)
+		i++
+	}
+	if patch.StartMinute != nil {
+		query += fmt.Sprintf("start_minute = $%d,", i)
+		args = append(args, *patch.StartMinute)
+		i++
+	}
+	if patch.EndMinute != nil {
+		query += fmt.Sprintf("end_minute = $%d,", i)
+		args = append(args, *patch.EndMinute)
+		i++
+	}
+	if patch.Multiplier != nil {
+		query += fmt.Sprintf("multiplier = $%d,", i)
+		args = append(args, *patch.Multiplier)
+		i++
+	}
+
+	if len(args) == 0 {
+		return nil, ErrNoFieldsToUpdate
+	}
+
+	query = strings.TrimSuffix(query, ",")
+	query += fmt.Sprintf(` 
+		WHERE id = $%d
+		AND contract_id IN (
+			SELECT id FROM contract WHERE workspace_id IN (`+managedWorkspaces(i+1)+`)
+		)
+		RETURNING id, contract_id, name, kind, days_mask, start_minute, end_minute, multiplier
+	`, i)
+	args = append(args, id, claims.ProfileID)
+
+	rule := model.PayRule{}
+	err := db.QueryRowContext(ctx, query, args...).Scan(
+		&rule.Id,
+		&rule.ContractId,
+		&rule.Name,
+		&rule.Kind,
+		&rule.DaysMask,
+		&rule.StartMinute,
+		&rule.EndMinute,
+		&rule.Multiplier,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPayRuleNotFound
+		}
+		return nil, fmt.Errorf("PatchPayRule: %w", err)
+	}
+
+	return &rule, nil
+}
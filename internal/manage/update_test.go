@@ -0,0 +1,74 @@
+package manage
+
+import (
+	"context"
+	"errors"
+	"test/internal/auth"
+	"test/internal/db/dbtest"
+	"testing"
+	"time"
+)
+
+func TestPatchNoFields(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.WithValue(context.Background(), auth.ClaimsKey, &auth.Claims{ProfileID: 1})
+
+	tests := []struct {
+		name  string
+		patch func() error
+	}{
+		{"workspace", func() error { _, err := PatchWorkspace(ctx, db, 1, WorkspacePatch{}); return err }},
+		{"company", func() error { _, err := PatchCompany(ctx, db, 1, CompanyPatch{}); return err }},
+		{"location", func() error { _, err := PatchLocation(ctx, db, 1, LocationPatch{}); return err }},
+		{"task", func() error { _, err := PatchTask(ctx, db, 1, TaskPatch{}); return err }},
+		{"employment", func() error { _, err := PatchEmployment(ctx, db, 1, EmploymentPatch{}); return err }},
+		{"contract", func() error { _, err := PatchContract(ctx, db, 1, ContractPatch{}); return err }},
+		{"break type", func() error { _, err := PatchBreakType(ctx, db, 1, BreakTypePatch{}); return err }},
+		{"profile", func() error { _, err := PatchProfile(ctx, db, 1, ProfilePatch{}); return err }},
+		{"shift", func() error { _, err := PatchShift(ctx, db, 1, ShiftPatch{}); return err }},
+		{"pay rule", func() error { _, err := PatchPayRule(ctx, db, 1, PayRulePatch{}); return err }},
+		{"planned shift", func() error { _, err := PatchPlannedShift(ctx, db, 1, PlannedShiftPatch{}); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.patch(); !errors.Is(err, ErrNoFieldsToUpdate) {
+				t.Errorf("got %v, want ErrNoFieldsToUpdate", err)
+			}
+		})
+	}
+}
+
+func TestPatchShiftFitsSegments(t *testing.T) {
+	db := dbtest.Open(t)
+	tn := newTenant(t, db, 1)
+
+	other, err := CreateTask(tn.ctx, db, TaskCreate{Name: "Other", LocationId: tn.location_id, CompanyId: tn.company_id})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	// the worker switched to the other task two hours in
+	var start time.Time
+	err = db.QueryRow(`SELECT start_ts FROM shift WHERE id = $1`, tn.shift_id).Scan(&start)
+	if err != nil {
+		t.Fatalf("select shift: %v", err)
+	}
+	dbtest.Exec(t, db, `
+		INSERT INTO shift_segment (shift_id, task_id, start_ts)
+		VALUES ($1, $2, $3), ($1, $4, $5)`, tn.shift_id, tn.task_id, start, other.Id, start.Add(2*time.Hour))
+	dbtest.Exec(t, db, `UPDATE shift SET task_id = $1 WHERE id = $2`, other.Id, tn.shift_id)
+
+	// the shift really ended before the switch
+	end := start.Add(time.Hour)
+	shift, err := PatchShift(tn.ctx, db, tn.shift_id, ShiftPatch{EndTs: &end})
+	if err != nil {
+		t.Fatalf("PatchShift: %v", err)
+	}
+	if shift.TaskId != tn.task_id {
+		t.Errorf("shift is on task %d, want %d", shift.TaskId, tn.task_id)
+	}
+	segments := dbtest.QueryInt(t, db, `SELECT count(*) FROM shift_segment WHERE shift_id = $1`, tn.shift_id)
+	if segments != 0 {
+		t.Errorf("%d segments left, want none", segments)
+	}
+}
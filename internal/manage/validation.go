@@ -0,0 +1,165 @@
+package manage
+
+import (
+	"context"
+	"database/sql"
+	"test/internal/auth"
+	"test/internal/model"
+	"time"
+)
+
+func validateGeofencePolicy(policy *model.GeofencePolicy) error {
+	if policy == nil {
+		return nil
+	}
+	switch *policy {
+	case model.GeofenceReject, model.GeofenceFlag, model.GeofenceIgnore:
+		return nil
+	}
+	return ErrInvalidGeofencePolicy
+}
+
+func validateGeofence(latitude, longitude *float64, radius_m *int) error {
+	if latitude != nil && (*latitude < -90 || *latitude > 90) {
+		return ErrInvalidGeofence
+	}
+	if longitude != nil && (*longitude < -180 || *longitude > 180) {
+		return ErrInvalidGeofence
+	}
+	if radius_m != nil && *radius_m <= 0 {
+		return ErrInvalidGeofence
+	}
+	return nil
+}
+
+func validateMaxShiftMinutes(minutes *int) error {
+	if minutes != nil && *minutes < 0 {
+		return ErrInvalidMaxShiftMinutes
+	}
+	return nil
+}
+
+func validatePayPeriodStartDay(day *int) error {
+	if day != nil && (*day < 1 || *day > 28) {
+		return ErrInvalidPayPeriodStartDay
+	}
+	return nil
+}
+
+// validateTimeZone checks the name against the IANA time zone database.
+// Local is Go's name for the server's zone, so it isn't allowed.
+func validateTimeZone(name *string) error {
+	if name == nil {
+		return nil
+	}
+	if *name == "" || *name == "Local" {
+		return ErrInvalidTimeZone
+	}
+	if _, err := time.LoadLocation(*name); err != nil {
+		return ErrInvalidTimeZone
+	}
+	return nil
+}
+
+// validateOvertime checks the overtime settings of a contract, 0 meaning no
+// threshold.
+func validateOvertime(daily, weekly *int, multiplier *float64) error {
+	if daily != nil && *daily < 0 {
+		return ErrInvalidOvertime
+	}
+	if weekly != nil && *weekly < 0 {
+		return ErrInvalidOvertime
+	}
+	if multiplier != nil && *multiplier < 1 {
+		return ErrInvalidOvertime
+	}
+	return nil
+}
+
+func validateMinuteOfDay(minute *int) bool {
+	return minute == nil || (*minute >= 0 && *minute <= 1440)
+}
+
+func validatePayRule(kind *model.PayRuleKind, days_mask, start_minute, end_minute *int, multiplier *float64) error {
+	if kind != nil && *kind != "" && *kind != model.PayRuleWindow && *kind != model.PayRuleHoliday {
+		return ErrInvalidPayRule
+	}
+	if days_mask != nil && (*days_mask < 0 || *days_mask > 127) {
+		return ErrInvalidPayRule
+	}
+	if !validateMinuteOfDay(start_minute) || !validateMinuteOfDay(end_minute) {
+		return ErrInvalidPayRule
+	}
+	if multiplier != nil && *multiplier < 1 {
+		return ErrInvalidPayRule
+	}
+	return nil
+}
+
+func validateRecurrence(recurrence *model.Recurrence, repeat_until *string) error {
+	if recurrence != nil {
+		switch *recurrence {
+		case model.RecurrenceNone, model.RecurrenceDaily, model.RecurrenceWeekly:
+		default:
+			return ErrInvalidPlannedShift
+		}
+	}
+	if repeat_until != nil && *repeat_until != "" {
+		if _, err := time.Parse(time.DateOnly, *repeat_until); err != nil {
+			return ErrInvalidPlannedShift
+		}
+	}
+	return nil
+}
+
+func ValidateWorkspaceCreate(ctx context.Context, db *sql.DB, input WorkspaceCreate) error {
+	if err := validateGeofencePolicy(input.GeofencePolicy); err != nil {
+		return err
+	}
+	if err := validateMaxShiftMinutes(input.MaxShiftMinutes); err != nil {
+		return err
+	}
+	if err := validatePayPeriodStartDay(input.PayPeriodStartDay); err != nil {
+		return err
+	}
+	return validateTimeZone(input.TimeZone)
+}
+
+func ValidateLocationCreate(ctx context.Context, db *sql.DB, input LocationCreate) error {
+	return validateGeofence(input.Latitude, input.Longitude, input.RadiusM)
+}
+
+func ValidateProfileHire(ctx context.Context, db *sql.DB, input ProfileHire) error {
+	if input.Password != nil && (input.Email == nil || *input.Email == "") {
+		return auth.ErrEmailRequired
+	}
+	return nil
+}
+
+func ValidateContractCreate(ctx context.Context, db *sql.DB, input ContractCreate) error {
+	return validateOvertime(input.DailyOvertimeMinutes, input.WeeklyOvertimeMinutes, input.OvertimeMultiplier)
+}
+
+func ValidatePayRuleCreate(ctx context.Context, db *sql.DB, input PayRuleCreate) error {
+	return validatePayRule(&input.Kind, input.DaysMask, input.StartMinute, input.EndMinute, &input.Multiplier)
+}
+
+func ValidateHolidayCreate(ctx context.Context, db *sql.DB, input HolidayCreate) error {
+	if input.Name == "" {
+		return ErrInvalidHoliday
+	}
+	if _, err := time.Parse(time.DateOnly, input.Date); err != nil {
+		return ErrInvalidHoliday
+	}
+	return nil
+}
+
+func ValidatePlannedShiftCreate(ctx context.Context, db *sql.DB, input PlannedShiftCreate) error {
+	if !input.EndTs.After(input.StartTs) {
+		return ErrInvalidPlannedShift
+	}
+	if err := validateRecurrence(input.Recurrence, input.RepeatUntil); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package manage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateProfilePasswordWithoutEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no email", `{"kt": "0101302989", "password": "secret"}`},
+		{"empty email", `{"kt": "0101302989", "password": "secret", "email": ""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the validator answers before the database is needed
+			handler := CreateProfileHandler(nil)
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/v1/manage/profile", strings.NewReader(tt.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -12,6 +12,22 @@ type Shift struct {
 	SLongitude *float64   `json:"s_longitude"`
 	ELatitude  *float64   `json:"e_latitude"`
 	ELongitude *float64   `json:"e_longitude"`
+	SAccuracy  *float64   `json:"s_accuracy"`
+	EAccuracy  *float64   `json:"e_accuracy"`
+	SFlagged    bool      `json:"s_flagged"`
+	EFlagged    bool      `json:"e_flagged"`
+	AutoClosed  bool      `json:"auto_closed"`
+	Version     int       `json:"version"`
+	Segments []ShiftSegment `json:"segments,omitempty"`
+	PlannedShiftId *int       `json:"planned_shift_id,omitempty"`
+	PlannedStartTs *time.Time `json:"planned_start_ts,omitempty"`
+}
+
+// ShiftSegment is the part of a shift spent on one task.
+type ShiftSegment struct {
+	TaskId   int       `json:"task_id"`
+	StartTs  time.Time `json:"start_ts"`
+	EndTs   *time.Time `json:"end_ts"`
 }
 
 type Company struct {
@@ -25,15 +41,33 @@ type Location struct {
 	WorkspaceId *int    `json:"workspace_id"`
 	Name         string `json:"name"`
 	Address      string `json:"address"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	RadiusM     *int     `json:"radius_m"`
 }
 
 type Workspace struct {
 	Id int      `json:"id"`
 	Name string `json:"name"`
+	GeofencePolicy GeofencePolicy `json:"geofence_policy,omitempty"`
+	MaxShiftMinutes *int `json:"max_shift_minutes,omitempty"`
+	AutoCloseEditRequest bool `json:"auto_close_edit_request,omitempty"`
+	PayPeriodStartDay int `json:"pay_period_start_day"`
+	TimeZone string `json:"time_zone"`
 }
 
+// GeofencePolicy decides what happens when a worker clocks in or out
+// outside the geofence of the task's location.
+type GeofencePolicy string
+const (
+	GeofenceReject GeofencePolicy = "reject"
+	GeofenceFlag   GeofencePolicy = "flag"
+	GeofenceIgnore GeofencePolicy = "ignore"
+)
+
 type Role string
 const (
+	RoleOwner   Role = "owner"
 	RoleAdmin   Role = "admin"
 	RoleManager Role = "manager"
 	RoleWorker  Role = "worker"
@@ -46,20 +80,72 @@ type Profile struct {
 	LastName  string `json:"last_name"`
 }
 
+// Employment binds a profile to a company, or only to a workspace for the
+// owner who created it. Owners have no contract and no end date.
 type Employment struct {
 	Id         int        `json:"id"`
 	ProfileId  int        `json:"profile_id"`
-	CompanyId  int        `json:"company_id"`
-	ContractId int        `json:"contract_id"`
+	CompanyId  *int       `json:"company_id"`
+	ContractId *int       `json:"contract_id"`
 	Role       Role       `json:"role"`
 	StartDate  time.Time  `json:"start_date"`
-	EndDate    time.Time  `json:"end_date"`
+	EndDate    *time.Time `json:"end_date"`
 }
 
+// Contract holds the terms of one version of a contract, the one in force at
+// the time it was read unless said otherwise.
 type Contract struct {
-	Id                 int `json:"id"`
-	HourlyRate         int `json:"hourly_rate"`
-	UnpaidLunchMinutes int `json:"unpaid_lunch_minutes"`
+	Id                    int       `json:"id"`
+	WorkspaceId           *int      `json:"workspace_id,omitempty"`
+	VersionId             int       `json:"version_id"`
+	EffectiveFrom         time.Time `json:"effective_from"`
+	HourlyRate            int       `json:"hourly_rate"`
+	UnpaidLunchMinutes    int       `json:"unpaid_lunch_minutes"`
+	DailyOvertimeMinutes  *int      `json:"daily_overtime_minutes"`
+	WeeklyOvertimeMinutes *int      `json:"weekly_overtime_minutes"`
+	OvertimeMultiplier    float64   `json:"overtime_multiplier"`
+}
+
+type PayRuleKind string
+const (
+	PayRuleWindow  PayRuleKind = "window"
+	PayRuleHoliday PayRuleKind = "holiday"
+)
+
+type PayRule struct {
+	Id          int         `json:"id"`
+	ContractId  int         `json:"contract_id"`
+	Name        string      `json:"name"`
+	Kind        PayRuleKind `json:"kind"`
+	DaysMask    int         `json:"days_mask"`
+	StartMinute int         `json:"start_minute"`
+	EndMinute   int         `json:"end_minute"`
+	Multiplier  float64     `json:"multiplier"`
+}
+
+type Holiday struct {
+	Id          int    `json:"id"`
+	WorkspaceId int    `json:"workspace_id"`
+	Date        string `json:"date"`
+	Name        string `json:"name"`
+}
+
+type BreakType struct {
+	Id          int    `json:"id"`
+	ContractId  int    `json:"contract_id"`
+	Name        string `json:"name"`
+	Paid        bool   `json:"paid"`
+	MaxMinutes *int    `json:"max_minutes"`
+}
+
+type ShiftBreak struct {
+	Id           int       `json:"id"`
+	ShiftId      int       `json:"shift_id"`
+	BreakTypeId *int       `json:"break_type_id"`
+	Paid         bool      `json:"paid"`
+	MaxMinutes  *int       `json:"max_minutes"`
+	StartTs      time.Time `json:"start_ts"`
+	EndTs       *time.Time `json:"end_ts"`
 }
 
 type Task struct {
@@ -71,19 +157,48 @@ type Task struct {
 	LocationId  int    `json:"location_id"`
 }
 
+type Recurrence string
+const (
+	RecurrenceNone   Recurrence = "none"
+	RecurrenceDaily  Recurrence = "daily"
+	RecurrenceWeekly Recurrence = "weekly"
+)
+
+// PlannedShift is a shift on the roster. StartTs and EndTs are the first
+// occurrence, recurring ones repeat at the same local time up to and
+// including the date RepeatUntil, or forever when it is nil. The location is
+// the task's.
+type PlannedShift struct {
+	Id          int        `json:"id"`
+	ProfileId   int        `json:"profile_id"`
+	TaskId      int        `json:"task_id"`
+	LocationId  int        `json:"location_id"`
+	StartTs     time.Time  `json:"start_ts"`
+	EndTs       time.Time  `json:"end_ts"`
+	Recurrence  Recurrence `json:"recurrence"`
+	RepeatUntil *string    `json:"repeat_until"`
+	Note        *string    `json:"note"`
+}
+
 type RequestStatus string
 const (
 	Pending   RequestStatus = "pending"
 	Rejected RequestStatus = "rejected"
 	Approved  RequestStatus = "approved"
+	Cancelled RequestStatus = "cancelled"
 )
 
 type EditRequest struct {
-	Id       int           `json:"id"`
-	ShiftId  int           `json:"shift_id"`
-	TaskId  *int           `json:"task_id"`
-	StartTs *time.Time     `json:"start_ts"`
-	EndTs   *time.Time     `json:"end_ts"`
-	Reason   string        `json:"reason"`
-	Status   RequestStatus `json:"status"`
+	Id             int           `json:"id"`
+	ShiftId        int           `json:"shift_id"`
+	ProfileId      int           `json:"profile_id"`
+	TaskId        *int           `json:"task_id"`
+	StartTs       *time.Time     `json:"start_ts"`
+	EndTs         *time.Time     `json:"end_ts"`
+	Reason         string        `json:"reason"`
+	Status         RequestStatus `json:"status"`
+	ReviewerId    *int           `json:"reviewer_id"`
+	ReviewComment *string        `json:"review_comment"`
+	ReviewedAt    *time.Time     `json:"reviewed_at"`
+	Created        time.Time     `json:"created"`
 }
@@ -0,0 +1,90 @@
+package payroll
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	// time zones are looked up by name, so don't depend on the host having
+	// the zone database installed
+	_ "time/tzdata"
+)
+
+// Calendar is how a workspace divides time into days, weeks, months and pay
+// periods. Pay periods run from PayPeriodStartDay of one month to the same
+// day of the next.
+type Calendar struct {
+	Location          *time.Location
+	PayPeriodStartDay int
+}
+
+// DefaultCalendar is used when there is no workspace to go by.
+var DefaultCalendar = Calendar{Location: time.UTC, PayPeriodStartDay: 1}
+
+// GetCalendar returns the calendar of the workspace of the employment.
+func GetCalendar(
+	ctx context.Context,
+	db *sql.DB,
+	employment_id int,
+) (*Calendar, error) {
+	var time_zone string
+	calendar := Calendar{}
+	err := db.QueryRowContext(
+		ctx,
+		`
+		SELECT w.time_zone, w.pay_period_start_day
+		FROM employment e
+		LEFT JOIN company c ON c.id = e.company_id
+		JOIN workspace w ON w.id = COALESCE(c.workspace_id, e.workspace_id)
+		WHERE e.id = $1
+		`,
+		employment_id,
+	).Scan(
+		&time_zone,
+		&calendar.PayPeriodStartDay,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEmploymentNotFound
+		}
+		return nil, fmt.Errorf("GetCalendar: db select: %w", err)
+	}
+
+	calendar.Location, err = time.LoadLocation(time_zone)
+	if err != nil {
+		return nil, fmt.Errorf("GetCalendar: load location: %w", err)
+	}
+
+	return &calendar, nil
+}
+
+// Date returns midnight in the calendar's time zone of the date of d, read
+// from its year, month and day whatever zone it is in.
+func (c Calendar) Date(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// MonthRange returns the first instant of the month and of the month after.
+func (c Calendar) MonthRange(year int, month time.Month) (time.Time, time.Time) {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, c.Location)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// WeekRange returns midnight of the Monday of the week t falls in and of the
+// Monday after.
+func (c Calendar) WeekRange(t time.Time) (time.Time, time.Time) {
+	start := weekStart(t, c.Location)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// PayPeriodRange returns the start and end of the pay period t falls in.
+func (c Calendar) PayPeriodRange(t time.Time) (time.Time, time.Time) {
+	t = t.In(c.Location)
+	start := time.Date(t.Year(), t.Month(), c.PayPeriodStartDay, 0, 0, 0, 0, c.Location)
+	if t.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
@@ -0,0 +1,131 @@
+package payroll
+
+import (
+	"test/internal/model"
+	"testing"
+	"time"
+)
+
+func TestCalendarRanges(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	calendar := Calendar{Location: la, PayPeriodStartDay: 15}
+
+	tests := []struct {
+		name     string
+		got      func() (time.Time, time.Time)
+		from, to time.Time
+	}{
+		{
+			// the clocks go forward on 2025-03-09, so the month starts
+			// at 08:00 UTC and ends at 07:00 UTC
+			name: "month",
+			got:  func() (time.Time, time.Time) { return calendar.MonthRange(2025, time.March) },
+			from: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC),
+			to:   time.Date(2025, 4, 1, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			// Monday 05:00 UTC is still Sunday in Los Angeles
+			name: "week",
+			got:  func() (time.Time, time.Time) { return calendar.WeekRange(time.Date(2025, 3, 3, 5, 0, 0, 0, time.UTC)) },
+			from: time.Date(2025, 2, 24, 0, 0, 0, 0, la),
+			to:   time.Date(2025, 3, 3, 0, 0, 0, 0, la),
+		},
+		{
+			name: "pay period before its start day",
+			got:  func() (time.Time, time.Time) { return calendar.PayPeriodRange(time.Date(2025, 3, 10, 12, 0, 0, 0, la)) },
+			from: time.Date(2025, 2, 15, 0, 0, 0, 0, la),
+			to:   time.Date(2025, 3, 15, 0, 0, 0, 0, la),
+		},
+		{
+			// 2025-03-15 03:00 UTC is the evening of the 14th locally
+			name: "pay period by local date",
+			got: func() (time.Time, time.Time) {
+				return calendar.PayPeriodRange(time.Date(2025, 3, 15, 3, 0, 0, 0, time.UTC))
+			},
+			from: time.Date(2025, 2, 15, 0, 0, 0, 0, la),
+			to:   time.Date(2025, 3, 15, 0, 0, 0, 0, la),
+		},
+		{
+			name: "pay period on its start day",
+			got:  func() (time.Time, time.Time) { return calendar.PayPeriodRange(time.Date(2025, 3, 15, 0, 0, 0, 0, la)) },
+			from: time.Date(2025, 3, 15, 0, 0, 0, 0, la),
+			to:   time.Date(2025, 4, 15, 0, 0, 0, 0, la),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := tt.got()
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("got [%v, %v), want [%v, %v)", from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestCalendarDate(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	calendar := Calendar{Location: la, PayPeriodStartDay: 1}
+
+	// a date parsed as UTC midnight is the same date in the calendar's zone
+	got := calendar.Date(time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2025, 3, 3, 0, 0, 0, 0, la); !got.Equal(want) {
+		t.Errorf("Date = %v, want %v", got, want)
+	}
+}
+
+func TestComputeShiftInTimeZone(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	daily := 480
+
+	// both shifts are on 2025-03-03 in Los Angeles, though the second one
+	// is on the 4th in UTC, so it is overtime there and not in UTC
+	shifts := []struct {
+		start, end time.Time
+	}{
+		{time.Date(2025, 3, 3, 16, 0, 0, 0, time.UTC), time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 4, 4, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		loc      *time.Location
+		date     string
+		overtime int
+	}{
+		{la, "2025-03-03", 240},
+		{time.UTC, "2025-03-04", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.loc.String(), func(t *testing.T) {
+			c := newCalculator(RuleSet{
+				Versions: []model.Contract{{HourlyRate: 100, DailyOvertimeMinutes: &daily, OvertimeMultiplier: 1.5}},
+				Location: tt.loc,
+			})
+
+			var earnings ShiftEarnings
+			for i, s := range shifts {
+				end := s.end
+				earnings = c.computeShift(model.Shift{Id: i + 1, StartTs: s.start, EndTs: &end}, nil)
+			}
+
+			if earnings.Date != tt.date {
+				t.Errorf("Date = %s, want %s", earnings.Date, tt.date)
+			}
+			if earnings.OvertimeMinutes != tt.overtime {
+				t.Errorf("OvertimeMinutes = %d, want %d", earnings.OvertimeMinutes, tt.overtime)
+			}
+			if !earnings.StartTs.Equal(shifts[1].start) || earnings.StartTs.Location() != tt.loc {
+				t.Errorf("StartTs = %v, want %v in %v", earnings.StartTs, shifts[1].start, tt.loc)
+			}
+		})
+	}
+}
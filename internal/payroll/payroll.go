@@ -0,0 +1,78 @@
+package payroll
+
+import (
+	"test/internal/model"
+	"time"
+)
+
+// Durations splits the time between start and end into worked and payable
+// time. Recorded breaks are never worked. Unpaid breaks aren't payable, and
+// paid ones only up to their max_minutes. The contract's flat unpaid lunch is
+// deducted from both only when no breaks were recorded. Breaks still going
+// count up to end.
+func Durations(
+	start time.Time,
+	end time.Time,
+	breaks []model.ShiftBreak,
+	contract *model.Contract,
+) (time.Duration, time.Duration) {
+	elapsed := end.Sub(start)
+
+	if len(breaks) == 0 {
+		if contract != nil {
+			elapsed -= time.Duration(contract.UnpaidLunchMinutes) * time.Minute
+		}
+		elapsed = max(elapsed, 0)
+		return elapsed, elapsed
+	}
+
+	worked := elapsed
+	payable := elapsed
+	for _, b := range breaks {
+		b_end := end
+		if b.EndTs != nil && b.EndTs.Before(end) {
+			b_end = *b.EndTs
+		}
+		length := max(b_end.Sub(b.StartTs), 0)
+
+		worked -= length
+		if !b.Paid {
+			payable -= length
+		} else if b.MaxMinutes != nil {
+			payable -= max(length-time.Duration(*b.MaxMinutes)*time.Minute, 0)
+		}
+	}
+
+	return max(worked, 0), max(payable, 0)
+}
+
+// Summarize adds the shifts up per day, in the order the days first appear,
+// and for the whole period.
+func Summarize(shifts []ShiftEarnings) ([]DayEarnings, Totals) {
+	days := []DayEarnings{}
+	index := map[string]int{}
+	total := Totals{}
+
+	for _, shift := range shifts {
+		i, ok := index[shift.Date]
+		if !ok {
+			i = len(days)
+			index[shift.Date] = i
+			days = append(days, DayEarnings{Date: shift.Date})
+		}
+
+		days[i].WorkedMinutes += shift.WorkedMinutes
+		days[i].PayableMinutes += shift.PayableMinutes
+		days[i].OvertimeMinutes += shift.OvertimeMinutes
+		days[i].PremiumMinutes += shift.PremiumMinutes
+		days[i].GrossPay += shift.GrossPay
+
+		total.WorkedMinutes += shift.WorkedMinutes
+		total.PayableMinutes += shift.PayableMinutes
+		total.OvertimeMinutes += shift.OvertimeMinutes
+		total.PremiumMinutes += shift.PremiumMinutes
+		total.GrossPay += shift.GrossPay
+	}
+
+	return days, total
+}
@@ -0,0 +1,426 @@
+package payroll
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrEmploymentNotFound = errors.New("employment not found")
+
+// ComputeEmployment builds the earnings report of an employment for the
+// finished shifts that started in [from, to). Only time worked on tasks of
+// the employment's company counts, or of any company of the workspace for
+// workspace-level employments, so a shift that switched to a task of another
+// company is split between them. Shifts from the start of the week are read
+// as well so that weekly overtime is right, but only the ones in the period
+// are reported.
+func ComputeEmployment(
+	ctx context.Context,
+	db *sql.DB,
+	employment_id int,
+	from time.Time,
+	to time.Time,
+) (*Report, error) {
+	report := Report{
+		EmploymentId: employment_id,
+		Shifts: []ShiftEarnings{},
+	}
+
+	var workspace_id int
+	var company_id, contract_id *int
+	err := db.QueryRowContext(
+		ctx,
+		`
+		SELECT e.profile_id, e.company_id, COALESCE(c.workspace_id, e.workspace_id), e.contract_id
+		FROM employment e
+		LEFT JOIN company c ON c.id = e.company_id
+		WHERE e.id = $1
+		`,
+		employment_id,
+	).Scan(
+		&report.ProfileId,
+		&company_id,
+		&workspace_id,
+		&contract_id,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEmploymentNotFound
+		}
+		return nil, fmt.Errorf("ComputeEmployment: select employment: %w", err)
+	}
+
+	calendar, err := GetCalendar(ctx, db, employment_id)
+	if err != nil {
+		return nil, err
+	}
+	report.TimeZone = calendar.Location.String()
+	report.From = from.In(calendar.Location)
+	report.To = to.In(calendar.Location)
+
+	rules := RuleSet{
+		Location: calendar.Location,
+	}
+
+	if contract_id != nil {
+		rules.Versions, err = getContractVersions(ctx, db, *contract_id)
+		if err != nil {
+			return nil, err
+		}
+		rules.Rules, err = getPayRules(ctx, db, *contract_id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if contract := rules.contractAt(from); contract != nil {
+		report.HourlyRate = contract.HourlyRate
+	}
+
+	week_start := weekStart(from, rules.Location)
+
+	rules.Holidays, err = getHolidays(ctx, db, workspace_id, week_start, to.In(rules.Location))
+	if err != nil {
+		return nil, err
+	}
+
+	shifts, err := getFinishedShifts(ctx, db, report.ProfileId, workspace_id, company_id, week_start, to)
+	if err != nil {
+		return nil, err
+	}
+
+	breaks, err := GetBreaks(ctx, db, shifts)
+	if err != nil {
+		return nil, err
+	}
+
+	calculator := newCalculator(rules)
+	for _, shift := range shifts {
+		earnings := calculator.computeShift(shift, breaks[shift.Id])
+		if shift.StartTs.Before(from) {
+			continue
+		}
+		report.Shifts = append(report.Shifts, earnings)
+	}
+	report.Days, report.Total = Summarize(report.Shifts)
+
+	return &report, nil
+}
+
+// weekStart returns midnight of the Monday of the week t falls in.
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	days := (int(local.Weekday()) + 6) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()-days, 0, 0, 0, 0, loc)
+}
+
+// getFinishedShifts returns the finished shifts that started in [from, to)
+// and were worked, in part at least, on tasks of the company, or of the
+// workspace when company_id is nil. A shift that switched to tasks elsewhere
+// has Segments set to the parts worked here.
+func getFinishedShifts(
+	ctx context.Context,
+	db *sql.DB,
+	profile_id int,
+	workspace_id int,
+	company_id *int,
+	from time.Time,
+	to time.Time,
+) ([]model.Shift, error) {
+	shifts := []model.Shift{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT s.id, s.profile_id, s.task_id, s.start_ts, s.end_ts,
+			p.task_id, p.start_ts, p.end_ts,
+			c.workspace_id = $2 AND ($3::int IS NULL OR t.company_id = $3)
+		FROM shift s
+		CROSS JOIN LATERAL (
+			SELECT
+				COALESCE(g.task_id, s.task_id) AS task_id,
+				CASE WHEN row_number() OVER w = 1 THEN s.start_ts ELSE g.start_ts END AS start_ts,
+				COALESCE(lead(g.start_ts) OVER w, s.end_ts) AS end_ts
+			FROM (SELECT 1) one
+			LEFT JOIN shift_segment g ON g.shift_id = s.id
+			WINDOW w AS (ORDER BY g.start_ts)
+		) p
+		JOIN task t ON t.id = p.task_id
+		JOIN company c ON c.id = t.company_id
+		WHERE s.profile_id = $1
+		AND s.start_ts >= $4
+		AND s.start_ts < $5
+		AND s.end_ts IS NOT NULL
+		ORDER BY s.start_ts, s.id, p.start_ts
+		`,
+		profile_id,
+		workspace_id,
+		company_id,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getFinishedShifts: db select: %w", err)
+	}
+	defer rows.Close()
+
+	// parts of the current shift, and whether each was worked here
+	var (
+		shift model.Shift
+		parts []model.ShiftSegment
+		here  []bool
+	)
+	flush := func() {
+		counted := []model.ShiftSegment{}
+		for i := range parts {
+			if here[i] {
+				counted = append(counted, parts[i])
+			}
+		}
+		if len(counted) == 0 {
+			return
+		}
+		if len(counted) < len(parts) {
+			shift.Segments = counted
+		}
+		shifts = append(shifts, shift)
+	}
+
+	for rows.Next() {
+		var next model.Shift
+		var part model.ShiftSegment
+		var part_here bool
+		err = rows.Scan(
+			&next.Id,
+			&next.ProfileId,
+			&next.TaskId,
+			&next.StartTs,
+			&next.EndTs,
+			&part.TaskId,
+			&part.StartTs,
+			&part.EndTs,
+			&part_here,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getFinishedShifts: db scan: %w", err)
+		}
+
+		if next.Id != shift.Id {
+			if shift.Id != 0 {
+				flush()
+			}
+			shift, parts, here = next, nil, nil
+		}
+		parts = append(parts, part)
+		here = append(here, part_here)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getFinishedShifts: rows: %w", err)
+	}
+	if shift.Id != 0 {
+		flush()
+	}
+
+	return shifts, nil
+}
+
+// GetBreaks returns the breaks of the shifts by shift id, oldest first.
+func GetBreaks(
+	ctx context.Context,
+	db *sql.DB,
+	shifts []model.Shift,
+) (map[int][]model.ShiftBreak, error) {
+	breaks := map[int][]model.ShiftBreak{}
+	if len(shifts) == 0 {
+		return breaks, nil
+	}
+
+	shift_ids := make([]int, len(shifts))
+	for i := range shifts {
+		shift_ids[i] = shifts[i].Id
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT id, shift_id, break_type_id, paid, max_minutes, start_ts, end_ts
+		FROM shift_break
+		WHERE shift_id = ANY($1)
+		ORDER BY start_ts
+		`,
+		pq.Array(shift_ids),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetBreaks: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shiftBreak model.ShiftBreak
+		err = rows.Scan(
+			&shiftBreak.Id,
+			&shiftBreak.ShiftId,
+			&shiftBreak.BreakTypeId,
+			&shiftBreak.Paid,
+			&shiftBreak.MaxMinutes,
+			&shiftBreak.StartTs,
+			&shiftBreak.EndTs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("GetBreaks: db scan: %w", err)
+		}
+
+		breaks[shiftBreak.ShiftId] = append(breaks[shiftBreak.ShiftId], shiftBreak)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetBreaks: rows: %w", err)
+	}
+
+	return breaks, nil
+}
+
+func getContractVersions(
+	ctx context.Context,
+	db *sql.DB,
+	contract_id int,
+) ([]model.Contract, error) {
+	versions := []model.Contract{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT
+			contract_id, id, effective_from, hourly_rate, unpaid_lunch_minutes,
+			daily_overtime_minutes, weekly_overtime_minutes, overtime_multiplier
+		FROM contract_version
+		WHERE contract_id = $1
+		ORDER BY effective_from
+		`,
+		contract_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getContractVersions: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version model.Contract
+		err = rows.Scan(
+			&version.Id,
+			&version.VersionId,
+			&version.EffectiveFrom,
+			&version.HourlyRate,
+			&version.UnpaidLunchMinutes,
+			&version.DailyOvertimeMinutes,
+			&version.WeeklyOvertimeMinutes,
+			&version.OvertimeMultiplier,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getContractVersions: db scan: %w", err)
+		}
+
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getContractVersions: rows: %w", err)
+	}
+
+	return versions, nil
+}
+
+func getPayRules(
+	ctx context.Context,
+	db *sql.DB,
+	contract_id int,
+) ([]model.PayRule, error) {
+	rules := []model.PayRule{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT id, contract_id, name, kind, days_mask, start_minute, end_minute, multiplier
+		FROM pay_rule
+		WHERE contract_id = $1
+		ORDER BY id
+		`,
+		contract_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getPayRules: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule model.PayRule
+		err = rows.Scan(
+			&rule.Id,
+			&rule.ContractId,
+			&rule.Name,
+			&rule.Kind,
+			&rule.DaysMask,
+			&rule.StartMinute,
+			&rule.EndMinute,
+			&rule.Multiplier,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getPayRules: db scan: %w", err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getPayRules: rows: %w", err)
+	}
+
+	return rules, nil
+}
+
+// getHolidays returns the holidays on the dates from and to fall on, in
+// their own time zone, and the ones in between.
+func getHolidays(
+	ctx context.Context,
+	db *sql.DB,
+	workspace_id int,
+	from time.Time,
+	to time.Time,
+) (map[string]bool, error) {
+	holidays := map[string]bool{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT to_char(date, 'YYYY-MM-DD')
+		FROM holiday
+		WHERE workspace_id = $1
+		AND date >= $2::date
+		AND date <= $3::date
+		`,
+		workspace_id,
+		from.Format(time.DateOnly),
+		to.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getHolidays: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("getHolidays: db scan: %w", err)
+		}
+
+		holidays[date] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getHolidays: rows: %w", err)
+	}
+
+	return holidays, nil
+}
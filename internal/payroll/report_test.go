@@ -0,0 +1,81 @@
+package payroll
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"test/internal/db/dbtest"
+	"testing"
+	"time"
+)
+
+// addShift adds a finished eight hour shift on a new task of the company.
+func addShift(t *testing.T, db *sql.DB, profile_id int, company_id int, start time.Time) int {
+	t.Helper()
+
+	location_id := dbtest.QueryInt(t, db, `
+		INSERT INTO location (name, address, workspace_id)
+		SELECT 'Site', 'Street 1', workspace_id FROM company WHERE id = $1
+		RETURNING id`, company_id)
+	task_id := dbtest.QueryInt(t, db, `
+		INSERT INTO task (location_id, company_id, name)
+		VALUES ($1, $2, 'Task')
+		RETURNING id`, location_id, company_id)
+	return dbtest.QueryInt(t, db, `
+		INSERT INTO shift (profile_id, task_id, start_ts, end_ts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`, profile_id, task_id, start, start.Add(8*time.Hour))
+}
+
+func TestComputeEmploymentWorkspaceLevel(t *testing.T) {
+	db := dbtest.Open(t)
+
+	profile_id := dbtest.QueryInt(t, db, `
+		INSERT INTO profile (kt, first_name, last_name)
+		VALUES ('0101302989', 'Jón', 'Jónsson')
+		RETURNING id`)
+	workspace_id := dbtest.QueryInt(t, db, `INSERT INTO workspace (name) VALUES ('Ours') RETURNING id`)
+	other_workspace_id := dbtest.QueryInt(t, db, `INSERT INTO workspace (name) VALUES ('Theirs') RETURNING id`)
+	first_id := dbtest.QueryInt(t, db, `INSERT INTO company (name, workspace_id) VALUES ('First', $1) RETURNING id`, workspace_id)
+	second_id := dbtest.QueryInt(t, db, `INSERT INTO company (name, workspace_id) VALUES ('Second', $1) RETURNING id`, workspace_id)
+	other_id := dbtest.QueryInt(t, db, `INSERT INTO company (name, workspace_id) VALUES ('Other', $1) RETURNING id`, other_workspace_id)
+
+	owner_id := dbtest.QueryInt(t, db, `
+		INSERT INTO employment (profile_id, workspace_id, role, end_date)
+		VALUES ($1, $2, 'owner', NULL)
+		RETURNING id`, profile_id, workspace_id)
+	worker_id := dbtest.QueryInt(t, db, `
+		INSERT INTO employment (profile_id, company_id, role)
+		VALUES ($1, $2, 'worker')
+		RETURNING id`, profile_id, first_id)
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	first_shift := addShift(t, db, profile_id, first_id, start)
+	second_shift := addShift(t, db, profile_id, second_id, start.AddDate(0, 0, 1))
+	addShift(t, db, profile_id, other_id, start.AddDate(0, 0, 2))
+
+	tests := []struct {
+		name          string
+		employment_id int
+		want          []int
+	}{
+		{"workspace level", owner_id, []int{first_shift, second_shift}},
+		{"company level", worker_id, []int{first_shift}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ComputeEmployment(context.Background(), db, tt.employment_id, start, start.AddDate(0, 0, 7))
+			if err != nil {
+				t.Fatalf("ComputeEmployment: %v", err)
+			}
+
+			got := []int{}
+			for _, shift := range report.Shifts {
+				got = append(got, shift.ShiftId)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("shifts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
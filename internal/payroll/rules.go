@@ -0,0 +1,328 @@
+package payroll
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"test/internal/model"
+	"time"
+)
+
+const (
+	ruleBase     = "base"
+	ruleOvertime = "overtime"
+)
+
+// RuleSet is everything that decides the rate of a minute of work.
+type RuleSet struct {
+	// versions of the contract, oldest first
+	Versions []model.Contract
+	Rules    []model.PayRule
+	// public holidays keyed by date, 2006-01-02
+	Holidays map[string]bool
+	// windows, days and overtime are worked out in this time zone
+	Location *time.Location
+}
+
+// calculator splits shifts into rate segments. It has to see the shifts in
+// order, since overtime depends on what was worked earlier that day and week.
+type calculator struct {
+	rules    RuleSet
+	dayPaid  map[string]time.Duration
+	weekPaid map[string]time.Duration
+}
+
+type interval struct {
+	start time.Time
+	end   time.Time
+}
+
+func newCalculator(rules RuleSet) *calculator {
+	if rules.Location == nil {
+		rules.Location = time.UTC
+	}
+	return &calculator{
+		rules: rules,
+		dayPaid: map[string]time.Duration{},
+		weekPaid: map[string]time.Duration{},
+	}
+}
+
+// contractAt returns the contract version in force at t, the same way
+// contract_version_at does, or nil when there is no contract.
+func (r RuleSet) contractAt(t time.Time) *model.Contract {
+	if len(r.Versions) == 0 {
+		return nil
+	}
+	contract := &r.Versions[0]
+	for i := range r.Versions {
+		if r.Versions[i].EffectiveFrom.After(t) {
+			break
+		}
+		contract = &r.Versions[i]
+	}
+	return contract
+}
+
+// computeShift works out the hours, rate segments and gross pay of one
+// finished shift, on the contract terms in force when it started. Where
+// several premiums apply the highest multiplier wins, they don't stack.
+// When shift.Segments is set only the time in those segments is counted,
+// breaks and the unpaid lunch still falling where they would in the whole
+// shift.
+func (c *calculator) computeShift(
+	shift model.Shift,
+	breaks []model.ShiftBreak,
+) ShiftEarnings {
+	contract := c.rules.contractAt(shift.StartTs)
+	worked, _ := Durations(shift.StartTs, *shift.EndTs, breaks, contract)
+	spans := payableIntervals(shift.StartTs, *shift.EndTs, breaks, contract)
+
+	if len(shift.Segments) > 0 {
+		counted := []interval{}
+		for _, segment := range shift.Segments {
+			counted = append(counted, interval{segment.StartTs, *segment.EndTs})
+		}
+		spans = clipIntervals(spans, counted)
+
+		// worked time leaves out paid breaks as well
+		unpaid := make([]model.ShiftBreak, len(breaks))
+		for i, b := range breaks {
+			unpaid[i] = b
+			unpaid[i].Paid = false
+		}
+		worked = 0
+		for _, span := range clipIntervals(payableIntervals(shift.StartTs, *shift.EndTs, unpaid, contract), counted) {
+			worked += span.end.Sub(span.start)
+		}
+	}
+
+	rate := 0
+	if contract != nil {
+		rate = contract.HourlyRate
+	}
+
+	earnings := ShiftEarnings{
+		ShiftId: shift.Id,
+		Date: shift.StartTs.In(c.rules.Location).Format(time.DateOnly),
+		StartTs: shift.StartTs.In(c.rules.Location),
+		EndTs: shift.EndTs.In(c.rules.Location),
+		WorkedMinutes: int(worked / time.Minute),
+		HourlyRate: rate,
+		Segments: []RateSegment{},
+	}
+
+	var payable, overtime, premium time.Duration
+	gross := 0.0
+
+	for _, span := range spans {
+		for t := span.start; t.Before(span.end); {
+			next := t.Truncate(time.Minute).Add(time.Minute)
+			if next.After(span.end) {
+				next = span.end
+			}
+			step := next.Sub(t)
+
+			multiplier, rule, isOvertime := c.rate(contract, t, step)
+
+			payable += step
+			if isOvertime {
+				overtime += step
+			}
+			if rule != ruleBase && rule != ruleOvertime {
+				premium += step
+			}
+			gross += step.Hours() * float64(rate) * multiplier
+
+			last := len(earnings.Segments) - 1
+			if last >= 0 &&
+				earnings.Segments[last].EndTs.Equal(t) &&
+				earnings.Segments[last].Rule == rule &&
+				earnings.Segments[last].Multiplier == multiplier {
+				earnings.Segments[last].EndTs = next
+			} else {
+				earnings.Segments = append(earnings.Segments, RateSegment{
+					StartTs: t,
+					EndTs: next,
+					Multiplier: multiplier,
+					Rule: rule,
+				})
+			}
+
+			t = next
+		}
+	}
+
+	for i := range earnings.Segments {
+		segment := &earnings.Segments[i]
+		segment.Minutes = int(segment.EndTs.Sub(segment.StartTs) / time.Minute)
+		segment.StartTs = segment.StartTs.In(c.rules.Location)
+		segment.EndTs = segment.EndTs.In(c.rules.Location)
+	}
+
+	earnings.PayableMinutes = int(payable / time.Minute)
+	earnings.OvertimeMinutes = int(overtime / time.Minute)
+	earnings.PremiumMinutes = int(premium / time.Minute)
+	earnings.GrossPay = int(math.Round(gross))
+
+	return earnings
+}
+
+// rate returns the multiplier and the name of the rule that sets it for the
+// step starting at t, and whether the step is overtime. The step is counted
+// towards the day and week totals.
+func (c *calculator) rate(contract *model.Contract, t time.Time, step time.Duration) (float64, string, bool) {
+	local := t.In(c.rules.Location)
+	date := local.Format(time.DateOnly)
+	year, week := local.ISOWeek()
+	weekKey := fmt.Sprintf("%d-W%02d", year, week)
+
+	isOvertime := false
+	if contract != nil {
+		if contract.DailyOvertimeMinutes != nil &&
+			c.dayPaid[date] >= time.Duration(*contract.DailyOvertimeMinutes)*time.Minute {
+			isOvertime = true
+		}
+		if contract.WeeklyOvertimeMinutes != nil &&
+			c.weekPaid[weekKey] >= time.Duration(*contract.WeeklyOvertimeMinutes)*time.Minute {
+			isOvertime = true
+		}
+	}
+	c.dayPaid[date] += step
+	c.weekPaid[weekKey] += step
+
+	multiplier := 1.0
+	rule := ruleBase
+
+	minute := local.Hour()*60 + local.Minute()
+	for _, r := range c.rules.Rules {
+		if !inWindow(minute, r.StartMinute, r.EndMinute) {
+			continue
+		}
+		switch r.Kind {
+		case model.PayRuleWindow:
+			if r.DaysMask&(1<<int(local.Weekday())) == 0 {
+				continue
+			}
+		case model.PayRuleHoliday:
+			if !c.rules.Holidays[date] {
+				continue
+			}
+		default:
+			continue
+		}
+		if r.Multiplier > multiplier {
+			multiplier = r.Multiplier
+			rule = r.Name
+		}
+	}
+
+	if isOvertime && contract.OvertimeMultiplier > multiplier {
+		multiplier = contract.OvertimeMultiplier
+		rule = ruleOvertime
+	}
+
+	return multiplier, rule, isOvertime
+}
+
+// inWindow reports whether minute falls in [start, end), where a window with
+// start after end wraps past midnight.
+func inWindow(minute, start, end int) bool {
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// payableIntervals returns the parts of [start, end) that are paid. Without
+// recorded breaks the contract's flat unpaid lunch is taken out of the
+// middle of the shift.
+func payableIntervals(
+	start time.Time,
+	end time.Time,
+	breaks []model.ShiftBreak,
+	contract *model.Contract,
+) []interval {
+	unpaid := []interval{}
+
+	if len(breaks) == 0 {
+		if contract != nil && contract.UnpaidLunchMinutes > 0 {
+			lunch := time.Duration(contract.UnpaidLunchMinutes) * time.Minute
+			l_start := start.Add(end.Sub(start)/2 - lunch/2)
+			unpaid = append(unpaid, interval{l_start, l_start.Add(lunch)})
+		}
+	}
+
+	for _, b := range breaks {
+		b_end := end
+		if b.EndTs != nil && b.EndTs.Before(end) {
+			b_end = *b.EndTs
+		}
+
+		u_start := b.StartTs
+		if b.Paid {
+			if b.MaxMinutes == nil {
+				continue
+			}
+			u_start = u_start.Add(time.Duration(*b.MaxMinutes) * time.Minute)
+		}
+
+		// a paid break within its max has nothing unpaid
+		if b_end.After(u_start) {
+			unpaid = append(unpaid, interval{u_start, b_end})
+		}
+	}
+
+	sort.Slice(unpaid, func(i, j int) bool {
+		return unpaid[i].start.Before(unpaid[j].start)
+	})
+
+	payable := []interval{}
+	cursor := start
+	for _, u := range unpaid {
+		if u.start.After(cursor) {
+			payable = append(payable, interval{cursor, minTime(u.start, end)})
+		}
+		if u.end.After(cursor) {
+			cursor = u.end
+		}
+		if !cursor.Before(end) {
+			break
+		}
+	}
+	if cursor.Before(end) {
+		payable = append(payable, interval{cursor, end})
+	}
+
+	return payable
+}
+
+// clipIntervals returns the parts of spans that fall within one of within,
+// both sorted and without overlaps.
+func clipIntervals(spans []interval, within []interval) []interval {
+	clipped := []interval{}
+	for _, span := range spans {
+		for _, w := range within {
+			start := maxTime(span.start, w.start)
+			end := minTime(span.end, w.end)
+			if start.Before(end) {
+				clipped = append(clipped, interval{start, end})
+			}
+		}
+	}
+	return clipped
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,298 @@
+package payroll
+
+import (
+	"test/internal/model"
+	"testing"
+	"time"
+)
+
+// at returns the time of day on 2025-03-03, a Monday, in UTC.
+func at(hour, minute int) time.Time {
+	return time.Date(2025, 3, 3, hour, minute, 0, 0, time.UTC)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func paidMinutes(intervals []interval) int {
+	total := time.Duration(0)
+	for _, i := range intervals {
+		total += i.end.Sub(i.start)
+	}
+	return int(total / time.Minute)
+}
+
+func TestPayableIntervals(t *testing.T) {
+	contract := &model.Contract{HourlyRate: 100, UnpaidLunchMinutes: 30}
+
+	tests := []struct {
+		name     string
+		breaks   []model.ShiftBreak
+		contract *model.Contract
+		want     []interval
+		// Durations doesn't account for breaks overlapping
+		overlapping bool
+	}{
+		{
+			name: "no breaks",
+			want: []interval{{at(8, 0), at(16, 0)}},
+		},
+		{
+			name:     "no breaks, flat lunch",
+			contract: contract,
+			want:     []interval{{at(8, 0), at(11, 45)}, {at(12, 15), at(16, 0)}},
+		},
+		{
+			name:     "unpaid break",
+			contract: contract,
+			breaks: []model.ShiftBreak{
+				{StartTs: at(12, 0), EndTs: ptr(at(12, 30))},
+			},
+			want: []interval{{at(8, 0), at(12, 0)}, {at(12, 30), at(16, 0)}},
+		},
+		{
+			name: "paid break under max",
+			breaks: []model.ShiftBreak{
+				{Paid: true, MaxMinutes: ptr(15), StartTs: at(10, 0), EndTs: ptr(at(10, 10))},
+			},
+			want: []interval{{at(8, 0), at(16, 0)}},
+		},
+		{
+			name: "paid break over max",
+			breaks: []model.ShiftBreak{
+				{Paid: true, MaxMinutes: ptr(15), StartTs: at(10, 0), EndTs: ptr(at(10, 25))},
+			},
+			want: []interval{{at(8, 0), at(10, 15)}, {at(10, 25), at(16, 0)}},
+		},
+		{
+			name: "paid break without max",
+			breaks: []model.ShiftBreak{
+				{Paid: true, StartTs: at(10, 0), EndTs: ptr(at(11, 0))},
+			},
+			want: []interval{{at(8, 0), at(16, 0)}},
+		},
+		{
+			name: "open break",
+			breaks: []model.ShiftBreak{
+				{StartTs: at(15, 0)},
+			},
+			want: []interval{{at(8, 0), at(15, 0)}},
+		},
+		{
+			name: "overlapping breaks",
+			breaks: []model.ShiftBreak{
+				{StartTs: at(12, 0), EndTs: ptr(at(12, 30))},
+				{Paid: true, MaxMinutes: ptr(10), StartTs: at(12, 0), EndTs: ptr(at(12, 40))},
+			},
+			want:        []interval{{at(8, 0), at(12, 0)}, {at(12, 40), at(16, 0)}},
+			overlapping: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := payableIntervals(at(8, 0), at(16, 0), tt.breaks, tt.contract)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].start.Equal(tt.want[i].start) || !got[i].end.Equal(tt.want[i].end) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+
+			if tt.overlapping {
+				return
+			}
+			_, payable := Durations(at(8, 0), at(16, 0), tt.breaks, tt.contract)
+			if paidMinutes(got) != int(payable/time.Minute) {
+				t.Errorf("payable %d minutes, Durations says %v", paidMinutes(got), payable)
+			}
+		})
+	}
+}
+
+func TestComputeShiftPaidBreakUnderMax(t *testing.T) {
+	c := newCalculator(RuleSet{
+		Versions: []model.Contract{{HourlyRate: 100, OvertimeMultiplier: 1}},
+	})
+
+	earnings := c.computeShift(
+		model.Shift{Id: 1, StartTs: at(8, 0), EndTs: ptr(at(16, 0))},
+		[]model.ShiftBreak{
+			{Paid: true, MaxMinutes: ptr(15), StartTs: at(10, 0), EndTs: ptr(at(10, 10))},
+		},
+	)
+
+	if earnings.PayableMinutes != 480 {
+		t.Errorf("PayableMinutes = %d, want 480", earnings.PayableMinutes)
+	}
+	if earnings.GrossPay != 800 {
+		t.Errorf("GrossPay = %d, want 800", earnings.GrossPay)
+	}
+}
+
+func TestComputeShiftSegments(t *testing.T) {
+	c := newCalculator(RuleSet{
+		Versions: []model.Contract{{HourlyRate: 100, UnpaidLunchMinutes: 30, OvertimeMultiplier: 1}},
+	})
+
+	tests := []struct {
+		name            string
+		breaks          []model.ShiftBreak
+		worked, payable int
+	}{
+		// the lunch is taken out of the middle of the whole shift
+		{"flat lunch", nil, 225, 225},
+		{"paid break", []model.ShiftBreak{{Paid: true, StartTs: at(10, 0), EndTs: ptr(at(10, 15))}}, 225, 240},
+		{"break in the afternoon", []model.ShiftBreak{{StartTs: at(13, 0), EndTs: ptr(at(13, 30))}}, 240, 240},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the morning was worked here and the afternoon for another company
+			earnings := c.computeShift(
+				model.Shift{
+					Id: 1, StartTs: at(8, 0), EndTs: ptr(at(16, 0)),
+					Segments: []model.ShiftSegment{{TaskId: 1, StartTs: at(8, 0), EndTs: ptr(at(12, 0))}},
+				},
+				tt.breaks,
+			)
+			if earnings.WorkedMinutes != tt.worked || earnings.PayableMinutes != tt.payable {
+				t.Errorf("worked %d and payable %d minutes, want %d and %d",
+					earnings.WorkedMinutes, earnings.PayableMinutes, tt.worked, tt.payable)
+			}
+		})
+	}
+}
+
+// shift returns a finished shift from start to end.
+func shift(id int, start time.Time, end time.Time) model.Shift {
+	return model.Shift{Id: id, StartTs: start, EndTs: &end}
+}
+
+func TestComputeShiftRates(t *testing.T) {
+	base := model.Contract{HourlyRate: 100, OvertimeMultiplier: 1.5}
+	daily := base
+	daily.DailyOvertimeMinutes = ptr(480)
+	weekly := base
+	weekly.WeeklyOvertimeMinutes = ptr(600)
+
+	night := model.PayRule{Name: "night", Kind: model.PayRuleWindow, DaysMask: 127, StartMinute: 22 * 60, EndMinute: 6 * 60, Multiplier: 2}
+	holiday := model.PayRule{Name: "holiday", Kind: model.PayRuleHoliday, StartMinute: 0, EndMinute: 24 * 60, Multiplier: 2}
+	weekend := model.PayRule{Name: "weekend", Kind: model.PayRuleWindow, DaysMask: 1<<time.Saturday | 1<<time.Sunday, StartMinute: 0, EndMinute: 24 * 60, Multiplier: 1.5}
+
+	type want struct {
+		payable, overtime, premium, gross int
+	}
+	tests := []struct {
+		name   string
+		rules  RuleSet
+		shifts []model.Shift
+		// of the last shift
+		want want
+	}{
+		{
+			name:   "base rate",
+			rules:  RuleSet{Versions: []model.Contract{base}},
+			shifts: []model.Shift{shift(1, at(8, 0), at(16, 0))},
+			want:   want{480, 0, 0, 800},
+		},
+		{
+			name:   "daily overtime",
+			rules:  RuleSet{Versions: []model.Contract{daily}},
+			shifts: []model.Shift{shift(1, at(8, 0), at(18, 0))},
+			want:   want{600, 120, 0, 1100},
+		},
+		{
+			name:  "daily overtime over two shifts",
+			rules: RuleSet{Versions: []model.Contract{daily}},
+			shifts: []model.Shift{
+				shift(1, at(8, 0), at(12, 0)),
+				shift(2, at(13, 0), at(19, 0)),
+			},
+			want: want{360, 120, 0, 700},
+		},
+		{
+			name:  "weekly overtime",
+			rules: RuleSet{Versions: []model.Contract{weekly}},
+			shifts: []model.Shift{
+				shift(1, at(8, 0), at(16, 0)),
+				shift(2, at(8, 0).AddDate(0, 0, 1), at(12, 0).AddDate(0, 0, 1)),
+			},
+			want: want{240, 120, 0, 500},
+		},
+		{
+			name:   "night window past midnight",
+			rules:  RuleSet{Versions: []model.Contract{base}, Rules: []model.PayRule{night}},
+			shifts: []model.Shift{shift(1, at(20, 0), at(24, 0))},
+			want:   want{240, 0, 120, 600},
+		},
+		{
+			name:   "window on other days",
+			rules:  RuleSet{Versions: []model.Contract{base}, Rules: []model.PayRule{weekend}},
+			shifts: []model.Shift{shift(1, at(8, 0), at(16, 0))},
+			want:   want{480, 0, 0, 800},
+		},
+		{
+			name: "holiday",
+			rules: RuleSet{
+				Versions: []model.Contract{base},
+				Rules:    []model.PayRule{holiday},
+				Holidays: map[string]bool{"2025-03-03": true},
+			},
+			shifts: []model.Shift{shift(1, at(8, 0), at(16, 0))},
+			want:   want{480, 0, 480, 1600},
+		},
+		{
+			name:   "highest multiplier wins over overtime",
+			rules:  RuleSet{Versions: []model.Contract{daily}, Rules: []model.PayRule{night}},
+			shifts: []model.Shift{shift(1, at(14, 0), at(24, 0))},
+			// 8 hours base, then 22-24 is both overtime and night
+			want: want{600, 120, 120, 1200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCalculator(tt.rules)
+			var earnings ShiftEarnings
+			for _, s := range tt.shifts {
+				earnings = c.computeShift(s, nil)
+			}
+
+			got := want{earnings.PayableMinutes, earnings.OvertimeMinutes, earnings.PremiumMinutes, earnings.GrossPay}
+			if got != tt.want {
+				t.Errorf("payable, overtime, premium, gross = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	shifts := []ShiftEarnings{
+		{Date: "2025-03-03", WorkedMinutes: 240, PayableMinutes: 240, GrossPay: 400},
+		{Date: "2025-03-04", WorkedMinutes: 480, PayableMinutes: 450, OvertimeMinutes: 30, GrossPay: 775},
+		{Date: "2025-03-03", WorkedMinutes: 120, PayableMinutes: 120, PremiumMinutes: 60, GrossPay: 250},
+	}
+
+	days, total := Summarize(shifts)
+
+	wantDays := []DayEarnings{
+		{Date: "2025-03-03", WorkedMinutes: 360, PayableMinutes: 360, PremiumMinutes: 60, GrossPay: 650},
+		{Date: "2025-03-04", WorkedMinutes: 480, PayableMinutes: 450, OvertimeMinutes: 30, GrossPay: 775},
+	}
+	if len(days) != len(wantDays) {
+		t.Fatalf("days = %+v, want %+v", days, wantDays)
+	}
+	for i := range days {
+		if days[i] != wantDays[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], wantDays[i])
+		}
+	}
+
+	wantTotal := Totals{WorkedMinutes: 840, PayableMinutes: 810, OvertimeMinutes: 30, PremiumMinutes: 60, GrossPay: 1425}
+	if total != wantTotal {
+		t.Errorf("total = %+v, want %+v", total, wantTotal)
+	}
+}
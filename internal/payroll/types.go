@@ -0,0 +1,60 @@
+package payroll
+
+import "time"
+
+// RateSegment is a stretch of paid time at one rate. Rule is base, overtime
+// or the name of the pay rule that set the multiplier.
+type RateSegment struct {
+	StartTs    time.Time `json:"start_ts"`
+	EndTs      time.Time `json:"end_ts"`
+	Minutes    int       `json:"minutes"`
+	Multiplier float64   `json:"multiplier"`
+	Rule       string    `json:"rule"`
+}
+
+type ShiftEarnings struct {
+	ShiftId         int           `json:"shift_id"`
+	Date            string        `json:"date"`
+	StartTs         time.Time     `json:"start_ts"`
+	EndTs           time.Time     `json:"end_ts"`
+	WorkedMinutes   int           `json:"worked_minutes"`
+	PayableMinutes  int           `json:"payable_minutes"`
+	OvertimeMinutes int           `json:"overtime_minutes"`
+	PremiumMinutes  int           `json:"premium_minutes"`
+	HourlyRate      int           `json:"hourly_rate"`
+	GrossPay        int           `json:"gross_pay"`
+	Segments        []RateSegment `json:"segments"`
+}
+
+type DayEarnings struct {
+	Date            string `json:"date"`
+	WorkedMinutes   int    `json:"worked_minutes"`
+	PayableMinutes  int    `json:"payable_minutes"`
+	OvertimeMinutes int    `json:"overtime_minutes"`
+	PremiumMinutes  int    `json:"premium_minutes"`
+	GrossPay        int    `json:"gross_pay"`
+}
+
+type Totals struct {
+	WorkedMinutes   int `json:"worked_minutes"`
+	PayableMinutes  int `json:"payable_minutes"`
+	OvertimeMinutes int `json:"overtime_minutes"`
+	PremiumMinutes  int `json:"premium_minutes"`
+	GrossPay        int `json:"gross_pay"`
+}
+
+// Report holds the earnings of one employment over [From, To). Only finished
+// shifts are counted. HourlyRate is the rate in force at From, each shift
+// carries the rate it was paid at. Dates and times are in TimeZone, the time
+// zone of the workspace.
+type Report struct {
+	EmploymentId int             `json:"employment_id"`
+	ProfileId    int             `json:"profile_id"`
+	TimeZone     string          `json:"time_zone"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	HourlyRate   int             `json:"hourly_rate"`
+	Shifts       []ShiftEarnings `json:"shifts"`
+	Days         []DayEarnings   `json:"days"`
+	Total        Totals          `json:"total"`
+}
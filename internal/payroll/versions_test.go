@@ -0,0 +1,93 @@
+package payroll
+
+import (
+	"context"
+	"test/internal/db/dbtest"
+	"test/internal/model"
+	"testing"
+	"time"
+)
+
+// versions are the terms of a contract that got a raise on 2025-03-04 and
+// another one on 2025-04-01.
+var versions = []model.Contract{
+	{VersionId: 1, EffectiveFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), HourlyRate: 100, OvertimeMultiplier: 1},
+	{VersionId: 2, EffectiveFrom: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), HourlyRate: 120, OvertimeMultiplier: 1},
+	{VersionId: 3, EffectiveFrom: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), HourlyRate: 150, OvertimeMultiplier: 1},
+}
+
+func TestContractAt(t *testing.T) {
+	rules := RuleSet{Versions: versions}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{"before the first version", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), 1},
+		{"first version", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), 1},
+		{"when a version takes effect", time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), 2},
+		{"just before", time.Date(2025, 3, 3, 23, 59, 0, 0, time.UTC), 1},
+		{"latest version", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules.contractAt(tt.at)
+			if got == nil || got.VersionId != tt.want {
+				t.Errorf("contractAt = %+v, want version %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := (RuleSet{}).contractAt(time.Now()); got != nil {
+		t.Errorf("contractAt without versions = %+v, want nil", got)
+	}
+}
+
+func TestComputeShiftAcrossRaise(t *testing.T) {
+	c := newCalculator(RuleSet{Versions: versions})
+
+	// the shift starting before the raise is paid the old rate for all of
+	// it, the next one the new rate
+	before := c.computeShift(shift(1, at(20, 0), at(20, 0).Add(8*time.Hour)), nil)
+	after := c.computeShift(shift(2, at(8, 0).AddDate(0, 0, 1), at(16, 0).AddDate(0, 0, 1)), nil)
+
+	if before.HourlyRate != 100 || before.GrossPay != 800 {
+		t.Errorf("before the raise: rate %d, gross %d, want 100, 800", before.HourlyRate, before.GrossPay)
+	}
+	if after.HourlyRate != 120 || after.GrossPay != 960 {
+		t.Errorf("after the raise: rate %d, gross %d, want 120, 960", after.HourlyRate, after.GrossPay)
+	}
+}
+
+// TestContractAtMatchesDB checks contractAt against contract_version_at,
+// which the rest of the code resolves versions with.
+func TestContractAtMatchesDB(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	contract_id := dbtest.QueryInt(t, db, `INSERT INTO contract DEFAULT VALUES RETURNING id`)
+	for _, version := range versions {
+		dbtest.Exec(t, db, `
+			INSERT INTO contract_version (contract_id, effective_from, hourly_rate)
+			VALUES ($1, $2, $3)`, contract_id, version.EffectiveFrom, version.HourlyRate)
+	}
+
+	loaded, err := getContractVersions(ctx, db, contract_id)
+	if err != nil {
+		t.Fatalf("getContractVersions: %v", err)
+	}
+	rules := RuleSet{Versions: loaded}
+
+	for _, at := range []time.Time{
+		time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 3, 23, 59, 0, 0, time.UTC),
+		time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		want := dbtest.QueryInt(t, db, `SELECT hourly_rate FROM contract_version_at($1, $2)`, contract_id, at)
+		if got := rules.contractAt(at); got == nil || got.HourlyRate != want {
+			t.Errorf("at %v: contractAt = %+v, contract_version_at has rate %d", at, got, want)
+		}
+	}
+}
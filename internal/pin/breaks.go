@@ -0,0 +1,291 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/model"
+	"time"
+)
+
+func StartBreak(
+	ctx context.Context,
+	db *sql.DB,
+	input BreakStart_R,
+) (*model.ShiftBreak, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	if claims.SelectedEmployment() == nil {
+		return nil, ErrNoEmploymentSelected
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("StartBreak: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if input.StartTs == nil {
+		now := time.Now()
+		input.StartTs = &now
+	}
+
+	var shift_id int
+	var shift_start time.Time
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT id, start_ts
+		FROM shift
+		WHERE profile_id = $1
+		AND end_ts IS NULL
+		FOR UPDATE
+		`,
+		profile_id,
+	).Scan(
+		&shift_id,
+		&shift_start,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotClockedIn
+		}
+		return nil, fmt.Errorf("StartBreak: select shift: %w", err)
+	}
+
+	if input.StartTs.Before(shift_start) {
+		return nil, ErrNegativeDuration
+	}
+
+	// a backdated break can't overlap the ones already taken
+	var last_end *time.Time
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT max(end_ts) FROM shift_break WHERE shift_id = $1
+		`,
+		shift_id,
+	).Scan(
+		&last_end,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("StartBreak: select breaks: %w", err)
+	}
+	if last_end != nil && input.StartTs.Before(*last_end) {
+		return nil, ErrBreakOverlaps
+	}
+
+	var shiftBreak model.ShiftBreak
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO shift_break (shift_id, break_type_id, paid, max_minutes, start_ts)
+		SELECT $1, bt.id, bt.paid, bt.max_minutes, $2
+		FROM break_type bt
+		JOIN employment e ON e.contract_id = bt.contract_id
+		WHERE bt.id = $3 AND e.id = $4
+		RETURNING id, shift_id, break_type_id, paid, max_minutes, start_ts, end_ts
+		`,
+		shift_id,
+		input.StartTs,
+		input.BreakTypeId,
+		claims.EmploymentID,
+	).Scan(
+		&shiftBreak.Id,
+		&shiftBreak.ShiftId,
+		&shiftBreak.BreakTypeId,
+		&shiftBreak.Paid,
+		&shiftBreak.MaxMinutes,
+		&shiftBreak.StartTs,
+		&shiftBreak.EndTs,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBreakTypeNotFound
+		}
+		return nil, translateDBError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("StartBreak: db commit: %w", err)
+	}
+
+	return &shiftBreak, nil
+}
+
+func EndBreak(
+	ctx context.Context,
+	db *sql.DB,
+	input BreakEnd_R,
+) (*model.ShiftBreak, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("EndBreak: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if input.EndTs == nil {
+		now := time.Now()
+		input.EndTs = &now
+	}
+
+	var shiftBreak model.ShiftBreak
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		UPDATE shift_break b
+		SET end_ts = $1, updated = now()
+		FROM shift s
+		WHERE s.id = b.shift_id
+		AND s.profile_id = $2
+		AND s.end_ts IS NULL
+		AND b.end_ts IS NULL
+		RETURNING b.id, b.shift_id, b.break_type_id, b.paid, b.max_minutes, b.start_ts, b.end_ts
+		`,
+		input.EndTs,
+		profile_id,
+	).Scan(
+		&shiftBreak.Id,
+		&shiftBreak.ShiftId,
+		&shiftBreak.BreakTypeId,
+		&shiftBreak.Paid,
+		&shiftBreak.MaxMinutes,
+		&shiftBreak.StartTs,
+		&shiftBreak.EndTs,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotOnBreak
+		}
+		return nil, fmt.Errorf("EndBreak: db update: %w", err)
+	}
+
+	if shiftBreak.EndTs.Before(shiftBreak.StartTs) {
+		return nil, ErrNegativeDuration
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("EndBreak: db commit: %w", err)
+	}
+
+	return &shiftBreak, nil
+}
+
+// endOpenBreak closes the shift's ongoing break, if any, when the shift ends.
+func endOpenBreak(
+	ctx context.Context,
+	tx *sql.Tx,
+	shift_id int,
+	end_ts time.Time,
+) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`
+		UPDATE shift_break
+		SET end_ts = GREATEST(start_ts, $1), updated = now()
+		WHERE shift_id = $2
+		AND end_ts IS NULL
+		`,
+		end_ts,
+		shift_id,
+	)
+	if err != nil {
+		return fmt.Errorf("endOpenBreak: db update: %w", err)
+	}
+
+	return nil
+}
+
+// GetBreakTypes lists the break types of the selected employment's contract.
+func GetBreakTypes(
+	ctx context.Context,
+	db *sql.DB,
+) (*[]model.BreakType, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	if claims.SelectedEmployment() == nil {
+		return nil, ErrNoEmploymentSelected
+	}
+
+	breakTypes := []model.BreakType{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT bt.id, bt.contract_id, bt.name, bt.paid, bt.max_minutes
+		FROM break_type bt
+		JOIN employment e ON e.contract_id = bt.contract_id
+		WHERE e.id = $1
+		ORDER BY bt.id
+		`,
+		claims.EmploymentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetBreakTypes: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var breakType model.BreakType
+		err = rows.Scan(
+			&breakType.Id,
+			&breakType.ContractId,
+			&breakType.Name,
+			&breakType.Paid,
+			&breakType.MaxMinutes,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("GetBreakTypes: db scan: %w", err)
+		}
+
+		breakTypes = append(breakTypes, breakType)
+	}
+
+	return &breakTypes, nil
+}
+
+func getShiftBreaks(
+	ctx context.Context,
+	db *sql.DB,
+	shift_id int,
+) ([]model.ShiftBreak, error) {
+	breaks := []model.ShiftBreak{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT id, shift_id, break_type_id, paid, max_minutes, start_ts, end_ts
+		FROM shift_break
+		WHERE shift_id = $1
+		ORDER BY start_ts
+		`,
+		shift_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getShiftBreaks: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shiftBreak model.ShiftBreak
+		err = rows.Scan(
+			&shiftBreak.Id,
+			&shiftBreak.ShiftId,
+			&shiftBreak.BreakTypeId,
+			&shiftBreak.Paid,
+			&shiftBreak.MaxMinutes,
+			&shiftBreak.StartTs,
+			&shiftBreak.EndTs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("getShiftBreaks: db scan: %w", err)
+		}
+
+		breaks = append(breaks, shiftBreak)
+	}
+
+	return breaks, nil
+}
@@ -0,0 +1,229 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/model"
+)
+
+const editRequestColumns = `r.id, r.shift_id, r.profile_id, r.task_id, r.start_ts, r.end_ts, COALESCE(r.reason, ''), r.status,
+	r.reviewer_id, r.review_comment, r.reviewed_at, r.created`
+
+func scanEditRequest(row interface{ Scan(...any) error }, edit_request *model.EditRequest) error {
+	return row.Scan(
+		&edit_request.Id,
+		&edit_request.ShiftId,
+		&edit_request.ProfileId,
+		&edit_request.TaskId,
+		&edit_request.StartTs,
+		&edit_request.EndTs,
+		&edit_request.Reason,
+		&edit_request.Status,
+		&edit_request.ReviewerId,
+		&edit_request.ReviewComment,
+		&edit_request.ReviewedAt,
+		&edit_request.Created,
+	)
+}
+
+// PostEditRequest asks a manager to correct one of the worker's own shifts.
+// A newer request for the same shift replaces any that is still pending.
+func PostEditRequest(
+	ctx context.Context,
+	db *sql.DB,
+	input EditRequest_R,
+) (*model.EditRequest, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PostEditRequest: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var shift model.Shift
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT id, start_ts, end_ts
+		FROM shift
+		WHERE id = $1 AND profile_id = $2
+		FOR UPDATE
+		`,
+		input.ShiftId,
+		profile_id,
+	).Scan(
+		&shift.Id,
+		&shift.StartTs,
+		&shift.EndTs,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrShiftNotFound
+		}
+		return nil, fmt.Errorf("PostEditRequest: select shift: %w", err)
+	}
+
+	start := shift.StartTs
+	if input.StartTs != nil {
+		start = *input.StartTs
+	}
+	end := shift.EndTs
+	if input.EndTs != nil {
+		end = input.EndTs
+	}
+	if end != nil && end.Before(start) {
+		return nil, ErrNegativeDuration
+	}
+
+	if input.TaskId != nil {
+		var exists bool
+		err = tx.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1
+				FROM task t
+				JOIN employment e ON e.company_id = t.company_id
+				WHERE t.id = $1
+				AND e.profile_id = $2
+				AND (e.start_date IS NULL OR e.start_date <= now())
+				AND (e.end_date IS NULL OR e.end_date > now())
+			)
+			`,
+			*input.TaskId,
+			profile_id,
+		).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("PostEditRequest: select task: %w", err)
+		}
+		if !exists {
+			return nil, ErrTaskNotFound
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		UPDATE edit_request
+		SET status = 'cancelled', updated = now()
+		WHERE shift_id = $1 AND profile_id = $2 AND status = 'pending'
+		`,
+		shift.Id,
+		profile_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("PostEditRequest: cancel pending: %w", err)
+	}
+
+	var edit_request model.EditRequest
+	err = scanEditRequest(tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO edit_request AS r (shift_id, profile_id, task_id, start_ts, end_ts, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+editRequestColumns,
+		shift.Id,
+		profile_id,
+		input.TaskId,
+		input.StartTs,
+		input.EndTs,
+		input.Reason,
+	), &edit_request)
+	if err != nil {
+		return nil, translateDBError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PostEditRequest: db commit: %w", err)
+	}
+
+	return &edit_request, nil
+}
+
+func GetEditRequests(
+	ctx context.Context,
+	db *sql.DB,
+	status *model.RequestStatus,
+) (*[]model.EditRequest, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	edit_requests := []model.EditRequest{}
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT `+editRequestColumns+`
+		FROM edit_request r
+		WHERE r.profile_id = $1
+		AND ($2::text IS NULL OR r.status = $2)
+		ORDER BY r.created DESC
+		`,
+		profile_id,
+		status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetEditRequests: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var edit_request model.EditRequest
+		err = scanEditRequest(rows, &edit_request)
+		if err != nil {
+			return nil, fmt.Errorf("GetEditRequests: db scan: %w", err)
+		}
+
+		edit_requests = append(edit_requests, edit_request)
+	}
+
+	return &edit_requests, nil
+}
+
+// CancelEditRequest withdraws one of the worker's own pending requests.
+func CancelEditRequest(
+	ctx context.Context,
+	db *sql.DB,
+	id int,
+) (*model.EditRequest, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	var edit_request model.EditRequest
+	err := scanEditRequest(db.QueryRowContext(
+		ctx,
+		`
+		UPDATE edit_request r
+		SET status = 'cancelled', updated = now()
+		WHERE r.id = $1 AND r.profile_id = $2 AND r.status = 'pending'
+		RETURNING `+editRequestColumns,
+		id,
+		profile_id,
+	), &edit_request)
+	if err == nil {
+		return &edit_request, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("CancelEditRequest: db update: %w", err)
+	}
+
+	var exists bool
+	err = db.QueryRowContext(
+		ctx,
+		`
+		SELECT EXISTS (SELECT 1 FROM edit_request WHERE id = $1 AND profile_id = $2)
+		`,
+		id,
+		profile_id,
+	).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("CancelEditRequest: db select: %w", err)
+	}
+	if exists {
+		return nil, ErrEditRequestNotPending
+	}
+	return nil, ErrEditRequestNotFound
+}
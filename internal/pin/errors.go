@@ -12,6 +12,23 @@ var (
 	ErrShiftAlreadyExists = errors.New("already clocked in")
 	ErrNotClockedIn       = errors.New("not clocked in")
 	ErrNegativeDuration   = errors.New("shift duration cannot be negative")
+	ErrNoEmploymentSelected = errors.New("no employment selected")
+	ErrTaskNotFound       = errors.New("task not found")
+	ErrOutsideGeofence    = errors.New("outside the location's geofence")
+	ErrPositionRequired   = errors.New("an accurate position is required at this location")
+	ErrBreakAlreadyStarted = errors.New("already on a break")
+	ErrNotOnBreak         = errors.New("not on a break")
+	ErrBreakTypeNotFound  = errors.New("break type not found")
+	ErrBreakOverlaps      = errors.New("break cannot start before the previous one ended")
+	ErrSameTask           = errors.New("already working on this task")
+	ErrShiftNotFound      = errors.New("shift not found")
+	ErrInvalidClientId    = errors.New("client_id must be a UUID")
+	ErrSyncBatchTooLarge  = errors.New("too many shifts in one sync")
+	ErrEditRequestNotFound = errors.New("edit request not found")
+	ErrEditRequestNotPending = errors.New("edit request is no longer pending")
+	ErrInvalidPeriod      = errors.New("period must be week, month or pay_period")
+	ErrInvalidHistoryRange = errors.New("give either from and to, month and year, or a period, with from before to")
+	ErrInvalidScheduleRange = errors.New("to must be after from and at most 62 days later")
 )
 
 func translateDBError(err error) error {
@@ -22,6 +39,12 @@ func translateDBError(err error) error {
 			if pqErr.Constraint == "one_ongoing_shift_per_employment" {
 				return ErrShiftAlreadyExists
 			}
+			if pqErr.Constraint == "one_shift_per_planned_occurrence" {
+				return ErrShiftAlreadyExists
+			}
+			if pqErr.Constraint == "one_ongoing_break_per_shift" {
+				return ErrBreakAlreadyStarted
+			}
 		}
 	}
 	return err
@@ -35,6 +58,40 @@ func WriteDomainError(w http.ResponseWriter, err error) {
 		http.Error(w, err.Error(), http.StatusConflict)
 	case errors.Is(err, ErrNegativeDuration):
 		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrNoEmploymentSelected):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrTaskNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrOutsideGeofence):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrPositionRequired):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, ErrBreakAlreadyStarted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrNotOnBreak):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrBreakTypeNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrBreakOverlaps):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrSameTask):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrShiftNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidClientId):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrSyncBatchTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrEditRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrEditRequestNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidPeriod):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidHistoryRange):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrInvalidScheduleRange):
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
 		log.Printf("internal error: %+v", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
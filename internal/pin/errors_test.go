@@ -0,0 +1,31 @@
+package pin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslateDBError(t *testing.T) {
+	other := &pq.Error{Code: "23505", Constraint: "shift_pkey"}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"ongoing shift", &pq.Error{Code: "23505", Constraint: "one_ongoing_shift_per_employment"}, ErrShiftAlreadyExists},
+		{"occurrence clocked in for", &pq.Error{Code: "23505", Constraint: "one_shift_per_planned_occurrence"}, ErrShiftAlreadyExists},
+		{"ongoing break", &pq.Error{Code: "23505", Constraint: "one_ongoing_break_per_shift"}, ErrBreakAlreadyStarted},
+		{"other constraint", other, other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := translateDBError(tt.err); !errors.Is(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"test/internal/auth"
 	"test/internal/model"
+	"test/internal/payroll"
+	"test/internal/roster"
 	"time"
 )
 
@@ -18,6 +20,10 @@ func ClockIn(
 	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
 	profile_id := claims.ProfileID
 
+	if claims.CompanyScope() == nil {
+		return nil, ErrNoEmploymentSelected
+	}
+
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("ClockIn: begin tx: %w", err)
@@ -29,17 +35,48 @@ func ClockIn(
 		input.StartTs = &now
 	}
 
+	fence, err := getTaskGeofence(ctx, tx, input.TaskId, claims.CompanyScope())
+	if err != nil {
+		return nil, err
+	}
+
+	flagged, err := fence.enforce(input.Latitude, input.Longitude, input.Accuracy)
+	if err != nil {
+		return nil, err
+	}
+
+	// the shift is linked to the occurrence on the roster it was clocked in
+	// for, so the plan can be compared with what was worked. Matching in tx
+	// leaves a concurrent clock-in for the same occurrence to the unique
+	// index on it.
+	planned, err := roster.Match(ctx, tx, profile_id, input.TaskId, *input.StartTs)
+	if err != nil {
+		return nil, err
+	}
+	var planned_shift_id *int
+	var planned_start_ts *time.Time
+	if planned != nil {
+		planned_shift_id = &planned.PlannedShiftId
+		planned_start_ts = &planned.StartTs
+	}
+
 	var shift model.Shift
 	err = tx.QueryRowContext(
 		ctx,
 		`
-		INSERT INTO shift (profile_id, task_id, start_ts)
-		VALUES ($1, $2, $3)
-		RETURNING id, profile_id, task_id, start_ts, s_latitude, s_longitude
+		INSERT INTO shift (profile_id, task_id, start_ts, s_latitude, s_longitude, s_accuracy, s_flagged, planned_shift_id, planned_start_ts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, profile_id, task_id, start_ts, s_latitude, s_longitude, s_accuracy, s_flagged, version, planned_shift_id, planned_start_ts
 		`,
 		profile_id,
 		input.TaskId,
 		input.StartTs,
+		input.Latitude,
+		input.Longitude,
+		input.Accuracy,
+		flagged,
+		planned_shift_id,
+		planned_start_ts,
 	).Scan(
 		&shift.Id,
 		&shift.ProfileId,
@@ -47,6 +84,11 @@ func ClockIn(
 		&shift.StartTs,
 		&shift.SLatitude,
 		&shift.SLongitude,
+		&shift.SAccuracy,
+		&shift.SFlagged,
+		&shift.Version,
+		&shift.PlannedShiftId,
+		&shift.PlannedStartTs,
 	)
 	if err != nil {
 		return nil, translateDBError(err)
@@ -77,88 +119,32 @@ func ClockOut(
 		input.EndTs = &now
 	}
 
-	var shift model.Shift
-	err = tx.QueryRowContext(
-		ctx,
-		`
-		UPDATE shift 
-		SET end_ts = $1
-		WHERE end_ts IS NULL AND profile_id = $2
-		RETURNING id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude
-		`,
-		input.EndTs,
-		profile_id,
-	).Scan(
-		&shift.Id,
-		&shift.ProfileId,
-		&shift.TaskId,
-		&shift.StartTs,
-		&shift.EndTs,
-		&shift.SLatitude,
-		&shift.SLongitude,
-		&shift.ELatitude,
-		&shift.ELongitude,
-	)
+	shift_id, fence, err := getOpenShiftGeofence(ctx, tx, profile_id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNotClockedIn
-		}
-		return nil, fmt.Errorf("ClockOut: db update: %w", err)
-	}
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("ClockOut: db commit: %w", err)
+		return nil, err
 	}
 
-	return &shift, nil
-}
-
-func SyncShift(
-	ctx context.Context,
-	db *sql.DB,
-	input SyncShift_R,
-) (*model.Shift, error) {
-	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
-	profile_id := claims.ProfileID
-
-	tx, err := db.BeginTx(ctx, nil)
+	flagged, err := fence.enforce(input.Latitude, input.Longitude, input.Accuracy)
 	if err != nil {
-		return nil, fmt.Errorf("ClockIn: begin tx: %w", err)
-	}
-	defer tx.Rollback()
-
-	var idToInsert any
-	if input.RemoteId != nil {
-		idToInsert = *input.RemoteId
-	} else {
-		idToInsert = nil
+		return nil, err
 	}
 
 	var shift model.Shift
 	err = tx.QueryRowContext(
 		ctx,
 		`
-		INSERT INTO shift (id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude)
-		VALUES (COALESCE($1, nextval(pg_get_serial_sequence('shift', 'id'))), $2, $3, $4, $5, $6, $7, $8, $9)
-		ON CONFLICT (id) DO UPDATE SET
-			task_id = EXCLUDED.task_id,
-			start_ts = EXCLUDED.start_ts,
-			end_ts = EXCLUDED.end_ts,
-			profile_id = EXCLUDED.profile_id,
-			s_latitude = EXCLUDED.s_latitude,
-			s_longitude = EXCLUDED.s_longitude,
-			e_latitude = EXCLUDED.e_latitude,
-			e_longitude = EXCLUDED.e_longitude
-		RETURNING id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude
+		UPDATE shift 
+		SET end_ts = $1, e_latitude = $2, e_longitude = $3, e_accuracy = $4, e_flagged = $5
+		WHERE id = $6
+		RETURNING id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude,
+			s_accuracy, e_accuracy, s_flagged, e_flagged, version
 		`,
-		idToInsert,
-		profile_id,
-		input.TaskId,
-		input.StartTs,
 		input.EndTs,
-		input.SLatitude,
-		input.SLongitude,
-		input.ELatitude,
-		input.ELongitude,
+		input.Latitude,
+		input.Longitude,
+		input.Accuracy,
+		flagged,
+		shift_id,
 	).Scan(
 		&shift.Id,
 		&shift.ProfileId,
@@ -169,12 +155,23 @@ func SyncShift(
 		&shift.SLongitude,
 		&shift.ELatitude,
 		&shift.ELongitude,
+		&shift.SAccuracy,
+		&shift.EAccuracy,
+		&shift.SFlagged,
+		&shift.EFlagged,
+		&shift.Version,
 	)
 	if err != nil {
-		return nil, translateDBError(err)
+		return nil, fmt.Errorf("ClockOut: db update: %w", err)
+	}
+
+	err = endOpenBreak(ctx, tx, shift_id, *input.EndTs)
+	if err != nil {
+		return nil, err
 	}
+
 	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("SyncShift: db commit: %w", err)
+		return nil, fmt.Errorf("ClockOut: db commit: %w", err)
 	}
 
 	return &shift, nil
@@ -192,8 +189,8 @@ func GetShiftOverview(
 		ctx,
 		`
 		SELECT 
-			s.id, s.profile_id, s.task_id, s.start_ts, s.s_latitude, s.s_longitude,
-			l.id, l.workspace_id, l.name, l.address,
+			s.id, s.profile_id, s.task_id, s.start_ts, s.s_latitude, s.s_longitude, s.version,
+			l.id, l.workspace_id, l.name, l.address, l.latitude, l.longitude, l.radius_m,
 			t.id, t.location_id, t.company_id, t.name, t.description, t.is_completed
 		FROM shift s
 		JOIN task t ON t.id = s.task_id
@@ -209,10 +206,14 @@ func GetShiftOverview(
 		&shiftOverview.Shift.StartTs,
 		&shiftOverview.Shift.SLatitude,
 		&shiftOverview.Shift.SLongitude,
+		&shiftOverview.Shift.Version,
 		&shiftOverview.Location.Id,
 		&shiftOverview.Location.WorkspaceId,
 		&shiftOverview.Location.Name,
 		&shiftOverview.Location.Address,
+		&shiftOverview.Location.Latitude,
+		&shiftOverview.Location.Longitude,
+		&shiftOverview.Location.RadiusM,
 		&shiftOverview.Task.Id,
 		&shiftOverview.Task.LocationId,
 		&shiftOverview.Task.CompanyId,
@@ -227,49 +228,130 @@ func GetShiftOverview(
 		return nil, fmt.Errorf("GetShiftOverview: db select: %w", err)
 	}
 
+	shifts := []model.Shift{shiftOverview.Shift}
+	err = fillShiftSegments(ctx, db, shifts)
+	if err != nil {
+		return nil, err
+	}
+	shiftOverview.Shift = shifts[0]
+
+	breaks, err := getShiftBreaks(ctx, db, shiftOverview.Shift.Id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range breaks {
+		if breaks[i].EndTs == nil {
+			shiftOverview.Break = &breaks[i]
+		}
+	}
+
+	contract, err := getEmploymentContract(ctx, db, claims.SelectedEmployment(), shiftOverview.Shift.StartTs)
+	if err != nil {
+		return nil, err
+	}
+
+	_, paid := payroll.Durations(shiftOverview.Shift.StartTs, time.Now(), breaks, contract)
+	shiftOverview.PaidMinutes = int(paid / time.Minute)
+
 	return &shiftOverview, nil
 }
 
+// getEmploymentContract returns the terms of the employment's contract in
+// force at the given time, or nil when there is no employment or it has no
+// contract.
+func getEmploymentContract(
+	ctx context.Context,
+	db *sql.DB,
+	employment_id *int,
+	at time.Time,
+) (*model.Contract, error) {
+	if employment_id == nil {
+		return nil, nil
+	}
+
+	var contract model.Contract
+	err := db.QueryRowContext(
+		ctx,
+		`
+		SELECT cv.contract_id, cv.id, cv.effective_from, cv.hourly_rate, cv.unpaid_lunch_minutes
+		FROM employment e
+		JOIN LATERAL contract_version_at(e.contract_id, $2) cv ON true
+		WHERE e.id = $1
+		`,
+		*employment_id,
+		at,
+	).Scan(
+		&contract.Id,
+		&contract.VersionId,
+		&contract.EffectiveFrom,
+		&contract.HourlyRate,
+		&contract.UnpaidLunchMinutes,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getEmploymentContract: db select: %w", err)
+	}
+
+	return &contract, nil
+}
+
+// historyFilters is the WHERE clause of the shift history, with the profile
+// in $1, the location in $2 and the task in $3. Location and task also match
+// shifts that switched to them part way.
+const historyFilters = `
+	WHERE s.profile_id = $1
+		AND ($2::int IS NULL OR t.location_id = $2 OR EXISTS (
+			SELECT 1 FROM shift_segment g
+			JOIN task gt ON gt.id = g.task_id
+			WHERE g.shift_id = s.id AND gt.location_id = $2
+		))
+		AND ($3::int IS NULL OR s.task_id = $3 OR EXISTS (
+			SELECT 1 FROM shift_segment g
+			WHERE g.shift_id = s.id AND g.task_id = $3
+		))
+`
+
 func GetShiftHistory(
 	ctx context.Context,
 	db *sql.DB,
-	month *int,
-	year *int,
-	location_id *int,
-	task_id *int,
+	query HistoryQuery,
 ) (*ShiftHistoryResponse, error) {
 	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
 	profile_id := claims.ProfileID
 
-	var start, end *time.Time
-	if month != nil && year != nil {
-		s, e := GetMonthRange(*year, *month)
-		start = &s
-		end = &e
+	calendar, err := getEmploymentCalendar(ctx, db, claims.SelectedEmployment())
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := resolveHistoryRange(query, calendar)
+	if err != nil {
+		return nil, err
 	}
 
 	shifts := []model.Shift{}
 	rows, err := db.Query(
 		`
-		SELECT s.id, s.profile_id, s.task_id, s.start_ts, s.end_ts, s.s_latitude, s.s_longitude, s.e_latitude, s.e_longitude
+		SELECT s.id, s.profile_id, s.task_id, s.start_ts, s.end_ts, s.s_latitude, s.s_longitude, s.e_latitude, s.e_longitude, s.version
 		FROM shift s
 		JOIN task t ON t.id = s.task_id
-		WHERE s.profile_id = $1
-			AND ($2::timestamptz IS NULL OR s.start_ts >= $2)
-			AND ($3::timestamptz IS NULL OR s.start_ts < $3)
-			AND ($4::int IS NULL OR t.location_id = $4)
-			AND ($5::int IS NULL OR s.task_id = $5)
+		`+historyFilters+`
+			AND ($4::timestamptz IS NULL OR s.start_ts >= $4)
+			AND ($5::timestamptz IS NULL OR s.start_ts < $5)
 		ORDER BY s.start_ts DESC
 		`,
 		profile_id,
-		start,
-		end,
-		location_id,
-		task_id,
+		query.LocationId,
+		query.TaskId,
+		period.From,
+		period.To,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("GetShiftHistory: db select: %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var shift model.Shift
@@ -283,37 +365,106 @@ func GetShiftHistory(
 			&shift.SLongitude,
 			&shift.ELatitude,
 			&shift.ELongitude,
+			&shift.Version,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("GetShiftHistory: db scan: %w", err)
 		}
 
+		shift.StartTs = shift.StartTs.In(calendar.Location)
+		if shift.EndTs != nil {
+			end_ts := shift.EndTs.In(calendar.Location)
+			shift.EndTs = &end_ts
+		}
+
 		shifts = append(shifts, shift)
 	}
 
-	m := 0
-	y := 0
-	if month != nil { m = *month }
-	if year  != nil { y = *year }
+	err = fillShiftSegments(ctx, db, shifts)
+	if err != nil {
+		return nil, err
+	}
 
-	nextM := m - 1
-	nextY := y
-	if nextM == 0 {
-		nextM = 12
-		nextY = y - 1
+	metadata := HistoryMetadata{
+		Period: period.Period,
+		From: period.From,
+		To: period.To,
+		ShiftCount: len(shifts),
 	}
 
-	hasMore := len(shifts) > 0
+	// worked minutes are time on the clock less the recorded breaks, open
+	// shifts counting up to now
+	breaks, err := payroll.GetBreaks(ctx, db, shifts)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, shift := range shifts {
+		end := now
+		if shift.EndTs != nil {
+			end = *shift.EndTs
+		}
+		worked, _ := payroll.Durations(shift.StartTs, end, breaks[shift.Id], nil)
+		metadata.WorkedMinutes += int(worked.Minutes())
+	}
+
+	// there is more when the profile has shifts from before the range
+	if period.From != nil {
+		err = db.QueryRowContext(
+			ctx,
+			`
+			SELECT EXISTS (
+				SELECT 1
+				FROM shift s
+				JOIN task t ON t.id = s.task_id
+				`+historyFilters+`
+					AND s.start_ts < $4
+			)
+			`,
+			profile_id,
+			query.LocationId,
+			query.TaskId,
+			period.From,
+		).Scan(&metadata.HasMore)
+		if err != nil {
+			return nil, fmt.Errorf("GetShiftHistory: db select has more: %w", err)
+		}
+	}
+
+	if period.Period == PeriodMonth {
+		metadata.Month = int(period.From.Month())
+		metadata.Year = period.From.Year()
+	}
+	if metadata.HasMore {
+		prev := period.previous(calendar)
+		metadata.NextFrom = prev.From
+		metadata.NextTo = prev.To
+		if prev.Period == PeriodMonth {
+			metadata.NextMonth = int(prev.From.Month())
+			metadata.NextYear = prev.From.Year()
+		}
+	}
+
+	// earnings are for the range asked for, or this month when the range
+	// is open
+	var earnings *payroll.Report
+	if employment_id := claims.SelectedEmployment(); employment_id != nil {
+		local := now.In(calendar.Location)
+		from, to := calendar.MonthRange(local.Year(), local.Month())
+		if period.From != nil && period.To != nil {
+			from, to = *period.From, *period.To
+		}
+
+		earnings, err = payroll.ComputeEmployment(ctx, db, *employment_id, from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &ShiftHistoryResponse{
 		Shifts: shifts,
-		Metadata: HistoryMetadata{
-			Month: m,
-			Year: y,
-			HasMore: hasMore,
-			NextMonth: nextM,
-			NextYear: nextY,
-		},
+		Earnings: earnings,
+		Metadata: metadata,
 	}, nil
 }
 
@@ -327,14 +478,20 @@ func GetLocations(
 	locations := []model.Location{}
 	rows, err := db.Query(
 		`
-		SELECT l.id, l.name, l.address, l.workspace_id
+		SELECT l.id, l.name, l.address, l.workspace_id, l.latitude, l.longitude, l.radius_m
 		FROM location l
-		JOIN workspace w ON w.id = l.workspace_id
-		JOIN company c ON w.id = c.workspace_id
-		JOIN employment e ON c.id = e.company_id
-		WHERE e.profile_id = $1
+		WHERE l.workspace_id IN (
+			SELECT c.workspace_id
+			FROM employment e
+			JOIN company c ON c.id = e.company_id
+			WHERE e.profile_id = $1
+			AND (e.start_date IS NULL OR e.start_date <= now())
+			AND (e.end_date IS NULL OR e.end_date > now())
+		)
+		AND ($2::int IS NULL OR l.workspace_id = $2)
 		`,
 		profile_id,
+		claims.WorkspaceScope(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("GetLocations: db select: %w", err)
@@ -347,6 +504,9 @@ func GetLocations(
 			&location.Name,
 			&location.Address,
 			&location.WorkspaceId,
+			&location.Latitude,
+			&location.Longitude,
+			&location.RadiusM,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("GetLocations: db scan: %w", err)
@@ -371,13 +531,19 @@ func GetTasks(
 		`
 		SELECT t.id, t.name, t.description, t.is_completed, t.location_id, t.company_id
 		FROM task t
-		JOIN company c ON c.id = t.company_id
-		JOIN employment e ON c.id = e.company_id
-		WHERE e.profile_id = $1
-		AND ($2::int IS NULL OR location_id = $2)
+		WHERE t.company_id IN (
+			SELECT e.company_id
+			FROM employment e
+			WHERE e.profile_id = $1
+			AND (e.start_date IS NULL OR e.start_date <= now())
+			AND (e.end_date IS NULL OR e.end_date > now())
+		)
+		AND ($2::int IS NULL OR t.location_id = $2)
+		AND ($3::int IS NULL OR t.company_id = $3)
 		`,
 		profile_id,
 		location_id,
+		claims.CompanyScope(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("GetTasks: db select: %w", err)
@@ -411,11 +577,16 @@ func GetEmploymentsDetailed(
 	profile_id := claims.ProfileID
 
 	employments := []EmploymentDetailed{}
-	rows, err := db.Query(
+	// workspace-level employments have no company, and an employment may
+	// have no contract
+	rows, err := db.QueryContext(
+		ctx,
 		`
 		SELECT 
 			w.id,
 			w.name,
+			w.pay_period_start_day,
+			w.time_zone,
 			c.id,
 			c.name,
 			c.workspace_id,
@@ -426,13 +597,18 @@ func GetEmploymentsDetailed(
 			e.role,
 			e.start_date,
 			e.end_date,
-			ct.id,
-			ct.hourly_rate,
-			ct.unpaid_lunch_minutes
+			cv.contract_id,
+			cv.id,
+			cv.effective_from,
+			cv.hourly_rate,
+			cv.unpaid_lunch_minutes,
+			cv.daily_overtime_minutes,
+			cv.weekly_overtime_minutes,
+			cv.overtime_multiplier
 		FROM employment e
-		JOIN contract ct ON ct.id = e.contract_id
-		JOIN company c ON c.id = e.company_id
-		JOIN workspace w ON w.id = c.workspace_id
+		LEFT JOIN company c ON c.id = e.company_id
+		LEFT JOIN LATERAL contract_version_at(e.contract_id, now()) cv ON true
+		JOIN workspace w ON w.id = COALESCE(c.workspace_id, e.workspace_id)
 		WHERE e.profile_id = $1
 		`,
 		profile_id,
@@ -440,18 +616,33 @@ func GetEmploymentsDetailed(
 	if err != nil {
 		return nil, fmt.Errorf("GetEmploymentsDetailed: db select: %w", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var e model.Employment
-		var c model.Company
 		var w model.Workspace
-		var ct model.Contract
+		var (
+			company_id   *int
+			company_name *string
+			company_ws   *int
+		)
+		var (
+			contract_id    *int
+			version_id     *int
+			effective_from *time.Time
+			hourly_rate    *int
+			lunch_minutes  *int
+			ct             model.Contract
+			multiplier     *float64
+		)
 		err = rows.Scan(
 			&w.Id,
 			&w.Name,
-			&c.Id,
-			&c.Name,
-			&c.WorkspaceId,
+			&w.PayPeriodStartDay,
+			&w.TimeZone,
+			&company_id,
+			&company_name,
+			&company_ws,
 			&e.Id,
 			&e.ProfileId,
 			&e.CompanyId,
@@ -459,100 +650,45 @@ func GetEmploymentsDetailed(
 			&e.Role,
 			&e.StartDate,
 			&e.EndDate,
-			&ct.Id,
-			&ct.HourlyRate,
-			&ct.UnpaidLunchMinutes,
+			&contract_id,
+			&version_id,
+			&effective_from,
+			&hourly_rate,
+			&lunch_minutes,
+			&ct.DailyOvertimeMinutes,
+			&ct.WeeklyOvertimeMinutes,
+			&multiplier,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("GetEmploymentsDetailed: db scan: %w", err)
 		}
 
 		detailed := EmploymentDetailed{
-			w,
-			c,
-			e,
-			ct,
+			Workspace: w,
+			Employment: e,
+		}
+		if company_id != nil {
+			detailed.Company = &model.Company{
+				Id: *company_id,
+				Name: *company_name,
+				WorkspaceId: company_ws,
+			}
+		}
+		if contract_id != nil {
+			ct.Id = *contract_id
+			ct.VersionId = *version_id
+			ct.EffectiveFrom = *effective_from
+			ct.HourlyRate = *hourly_rate
+			ct.UnpaidLunchMinutes = *lunch_minutes
+			ct.OvertimeMultiplier = *multiplier
+			detailed.Contract = &ct
 		}
 
 		employments = append(employments, detailed)
 	}
-
-	return &employments, nil
-}
-
-func GetPin(
-	ctx context.Context,
-	db *sql.DB,
-) (*string, error) {
-	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
-	profile_id := claims.ProfileID
-
-	var pinHash string
-	err := db.QueryRowContext(
-		ctx,
-		`
-		SELECT pin
-		FROM profile_pin_auth
-		WHERE profile_id = $1
-		`,
-		profile_id,
-	).Scan(
-		&pinHash,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("GetPin: db select: %w", err)
-	}
-
-	return &pinHash, nil
-}
-
-func PostEditRequest(
-	ctx context.Context,
-	db *sql.DB,
-	input EditRequest_R,
-) (*model.EditRequest, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, fmt.Errorf("PostEditRequest: begin tx: %w", err)
-	}
-	defer tx.Rollback()
-
-	println("input shift_id was ", input.ShiftId)
-
-	var edit_request model.EditRequest
-	err = tx.QueryRowContext(
-		ctx,
-		`
-		INSERT INTO edit_request (shift_id, task_id, start_ts, end_ts, reason)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, shift_id, task_id, start_ts, end_ts, reason, status
-		`,
-		input.ShiftId,
-		input.TaskId,
-		input.StartTs,
-		input.EndTs,
-		input.Reason,
-	).Scan(
-		&edit_request.Id,
-		&edit_request.ShiftId,
-		&edit_request.TaskId,
-		&edit_request.StartTs,
-		&edit_request.EndTs,
-		&edit_request.Reason,
-		&edit_request.Status,
-	)
-	if err != nil {
-		return nil, translateDBError(err)
-	}
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("PostEditRequest: db commit: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetEmploymentsDetailed: rows: %w", err)
 	}
 
-	return &edit_request, nil
-}
-
-func GetMonthRange(year, month int) (time.Time, time.Time) {
-    start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-    end := start.AddDate(0, 1, 0) 
-    return start, end
+	return &employments, nil
 }
@@ -0,0 +1,158 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"test/internal/model"
+)
+
+const (
+	earthRadiusMeters = 6371000.0
+	// fixes less accurate than this can't place a worker at a location and
+	// count as missing
+	maxAccuracyMeters = 100.0
+)
+
+type geofence struct {
+	Latitude  *float64
+	Longitude *float64
+	RadiusM   *int
+	Policy    model.GeofencePolicy
+}
+
+func (g *geofence) configured() bool {
+	return g.Policy != model.GeofenceIgnore &&
+		g.Latitude != nil && g.Longitude != nil && g.RadiusM != nil
+}
+
+// violated reports why a position doesn't satisfy the geofence, or nil when
+// it does or there is nothing to enforce. The accuracy is given to the
+// worker, so a fix counts as inside when its error circle touches the fence.
+func (g *geofence) violated(latitude, longitude, accuracy *float64) error {
+	if !g.configured() {
+		return nil
+	}
+
+	if latitude == nil || longitude == nil {
+		return ErrPositionRequired
+	}
+
+	margin := 0.0
+	if accuracy != nil {
+		if *accuracy > maxAccuracyMeters {
+			return ErrPositionRequired
+		}
+		margin = math.Max(*accuracy, 0)
+	}
+
+	distance := haversine(*g.Latitude, *g.Longitude, *latitude, *longitude)
+	if distance-margin > float64(*g.RadiusM) {
+		return ErrOutsideGeofence
+	}
+
+	return nil
+}
+
+// enforce applies the workspace policy to a live clock-in or clock-out. It
+// returns whether the event should be flagged, or an error under reject.
+func (g *geofence) enforce(latitude, longitude, accuracy *float64) (bool, error) {
+	err := g.violated(latitude, longitude, accuracy)
+	if err == nil {
+		return false, nil
+	}
+	if g.Policy == model.GeofenceReject {
+		return false, err
+	}
+	return true, nil
+}
+
+// haversine returns the great-circle distance in meters between two points.
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// getTaskGeofence loads the geofence of the task's location. When company_id
+// is given the task has to belong to that company.
+func getTaskGeofence(
+	ctx context.Context,
+	tx *sql.Tx,
+	task_id int,
+	company_id *int,
+) (*geofence, error) {
+	var fence geofence
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT l.latitude, l.longitude, l.radius_m, w.geofence_policy
+		FROM task t
+		JOIN location l ON l.id = t.location_id
+		JOIN workspace w ON w.id = l.workspace_id
+		WHERE t.id = $1
+		AND ($2::int IS NULL OR t.company_id = $2)
+		`,
+		task_id,
+		company_id,
+	).Scan(
+		&fence.Latitude,
+		&fence.Longitude,
+		&fence.RadiusM,
+		&fence.Policy,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("getTaskGeofence: db select: %w", err)
+	}
+
+	return &fence, nil
+}
+
+// getOpenShiftGeofence loads the ongoing shift of the profile together with
+// the geofence of its task's location.
+func getOpenShiftGeofence(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+) (int, *geofence, error) {
+	var shift_id int
+	var fence geofence
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT s.id, l.latitude, l.longitude, l.radius_m, COALESCE(w.geofence_policy, 'ignore')
+		FROM shift s
+		LEFT JOIN task t ON t.id = s.task_id
+		LEFT JOIN location l ON l.id = t.location_id
+		LEFT JOIN workspace w ON w.id = l.workspace_id
+		WHERE s.profile_id = $1
+		AND s.end_ts IS NULL
+		FOR UPDATE OF s
+		`,
+		profile_id,
+	).Scan(
+		&shift_id,
+		&fence.Latitude,
+		&fence.Longitude,
+		&fence.RadiusM,
+		&fence.Policy,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, ErrNotClockedIn
+		}
+		return 0, nil, fmt.Errorf("getOpenShiftGeofence: db select: %w", err)
+	}
+
+	return shift_id, &fence, nil
+}
@@ -0,0 +1,86 @@
+package pin
+
+import (
+	"errors"
+	"math"
+	"test/internal/model"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 64.1417, -21.9266, 64.1417, -21.9266, 0},
+		{"one degree of latitude", 0, 0, 1, 0, earthRadiusMeters * math.Pi / 180},
+		{"equator to pole", 0, 0, 90, 0, earthRadiusMeters * math.Pi / 2},
+		{"antipodes", 0, 0, 0, 180, earthRadiusMeters * math.Pi},
+		{"across the date line", 0, 179.5, 0, -179.5, earthRadiusMeters * math.Pi / 180},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := haversine(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("haversine = %.2f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeofenceViolated(t *testing.T) {
+	// 0.0015 degrees of latitude is about 167 meters
+	fence := geofence{Latitude: ptr(64.0), Longitude: ptr(-22.0), RadiusM: ptr(100), Policy: model.GeofenceFlag}
+	near := ptr(64.0015)
+
+	tests := []struct {
+		name                          string
+		fence                         geofence
+		latitude, longitude, accuracy *float64
+		want                          error
+	}{
+		{"inside", fence, ptr(64.0), ptr(-22.0), nil, nil},
+		{"outside", fence, near, ptr(-22.0), nil, ErrOutsideGeofence},
+		{"inside within the accuracy", fence, near, ptr(-22.0), ptr(80.0), nil},
+		{"outside the accuracy", fence, near, ptr(-22.0), ptr(50.0), ErrOutsideGeofence},
+		{"negative accuracy", fence, near, ptr(-22.0), ptr(-80.0), ErrOutsideGeofence},
+		{"too inaccurate", fence, ptr(64.0), ptr(-22.0), ptr(150.0), ErrPositionRequired},
+		{"no position", fence, nil, nil, nil, ErrPositionRequired},
+		{"no longitude", fence, ptr(64.0), nil, nil, ErrPositionRequired},
+		{"ignored", geofence{Latitude: ptr(64.0), Longitude: ptr(-22.0), RadiusM: ptr(100), Policy: model.GeofenceIgnore}, nil, nil, nil, nil},
+		{"no radius", geofence{Latitude: ptr(64.0), Longitude: ptr(-22.0), Policy: model.GeofenceReject}, near, ptr(-22.0), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fence.violated(tt.latitude, tt.longitude, tt.accuracy); !errors.Is(got, tt.want) {
+				t.Errorf("violated = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeofenceEnforce(t *testing.T) {
+	tests := []struct {
+		policy  model.GeofencePolicy
+		flagged bool
+		wantErr error
+	}{
+		{model.GeofenceReject, false, ErrOutsideGeofence},
+		{model.GeofenceFlag, true, nil},
+		{model.GeofenceIgnore, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			fence := geofence{Latitude: ptr(64.0), Longitude: ptr(-22.0), RadiusM: ptr(100), Policy: tt.policy}
+			flagged, err := fence.enforce(ptr(64.01), ptr(-22.0), nil)
+			if flagged != tt.flagged || !errors.Is(err, tt.wantErr) {
+				t.Errorf("enforce = %v, %v, want %v, %v", flagged, err, tt.flagged, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"test/internal/payroll"
+	"time"
+)
+
+// historyRange is the part of the shift history that is read. From and To
+// are nil when it is open on that side.
+type historyRange struct {
+	Period HistoryPeriod
+	From   *time.Time
+	To     *time.Time
+}
+
+// resolveHistoryRange turns the query into a range of the calendar. Dates
+// are read as days in the calendar's time zone.
+func resolveHistoryRange(q HistoryQuery, calendar payroll.Calendar) (historyRange, error) {
+	custom := q.From != nil || q.To != nil
+	month := q.Month != nil || q.Year != nil
+
+	if custom && (month || q.Period != nil || q.Date != nil) {
+		return historyRange{}, ErrInvalidHistoryRange
+	}
+	if (q.Month == nil) != (q.Year == nil) {
+		return historyRange{}, ErrInvalidHistoryRange
+	}
+	if q.Month != nil && (*q.Month < 1 || *q.Month > 12) {
+		return historyRange{}, ErrInvalidHistoryRange
+	}
+
+	if custom {
+		r := historyRange{Period: PeriodCustom}
+		if q.From != nil {
+			from := calendar.Date(*q.From)
+			r.From = &from
+		}
+		if q.To != nil {
+			to := calendar.Date(*q.To)
+			r.To = &to
+		}
+		if r.From != nil && r.To != nil && !r.From.Before(*r.To) {
+			return historyRange{}, ErrInvalidHistoryRange
+		}
+		return r, nil
+	}
+
+	period := PeriodMonth
+	if q.Period != nil {
+		period = *q.Period
+	}
+	if month && period != PeriodMonth {
+		return historyRange{}, ErrInvalidHistoryRange
+	}
+	if !month && q.Period == nil {
+		if q.Date != nil {
+			return historyRange{}, ErrInvalidHistoryRange
+		}
+		return historyRange{}, nil
+	}
+
+	date := time.Now()
+	if q.Date != nil {
+		date = calendar.Date(*q.Date)
+	}
+	if month {
+		date, _ = calendar.MonthRange(*q.Year, time.Month(*q.Month))
+	}
+
+	return periodRange(period, date, calendar)
+}
+
+// periodRange returns the range of the period t falls in.
+func periodRange(period HistoryPeriod, t time.Time, calendar payroll.Calendar) (historyRange, error) {
+	var from, to time.Time
+	switch period {
+	case PeriodWeek:
+		from, to = calendar.WeekRange(t)
+	case PeriodMonth:
+		t = t.In(calendar.Location)
+		from, to = calendar.MonthRange(t.Year(), t.Month())
+	case PeriodPayPeriod:
+		from, to = calendar.PayPeriodRange(t)
+	default:
+		return historyRange{}, ErrInvalidPeriod
+	}
+	return historyRange{Period: period, From: &from, To: &to}, nil
+}
+
+// previous returns the range right before r. Presets step back one period,
+// custom ranges by their own length. A range with no start has nothing
+// before it.
+func (r historyRange) previous(calendar payroll.Calendar) *historyRange {
+	if r.From == nil {
+		return nil
+	}
+	if r.Period != PeriodCustom {
+		prev, _ := periodRange(r.Period, r.From.Add(-time.Nanosecond), calendar)
+		return &prev
+	}
+
+	to := *r.From
+	if r.To == nil {
+		return &historyRange{Period: PeriodCustom, To: &to}
+	}
+	from := to.Add(-r.To.Sub(*r.From))
+	return &historyRange{Period: PeriodCustom, From: &from, To: &to}
+}
+
+// getEmploymentCalendar returns the calendar of the workspace of the
+// employment, the default one when no employment is selected.
+func getEmploymentCalendar(
+	ctx context.Context,
+	db *sql.DB,
+	employment_id *int,
+) (payroll.Calendar, error) {
+	if employment_id == nil {
+		return payroll.DefaultCalendar, nil
+	}
+
+	calendar, err := payroll.GetCalendar(ctx, db, *employment_id)
+	if err != nil {
+		if errors.Is(err, payroll.ErrEmploymentNotFound) {
+			return payroll.DefaultCalendar, nil
+		}
+		return payroll.Calendar{}, err
+	}
+
+	return *calendar, nil
+}
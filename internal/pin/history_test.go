@@ -0,0 +1,85 @@
+package pin
+
+import (
+	"errors"
+	"test/internal/payroll"
+	"testing"
+	"time"
+)
+
+func TestResolveHistoryRange(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	calendar := payroll.Calendar{Location: auckland, PayPeriodStartDay: 1}
+
+	month := 3
+	year := 2025
+	week := PeriodWeek
+	date := time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    HistoryQuery
+		from, to time.Time
+	}{
+		{
+			// Auckland is 13 hours ahead of UTC in March
+			name:  "month",
+			query: HistoryQuery{Month: &month, Year: &year},
+			from:  time.Date(2025, 2, 28, 11, 0, 0, 0, time.UTC),
+			to:    time.Date(2025, 3, 31, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "week around a date",
+			query: HistoryQuery{Period: &week, Date: &date},
+			from:  time.Date(2025, 3, 3, 0, 0, 0, 0, auckland),
+			to:    time.Date(2025, 3, 10, 0, 0, 0, 0, auckland),
+		},
+		{
+			name:  "custom",
+			query: HistoryQuery{From: &date},
+			from:  time.Date(2025, 3, 5, 0, 0, 0, 0, auckland),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := resolveHistoryRange(tt.query, calendar)
+			if err != nil {
+				t.Fatalf("resolveHistoryRange: %v", err)
+			}
+			if r.From == nil || !r.From.Equal(tt.from) {
+				t.Errorf("From = %v, want %v", r.From, tt.from)
+			}
+			if tt.to.IsZero() != (r.To == nil) || (r.To != nil && !r.To.Equal(tt.to)) {
+				t.Errorf("To = %v, want %v", r.To, tt.to)
+			}
+		})
+	}
+}
+
+func TestResolveHistoryRangeInvalid(t *testing.T) {
+	month := 13
+	year := 2025
+	date := time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query HistoryQuery
+	}{
+		{"month out of range", HistoryQuery{Month: &month, Year: &year}},
+		{"month without year", HistoryQuery{Month: &month}},
+		{"custom and a month", HistoryQuery{From: &date, Year: &year, Month: &month}},
+		{"to before from", HistoryQuery{From: &date, To: &date}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveHistoryRange(tt.query, payroll.DefaultCalendar)
+			if !errors.Is(err, ErrInvalidHistoryRange) {
+				t.Errorf("got %v, want ErrInvalidHistoryRange", err)
+			}
+		})
+	}
+}
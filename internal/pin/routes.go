@@ -6,6 +6,10 @@ import (
 	"net/http"
 	"strconv"
 	"test/internal/abstractions"
+	"test/internal/model"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func ClockInHandler(db *sql.DB) http.HandlerFunc {
@@ -25,14 +29,51 @@ func ClockOutHandler(db *sql.DB) http.HandlerFunc {
 	)
 }
 
-func SyncShiftHandler(db *sql.DB) http.HandlerFunc {
+func SyncShiftsHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(
+		db,
+		SyncShifts,
+		WriteDomainError,
+	)
+}
+
+func SwitchTaskHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(
+		db,
+		SwitchTask,
+		WriteDomainError,
+	)
+}
+
+func StartBreakHandler(db *sql.DB) http.HandlerFunc {
 	return abstractions.CreateJSONHandler(
 		db,
-		SyncShift,
+		StartBreak,
 		WriteDomainError,
 	)
 }
 
+func EndBreakHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(
+		db,
+		EndBreak,
+		WriteDomainError,
+	)
+}
+
+func GetBreakTypesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := GetBreakTypes(r.Context(), db)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
 func ShiftOverviewHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		result, err := GetShiftOverview(r.Context(), db)
@@ -96,7 +137,91 @@ func ShiftHistoryHandler(db *sql.DB) http.HandlerFunc {
 			year = &parsed
 		}
 
-		result, err := GetShiftHistory(r.Context(), db, month, year, location_id, task_id)
+		query := HistoryQuery{
+			Month: month,
+			Year: year,
+			LocationId: location_id,
+			TaskId: task_id,
+		}
+
+		// to is the first day not included
+		s_from := r.URL.Query().Get("from")
+
+		if s_from != "" {
+			parsed, err := time.Parse(time.DateOnly, s_from)
+			if err != nil {
+				http.Error(w, "from must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			query.From = &parsed
+		}
+
+		s_to := r.URL.Query().Get("to")
+
+		if s_to != "" {
+			parsed, err := time.Parse(time.DateOnly, s_to)
+			if err != nil {
+				http.Error(w, "to must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			query.To = &parsed
+		}
+
+		s_date := r.URL.Query().Get("date")
+
+		if s_date != "" {
+			parsed, err := time.Parse(time.DateOnly, s_date)
+			if err != nil {
+				http.Error(w, "date must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			query.Date = &parsed
+		}
+
+		if s_period := r.URL.Query().Get("period"); s_period != "" {
+			period := HistoryPeriod(s_period)
+			query.Period = &period
+		}
+
+		result, err := GetShiftHistory(r.Context(), db, query)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+func ScheduleHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s_from := r.URL.Query().Get("from")
+		var from *time.Time
+
+		if s_from != "" {
+			parsed, err := time.Parse(time.DateOnly, s_from)
+			if err != nil {
+				http.Error(w, "from must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			from = &parsed
+		}
+
+		// to is the first day not included
+		s_to := r.URL.Query().Get("to")
+		var to *time.Time
+
+		if s_to != "" {
+			parsed, err := time.Parse(time.DateOnly, s_to)
+			if err != nil {
+				http.Error(w, "to must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			to = &parsed
+		}
+
+		result, err := GetSchedule(r.Context(), db, from, to)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -158,9 +283,23 @@ func GetEmploymentsDetailedHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func GetPinHandler(db *sql.DB) http.HandlerFunc {
+func PostEditRequestHandler(db *sql.DB) http.HandlerFunc {
+	return abstractions.CreateJSONHandler(
+		db,
+		PostEditRequest,
+		WriteDomainError,
+	)
+}
+
+func GetEditRequestsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		result, err := GetPin(r.Context(), db)
+		var status *model.RequestStatus
+		if s_status := r.URL.Query().Get("status"); s_status != "" {
+			parsed := model.RequestStatus(s_status)
+			status = &parsed
+		}
+
+		result, err := GetEditRequests(r.Context(), db, status)
 		if err != nil {
 			WriteDomainError(w, err)
 			return
@@ -171,10 +310,22 @@ func GetPinHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func PostEditRequestHandler(db *sql.DB) http.HandlerFunc {
-	return abstractions.CreateJSONHandler(
-		db,
-		PostEditRequest,
-		WriteDomainError, //also validate that sender is owner of shift
-	)
+func CancelEditRequestHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		result, err := CancelEditRequest(r.Context(), db, id)
+		if err != nil {
+			WriteDomainError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
 }
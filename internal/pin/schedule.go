@@ -0,0 +1,63 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"test/internal/auth"
+	"test/internal/roster"
+	"time"
+)
+
+const (
+	// scheduleDays is how far ahead the schedule looks by default
+	scheduleDays = 14
+	// maxScheduleDays is the longest range a schedule can cover
+	maxScheduleDays = 62
+)
+
+// GetSchedule returns the planned shifts of the caller between the dates
+// from and to, to not included, in the time zone of the selected
+// employment's workspace. Without dates it is the two weeks from now on.
+func GetSchedule(
+	ctx context.Context,
+	db *sql.DB,
+	from *time.Time,
+	to *time.Time,
+) (*ScheduleResponse, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+
+	calendar, err := getEmploymentCalendar(ctx, db, claims.SelectedEmployment())
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now().In(calendar.Location)
+	if from != nil {
+		start = calendar.Date(*from)
+	}
+	end := start.AddDate(0, 0, scheduleDays)
+	if to != nil {
+		end = calendar.Date(*to)
+	}
+	if !end.After(start) || end.After(start.AddDate(0, 0, maxScheduleDays)) {
+		return nil, ErrInvalidScheduleRange
+	}
+
+	occurrences, err := roster.GetOccurrences(
+		ctx,
+		db,
+		start,
+		end,
+		`p.profile_id = $3`,
+		claims.ProfileID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScheduleResponse{
+		From: start,
+		To: end,
+		Occurrences: occurrences,
+	}, nil
+}
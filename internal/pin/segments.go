@@ -0,0 +1,216 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SwitchTask moves the ongoing shift over to another task. The shift stays
+// open, the current task segment ends and a new one starts at input.Ts.
+func SwitchTask(
+	ctx context.Context,
+	db *sql.DB,
+	input SwitchTask_R,
+) (*model.Shift, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	if claims.CompanyScope() == nil {
+		return nil, ErrNoEmploymentSelected
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("SwitchTask: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if input.Ts == nil {
+		now := time.Now()
+		input.Ts = &now
+	}
+
+	var shift model.Shift
+	var segment_start time.Time
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		SELECT s.id, s.profile_id, s.task_id, s.start_ts,
+			COALESCE((SELECT max(g.start_ts) FROM shift_segment g WHERE g.shift_id = s.id), s.start_ts)
+		FROM shift s
+		WHERE s.profile_id = $1
+		AND s.end_ts IS NULL
+		FOR UPDATE
+		`,
+		profile_id,
+	).Scan(
+		&shift.Id,
+		&shift.ProfileId,
+		&shift.TaskId,
+		&shift.StartTs,
+		&segment_start,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotClockedIn
+		}
+		return nil, fmt.Errorf("SwitchTask: select shift: %w", err)
+	}
+
+	if shift.TaskId == input.TaskId {
+		return nil, ErrSameTask
+	}
+	if !input.Ts.After(segment_start) {
+		return nil, ErrNegativeDuration
+	}
+
+	// the first switch splits the shift, so the part worked so far becomes
+	// the first segment
+	_, err = tx.ExecContext(
+		ctx,
+		`
+		INSERT INTO shift_segment (shift_id, task_id, start_ts)
+		SELECT s.id, s.task_id, s.start_ts
+		FROM shift s
+		WHERE s.id = $1
+		AND NOT EXISTS (SELECT 1 FROM shift_segment g WHERE g.shift_id = s.id)
+		`,
+		shift.Id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SwitchTask: insert first segment: %w", err)
+	}
+
+	var inserted int
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		INSERT INTO shift_segment (shift_id, task_id, start_ts)
+		SELECT $1, t.id, $3
+		FROM task t
+		WHERE t.id = $2 AND t.company_id = $4
+		RETURNING id
+		`,
+		shift.Id,
+		input.TaskId,
+		input.Ts,
+		claims.CompanyID,
+	).Scan(
+		&inserted,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("SwitchTask: insert segment: %w", err)
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		`
+		UPDATE shift
+		SET task_id = $1
+		WHERE id = $2
+		RETURNING task_id, version
+		`,
+		input.TaskId,
+		shift.Id,
+	).Scan(
+		&shift.TaskId,
+		&shift.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SwitchTask: update shift: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("SwitchTask: db commit: %w", err)
+	}
+
+	shifts := []model.Shift{shift}
+	err = fillShiftSegments(ctx, db, shifts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shifts[0], nil
+}
+
+// fillShiftSegments sets Segments on every shift. A segment runs until the
+// next one starts, the last one until the shift ends, and a shift that never
+// switched task is a single segment.
+func fillShiftSegments(
+	ctx context.Context,
+	db *sql.DB,
+	shifts []model.Shift,
+) error {
+	if len(shifts) == 0 {
+		return nil
+	}
+
+	shift_ids := make([]int, len(shifts))
+	for i := range shifts {
+		shift_ids[i] = shifts[i].Id
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT shift_id, task_id, start_ts
+		FROM shift_segment
+		WHERE shift_id = ANY($1)
+		ORDER BY shift_id, start_ts
+		`,
+		pq.Array(shift_ids),
+	)
+	if err != nil {
+		return fmt.Errorf("fillShiftSegments: db select: %w", err)
+	}
+	defer rows.Close()
+
+	switches := map[int][]model.ShiftSegment{}
+	for rows.Next() {
+		var shift_id int
+		var segment model.ShiftSegment
+		err = rows.Scan(
+			&shift_id,
+			&segment.TaskId,
+			&segment.StartTs,
+		)
+		if err != nil {
+			return fmt.Errorf("fillShiftSegments: db scan: %w", err)
+		}
+		switches[shift_id] = append(switches[shift_id], segment)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("fillShiftSegments: rows: %w", err)
+	}
+
+	for i := range shifts {
+		shift := &shifts[i]
+		segments := switches[shift.Id]
+		if len(segments) == 0 {
+			segments = []model.ShiftSegment{{TaskId: shift.TaskId}}
+		}
+
+		segments[0].StartTs = shift.StartTs
+		for j := range segments {
+			if j+1 < len(segments) {
+				end := segments[j+1].StartTs
+				segments[j].EndTs = &end
+			} else {
+				segments[j].EndTs = shift.EndTs
+			}
+		}
+		shift.Segments = segments
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"test/internal/auth"
+	"test/internal/model"
+	"time"
+)
+
+const maxSyncBatch = 500
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SyncShifts applies a batch of shifts recorded offline. Every item is synced
+// in its own transaction and gets its own result, so one bad item doesn't
+// hold back the rest.
+func SyncShifts(
+	ctx context.Context,
+	db *sql.DB,
+	input SyncShifts_R,
+) (*SyncShiftsResponse, error) {
+	if len(input.Shifts) > maxSyncBatch {
+		return nil, ErrSyncBatchTooLarge
+	}
+
+	response := SyncShiftsResponse{Results: []SyncShiftResult{}}
+	for _, item := range input.Shifts {
+		result, err := syncShift(ctx, db, item)
+		if err != nil {
+			if !isSyncRejection(err) {
+				log.Printf("internal error: %+v", err)
+				err = errors.New("internal server error")
+			}
+			result = &SyncShiftResult{Status: SyncRejected, Error: err.Error()}
+		}
+		result.ClientId = item.ClientId
+		result.LocalId = item.LocalId
+		response.Results = append(response.Results, *result)
+	}
+
+	return &response, nil
+}
+
+func isSyncRejection(err error) bool {
+	return errors.Is(err, ErrInvalidClientId) ||
+		errors.Is(err, ErrShiftNotFound) ||
+		errors.Is(err, ErrTaskNotFound) ||
+		errors.Is(err, ErrNegativeDuration) ||
+		errors.Is(err, ErrShiftAlreadyExists)
+}
+
+func syncShift(
+	ctx context.Context,
+	db *sql.DB,
+	item SyncShiftItem,
+) (*SyncShiftResult, error) {
+	claims := ctx.Value(auth.ClaimsKey).(*auth.Claims)
+	profile_id := claims.ProfileID
+
+	if !uuidPattern.MatchString(item.ClientId) {
+		return nil, ErrInvalidClientId
+	}
+	if item.EndTs != nil && item.EndTs.Before(item.StartTs) {
+		return nil, ErrNegativeDuration
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("syncShift: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := getSyncedShift(ctx, tx, profile_id, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		if sameShift(item, existing) {
+			return &SyncShiftResult{
+				Status: SyncUnchanged,
+				Id: &existing.Id,
+				Version: &existing.Version,
+			}, nil
+		}
+		if item.BaseVersion == nil || *item.BaseVersion != existing.Version {
+			return &SyncShiftResult{
+				Status: SyncConflict,
+				Id: &existing.Id,
+				Version: &existing.Version,
+				Shift: existing,
+			}, nil
+		}
+	}
+
+	fence, err := getTaskGeofence(ctx, tx, item.TaskId, claims.CompanyScope())
+	if err != nil {
+		return nil, err
+	}
+
+	// synced shifts already happened offline, so they are only flagged and
+	// never rejected
+	s_flagged := fence.violated(item.SLatitude, item.SLongitude, item.SAccuracy) != nil
+	e_flagged := item.EndTs != nil &&
+		fence.violated(item.ELatitude, item.ELongitude, item.EAccuracy) != nil
+
+	var shift model.Shift
+	status := SyncCreated
+	if existing == nil {
+		err = tx.QueryRowContext(
+			ctx,
+			`
+			INSERT INTO shift (
+				client_id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude,
+				s_accuracy, e_accuracy, s_flagged, e_flagged
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id, version
+			`,
+			item.ClientId,
+			profile_id,
+			item.TaskId,
+			item.StartTs,
+			item.EndTs,
+			item.SLatitude,
+			item.SLongitude,
+			item.ELatitude,
+			item.ELongitude,
+			item.SAccuracy,
+			item.EAccuracy,
+			s_flagged,
+			e_flagged,
+		).Scan(
+			&shift.Id,
+			&shift.Version,
+		)
+	} else {
+		status = SyncUpdated
+		err = tx.QueryRowContext(
+			ctx,
+			`
+			UPDATE shift SET
+				client_id = COALESCE(client_id, $1),
+				task_id = $2,
+				start_ts = $3,
+				end_ts = $4,
+				s_latitude = $5,
+				s_longitude = $6,
+				e_latitude = $7,
+				e_longitude = $8,
+				s_accuracy = $9,
+				e_accuracy = $10,
+				s_flagged = $11,
+				e_flagged = $12
+			WHERE id = $13
+			RETURNING id, version
+			`,
+			item.ClientId,
+			item.TaskId,
+			item.StartTs,
+			item.EndTs,
+			item.SLatitude,
+			item.SLongitude,
+			item.ELatitude,
+			item.ELongitude,
+			item.SAccuracy,
+			item.EAccuracy,
+			s_flagged,
+			e_flagged,
+			existing.Id,
+		).Scan(
+			&shift.Id,
+			&shift.Version,
+		)
+	}
+	if err != nil {
+		return nil, translateDBError(err)
+	}
+
+	// a synced task replaces whatever the shift switched between on the
+	// server
+	if existing != nil && existing.TaskId != item.TaskId {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+			DELETE FROM shift_segment WHERE shift_id = $1
+			`,
+			existing.Id,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("syncShift: delete segments: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("syncShift: db commit: %w", err)
+	}
+
+	return &SyncShiftResult{
+		Status: status,
+		Id: &shift.Id,
+		Version: &shift.Version,
+	}, nil
+}
+
+// getSyncedShift finds the server copy of a synced shift, by client id first
+// and by server id for shifts that were started online. Shifts of other
+// profiles are never returned.
+func getSyncedShift(
+	ctx context.Context,
+	tx *sql.Tx,
+	profile_id int,
+	item SyncShiftItem,
+) (*model.Shift, error) {
+	var shift model.Shift
+	var owner int
+	err := tx.QueryRowContext(
+		ctx,
+		`
+		SELECT id, profile_id, task_id, start_ts, end_ts, s_latitude, s_longitude, e_latitude, e_longitude,
+			s_accuracy, e_accuracy, s_flagged, e_flagged, auto_closed, version
+		FROM shift
+		WHERE (profile_id = $1 AND client_id = $2)
+		OR id = $3
+		ORDER BY (client_id = $2) DESC NULLS LAST
+		LIMIT 1
+		FOR UPDATE
+		`,
+		profile_id,
+		item.ClientId,
+		item.Id,
+	).Scan(
+		&shift.Id,
+		&owner,
+		&shift.TaskId,
+		&shift.StartTs,
+		&shift.EndTs,
+		&shift.SLatitude,
+		&shift.SLongitude,
+		&shift.ELatitude,
+		&shift.ELongitude,
+		&shift.SAccuracy,
+		&shift.EAccuracy,
+		&shift.SFlagged,
+		&shift.EFlagged,
+		&shift.AutoClosed,
+		&shift.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			if item.Id != nil {
+				return nil, ErrShiftNotFound
+			}
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getSyncedShift: db select: %w", err)
+	}
+
+	// someone else's shift looks the same as a missing one
+	if owner != profile_id {
+		return nil, ErrShiftNotFound
+	}
+	shift.ProfileId = owner
+
+	return &shift, nil
+}
+
+func sameShift(item SyncShiftItem, shift *model.Shift) bool {
+	return item.TaskId == shift.TaskId &&
+		item.StartTs.Equal(shift.StartTs) &&
+		sameTime(item.EndTs, shift.EndTs) &&
+		sameFloat(item.SLatitude, shift.SLatitude) &&
+		sameFloat(item.SLongitude, shift.SLongitude) &&
+		sameFloat(item.ELatitude, shift.ELatitude) &&
+		sameFloat(item.ELongitude, shift.ELongitude)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,217 @@
+package pin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/internal/auth"
+	"test/internal/db/dbtest"
+	"test/internal/model"
+	"testing"
+	"time"
+)
+
+// worker is a profile clocking in on a task of its company.
+type worker struct {
+	ctx     context.Context
+	task_id int
+}
+
+// newWorker sets up a workspace with a company, a task and a worker employed
+// there, and returns the worker's session.
+func newWorker(t *testing.T, db *sql.DB, n int) worker {
+	t.Helper()
+	profile_id := dbtest.QueryInt(t, db, `
+		INSERT INTO profile (kt, first_name, last_name)
+		VALUES ($1, 'Worker', $2)
+		RETURNING id`, fmt.Sprintf("02023029%02d", n), fmt.Sprint(n))
+	workspace_id := dbtest.QueryInt(t, db, `
+		INSERT INTO workspace (name) VALUES ($1) RETURNING id`, fmt.Sprint("Workspace ", n))
+	company_id := dbtest.QueryInt(t, db, `
+		INSERT INTO company (name, workspace_id) VALUES ($1, $2) RETURNING id`, fmt.Sprint("Company ", n), workspace_id)
+	location_id := dbtest.QueryInt(t, db, `
+		INSERT INTO location (name, address, workspace_id) VALUES ('Site', 'Street 1', $1) RETURNING id`, workspace_id)
+	task_id := dbtest.QueryInt(t, db, `
+		INSERT INTO task (name, location_id, company_id) VALUES ('Task', $1, $2) RETURNING id`, location_id, company_id)
+	employment_id := dbtest.QueryInt(t, db, `
+		INSERT INTO employment (profile_id, company_id, role) VALUES ($1, $2, 'worker') RETURNING id`, profile_id, company_id)
+
+	claims := &auth.Claims{
+		ProfileID: profile_id,
+		EmploymentScope: auth.EmploymentScope{
+			EmploymentID: employment_id,
+			CompanyID:    company_id,
+			WorkspaceID:  workspace_id,
+		},
+	}
+	return worker{
+		ctx:     context.WithValue(context.Background(), auth.ClaimsKey, claims),
+		task_id: task_id,
+	}
+}
+
+func syncOne(t *testing.T, db *sql.DB, w worker, item SyncShiftItem) SyncShiftResult {
+	t.Helper()
+	response, err := SyncShifts(w.ctx, db, SyncShifts_R{Shifts: []SyncShiftItem{item}})
+	if err != nil {
+		t.Fatalf("SyncShifts: %v", err)
+	}
+	return response.Results[0]
+}
+
+func TestSameShift(t *testing.T) {
+	start := time.Date(2025, 3, 3, 8, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	shift := &model.Shift{TaskId: 1, StartTs: start, EndTs: &end, SLatitude: ptr(64.0)}
+
+	tests := []struct {
+		name string
+		item SyncShiftItem
+		want bool
+	}{
+		{"same", SyncShiftItem{TaskId: 1, StartTs: start, EndTs: ptr(end), SLatitude: ptr(64.0)}, true},
+		{"same instant in another zone", SyncShiftItem{TaskId: 1, StartTs: start.In(time.FixedZone("", 3600)), EndTs: ptr(end), SLatitude: ptr(64.0)}, true},
+		{"other task", SyncShiftItem{TaskId: 2, StartTs: start, EndTs: ptr(end), SLatitude: ptr(64.0)}, false},
+		{"still open", SyncShiftItem{TaskId: 1, StartTs: start, SLatitude: ptr(64.0)}, false},
+		{"later end", SyncShiftItem{TaskId: 1, StartTs: start, EndTs: ptr(end.Add(time.Minute)), SLatitude: ptr(64.0)}, false},
+		{"no position", SyncShiftItem{TaskId: 1, StartTs: start, EndTs: ptr(end)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameShift(tt.item, shift); got != tt.want {
+				t.Errorf("sameShift = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncShiftsRejectsItems(t *testing.T) {
+	ctx := context.WithValue(context.Background(), auth.ClaimsKey, &auth.Claims{ProfileID: 1})
+	start := time.Now().Add(-time.Hour)
+
+	_, err := SyncShifts(ctx, nil, SyncShifts_R{Shifts: make([]SyncShiftItem, maxSyncBatch+1)})
+	if !errors.Is(err, ErrSyncBatchTooLarge) {
+		t.Errorf("oversized batch: got %v, want ErrSyncBatchTooLarge", err)
+	}
+
+	// both are turned down before the database is needed
+	response, err := SyncShifts(ctx, nil, SyncShifts_R{Shifts: []SyncShiftItem{
+		{ClientId: "not-a-uuid", LocalId: 1, StartTs: start},
+		{ClientId: "5f1c4a52-7d3e-4c7b-9a61-0f6c1d2e3b4a", LocalId: 2, StartTs: start, EndTs: ptr(start.Add(-time.Minute))},
+	}})
+	if err != nil {
+		t.Fatalf("SyncShifts: %v", err)
+	}
+	want := []error{ErrInvalidClientId, ErrNegativeDuration}
+	for i, result := range response.Results {
+		if result.Status != SyncRejected || result.Error != want[i].Error() || result.LocalId != i+1 {
+			t.Errorf("item %d: got %+v, want rejected with %q", i+1, result, want[i])
+		}
+	}
+}
+
+func TestSyncShiftIdempotent(t *testing.T) {
+	db := dbtest.Open(t)
+	w := newWorker(t, db, 1)
+
+	start := time.Now().Add(-8 * time.Hour).Truncate(time.Second)
+	item := SyncShiftItem{
+		ClientId: "5f1c4a52-7d3e-4c7b-9a61-0f6c1d2e3b4a",
+		TaskId:   w.task_id,
+		StartTs:  start,
+		EndTs:    ptr(start.Add(4 * time.Hour)),
+	}
+
+	created := syncOne(t, db, w, item)
+	if created.Status != SyncCreated {
+		t.Fatalf("first sync: got %+v, want created", created)
+	}
+
+	// a retry of the same upload changes nothing
+	again := syncOne(t, db, w, item)
+	if again.Status != SyncUnchanged || *again.Id != *created.Id || *again.Version != *created.Version {
+		t.Errorf("second sync: got %+v, want unchanged shift %d", again, *created.Id)
+	}
+}
+
+func TestSyncShiftConflict(t *testing.T) {
+	db := dbtest.Open(t)
+	w := newWorker(t, db, 1)
+
+	start := time.Now().Add(-8 * time.Hour).Truncate(time.Second)
+	item := SyncShiftItem{
+		ClientId: "5f1c4a52-7d3e-4c7b-9a61-0f6c1d2e3b4a",
+		TaskId:   w.task_id,
+		StartTs:  start,
+	}
+	created := syncOne(t, db, w, item)
+	if created.Status != SyncCreated {
+		t.Fatalf("first sync: got %+v, want created", created)
+	}
+
+	// the device clocks out on the copy it has
+	item.EndTs = ptr(start.Add(4 * time.Hour))
+	item.BaseVersion = created.Version
+	updated := syncOne(t, db, w, item)
+	if updated.Status != SyncUpdated || *updated.Version == *created.Version {
+		t.Fatalf("update: got %+v, want updated to a new version", updated)
+	}
+
+	// and then edits the stale copy again
+	item.EndTs = ptr(start.Add(5 * time.Hour))
+	conflict := syncOne(t, db, w, item)
+	if conflict.Status != SyncConflict || conflict.Shift == nil || *conflict.Version != *updated.Version {
+		t.Fatalf("stale update: got %+v, want a conflict at version %d", conflict, *updated.Version)
+	}
+	if !conflict.Shift.EndTs.Equal(start.Add(4 * time.Hour)) {
+		t.Errorf("conflict returned end %v, want the server's %v", conflict.Shift.EndTs, start.Add(4*time.Hour))
+	}
+}
+
+func TestSyncShiftOwnership(t *testing.T) {
+	db := dbtest.Open(t)
+	ours := newWorker(t, db, 1)
+	theirs := newWorker(t, db, 2)
+
+	start := time.Now().Add(-8 * time.Hour).Truncate(time.Second)
+	item := SyncShiftItem{
+		ClientId: "5f1c4a52-7d3e-4c7b-9a61-0f6c1d2e3b4a",
+		TaskId:   theirs.task_id,
+		StartTs:  start,
+		EndTs:    ptr(start.Add(4 * time.Hour)),
+	}
+	their_shift := syncOne(t, db, theirs, item)
+	if their_shift.Status != SyncCreated {
+		t.Fatalf("their sync: got %+v, want created", their_shift)
+	}
+
+	// their shift by server id is as good as missing
+	steal := SyncShiftItem{
+		ClientId:    "0b7e2f4c-3a9d-4e61-8c2f-5d1a6b7c8e9f",
+		Id:          their_shift.Id,
+		BaseVersion: their_shift.Version,
+		TaskId:      ours.task_id,
+		StartTs:     start,
+	}
+	result := syncOne(t, db, ours, steal)
+	if result.Status != SyncRejected || result.Error != ErrShiftNotFound.Error() {
+		t.Errorf("sync onto their shift: got %+v, want rejected as not found", result)
+	}
+
+	// client ids are only unique per profile, so the same one makes a shift
+	// of our own
+	item.TaskId = ours.task_id
+	result = syncOne(t, db, ours, item)
+	if result.Status != SyncCreated || *result.Id == *their_shift.Id {
+		t.Errorf("sync with their client id: got %+v, want a new shift", result)
+	}
+
+	// and a task of their company is no task at all
+	item.ClientId = "9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	item.TaskId = theirs.task_id
+	result = syncOne(t, db, ours, item)
+	if result.Status != SyncRejected || result.Error != ErrTaskNotFound.Error() {
+		t.Errorf("sync on their task: got %+v, want rejected as task not found", result)
+	}
+}
@@ -2,29 +2,86 @@ package pin
 
 import (
 	"test/internal/model"
+	"test/internal/payroll"
+	"test/internal/roster"
 	"time"
 )
 
-type ClockIn_R struct { // have to add lat long
+type ClockIn_R struct {
 	TaskId        int       `json:"task_id"`
 	StartTs      *time.Time `json:"start_ts"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	Accuracy     *float64   `json:"accuracy"`
 }
 
-type ClockOut_R struct { // have to add lat long
+type ClockOut_R struct {
 	EndTs        *time.Time `json:"end_ts"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	Accuracy     *float64   `json:"accuracy"`
 }
 
-type SyncShift_R struct {
-	RemoteId   *int      `json:"remote_id"`
-	LocalId     int       `json:"local_id"`
-	ProfileId   int       `json:"profile_id"`
-	TaskId      int       `json:"task_id"`
-	StartTs     time.Time `json:"start_ts"`
-	EndTs      *time.Time `json:"end_ts"`
-	SLatitude  *float64   `json:"s_latitude"`
-	SLongitude *float64   `json:"s_longitude"`
-	ELatitude  *float64   `json:"e_latitude"`
-	ELongitude *float64   `json:"e_longitude"`
+type SyncShifts_R struct {
+	Shifts []SyncShiftItem `json:"shifts"`
+}
+
+// SyncShiftItem is a shift recorded offline. ClientId is generated on the
+// device and makes retries idempotent. Id and BaseVersion are the server id
+// and version the device last saw, when it has synced the shift before.
+type SyncShiftItem struct {
+	ClientId     string    `json:"client_id"`
+	LocalId      int       `json:"local_id"`
+	Id          *int       `json:"id"`
+	BaseVersion *int       `json:"base_version"`
+	TaskId       int       `json:"task_id"`
+	StartTs      time.Time `json:"start_ts"`
+	EndTs       *time.Time `json:"end_ts"`
+	SLatitude   *float64   `json:"s_latitude"`
+	SLongitude  *float64   `json:"s_longitude"`
+	ELatitude   *float64   `json:"e_latitude"`
+	ELongitude  *float64   `json:"e_longitude"`
+	SAccuracy   *float64   `json:"s_accuracy"`
+	EAccuracy   *float64   `json:"e_accuracy"`
+}
+
+type SyncStatus string
+const (
+	SyncCreated   SyncStatus = "created"
+	SyncUpdated   SyncStatus = "updated"
+	SyncUnchanged SyncStatus = "unchanged"
+	SyncConflict  SyncStatus = "conflict"
+	SyncRejected  SyncStatus = "rejected"
+)
+
+// SyncShiftResult maps a device's shift to the server's. On a conflict Shift
+// holds the server's copy, on rejected Error says why.
+type SyncShiftResult struct {
+	ClientId  string       `json:"client_id"`
+	LocalId   int          `json:"local_id"`
+	Status    SyncStatus   `json:"status"`
+	Id       *int          `json:"id"`
+	Version  *int          `json:"version"`
+	Shift    *model.Shift  `json:"shift,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+type SyncShiftsResponse struct {
+	Results []SyncShiftResult `json:"results"`
+}
+
+type SwitchTask_R struct {
+	TaskId  int       `json:"task_id"`
+	Ts     *time.Time `json:"ts"`
+}
+
+type BreakStart_R struct {
+	BreakTypeId  int       `json:"break_type_id"`
+	StartTs     *time.Time `json:"start_ts"`
+}
+
+type BreakEnd_R struct {
+	EndTs *time.Time `json:"end_ts"`
 }
 
 type EditRequest_R struct {
@@ -36,27 +93,75 @@ type EditRequest_R struct {
 }
 
 type ShiftOverview struct {
-	Shift    model.Shift    `json:"shift"`
-	Location model.Location `json:"location"`
-	Task     model.Task     `json:"task"`
+	Shift       model.Shift       `json:"shift"`
+	Location    model.Location    `json:"location"`
+	Task        model.Task        `json:"task"`
+	Break      *model.ShiftBreak  `json:"break"`
+	PaidMinutes int               `json:"paid_minutes"`
 }
 
 type ShiftHistoryResponse struct {
 	Shifts   []model.Shift     `json:"shifts"`
 	Metadata   HistoryMetadata `json:"metadata"`
+	Earnings  *payroll.Report  `json:"earnings"`
+}
+
+// HistoryPeriod is a preset range of the shift history.
+type HistoryPeriod string
+
+const (
+	PeriodWeek      HistoryPeriod = "week"
+	PeriodMonth     HistoryPeriod = "month"
+	PeriodPayPeriod HistoryPeriod = "pay_period"
+	PeriodCustom    HistoryPeriod = "custom"
+)
+
+// HistoryQuery picks the part of the shift history to read: a from/to
+// range, a month and year, or a period around Date (today when nil). With
+// none of them the whole history is read. To is exclusive. Dates are days in
+// the time zone of the selected employment's workspace.
+type HistoryQuery struct {
+	From       *time.Time
+	To         *time.Time
+	Month      *int
+	Year       *int
+	Period     *HistoryPeriod
+	Date       *time.Time
+	LocationId *int
+	TaskId     *int
 }
 
+// HistoryMetadata describes the range that was read and how to get the one
+// before it. From and To are nil when the range is open on that side, and
+// the Next fields are only set when HasMore. Month and Year are only set for
+// months.
 type HistoryMetadata struct {
+	Period    HistoryPeriod `json:"period,omitempty"`
+	From      *time.Time `json:"from"`
+	To        *time.Time `json:"to"`
 	Month     int  `json:"month"`
 	Year      int  `json:"year"`
 	HasMore   bool `json:"has_more"`
+	NextFrom  *time.Time `json:"next_from,omitempty"`
+	NextTo    *time.Time `json:"next_to,omitempty"`
 	NextMonth int  `json:"next_month"`
 	NextYear  int  `json:"next_year"`
+	ShiftCount    int `json:"shift_count"`
+	WorkedMinutes int `json:"worked_minutes"`
+}
+
+// ScheduleResponse is the schedule between From and To, To not included.
+type ScheduleResponse struct {
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	Occurrences []roster.Occurrence `json:"occurrences"`
 }
 
+// EmploymentDetailed is an employment along with its workspace, and its
+// company and current contract terms when it has them.
 type EmploymentDetailed struct {
 	Workspace  model.Workspace
-	Company    model.Company
+	Company    *model.Company
 	Employment model.Employment
-	Contract   model.Contract
+	Contract   *model.Contract
 }
@@ -0,0 +1,321 @@
+package roster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"test/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// matchWindow is how long before its planned start a shift can be clocked
+// in for an occurrence.
+const matchWindow = 2 * time.Hour
+
+// Querier runs queries on either a *sql.DB or a *sql.Tx, so occurrences
+// can be read inside the transaction that links a shift to one.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Occurrence is one time a planned shift is to be worked, in the time zone
+// of its workspace, together with the actual shift clocked in for it. The
+// deltas are how many minutes the actual shift started and ended after the
+// plan, negative when early, and nil until known.
+type Occurrence struct {
+	PlannedShiftId    int          `json:"planned_shift_id"`
+	ProfileId         int          `json:"profile_id"`
+	TaskId            int          `json:"task_id"`
+	LocationId        int          `json:"location_id"`
+	StartTs           time.Time    `json:"start_ts"`
+	EndTs             time.Time    `json:"end_ts"`
+	Note              *string      `json:"note"`
+	Shift             *model.Shift `json:"shift"`
+	StartDeltaMinutes *int         `json:"start_delta_minutes"`
+	EndDeltaMinutes   *int         `json:"end_delta_minutes"`
+}
+
+// RepeatDays is how many days apart the occurrences of a planned shift with
+// the recurrence start, 0 when it doesn't repeat.
+func RepeatDays(recurrence model.Recurrence) int {
+	switch recurrence {
+	case model.RecurrenceDaily:
+		return 1
+	case model.RecurrenceWeekly:
+		return 7
+	}
+	return 0
+}
+
+// FitsRepeat reports whether the planned shift ends before its next
+// occurrence starts, counting in calendar days in loc the way Expand does.
+func FitsRepeat(plan model.PlannedShift, loc *time.Location) bool {
+	days := RepeatDays(plan.Recurrence)
+	if days == 0 {
+		return true
+	}
+	return !plan.EndTs.After(plan.StartTs.In(loc).AddDate(0, 0, days))
+}
+
+// Expand returns the start times of the occurrences of the planned shift
+// that overlap [from, to), in loc. Repeats are counted from the first
+// occurrence so they stay at the same local time across DST changes.
+func Expand(
+	plan model.PlannedShift,
+	loc *time.Location,
+	from time.Time,
+	to time.Time,
+) []time.Time {
+	first := plan.StartTs.In(loc)
+	length := plan.EndTs.Sub(plan.StartTs)
+
+	days := RepeatDays(plan.Recurrence)
+	if days == 0 {
+		if first.Before(to) && first.Add(length).After(from) {
+			return []time.Time{first}
+		}
+		return nil
+	}
+
+	// skip the repeats that are over long before from, keeping one
+	// period to spare for DST changes
+	k := 0
+	if skip := int(from.Sub(first).Hours()/24)/days - 1; skip > 0 {
+		k = skip
+	}
+
+	starts := []time.Time{}
+	for ; ; k++ {
+		start := first.AddDate(0, 0, k*days)
+		if !start.Before(to) {
+			break
+		}
+		if plan.RepeatUntil != nil && start.Format(time.DateOnly) > *plan.RepeatUntil {
+			break
+		}
+		if start.Add(length).After(from) {
+			starts = append(starts, start)
+		}
+	}
+
+	return starts
+}
+
+// GetOccurrences returns the occurrences overlapping [from, to) of the
+// planned shifts matching where, ordered by start, with the actual shifts
+// clocked in for them. where is a condition on planned_shift p, task t and
+// location l, using args from $3 on.
+func GetOccurrences(
+	ctx context.Context,
+	db Querier,
+	from time.Time,
+	to time.Time,
+	where string,
+	args ...any,
+) ([]Occurrence, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT
+			p.id, p.profile_id, p.task_id, t.location_id, p.start_ts, p.end_ts,
+			p.recurrence, to_char(p.repeat_until, 'YYYY-MM-DD'), p.note,
+			COALESCE(w.time_zone, 'UTC')
+		FROM planned_shift p
+		JOIN task t ON t.id = p.task_id
+		JOIN location l ON l.id = t.location_id
+		LEFT JOIN workspace w ON w.id = l.workspace_id
+		WHERE p.start_ts < $2
+		AND (p.recurrence <> 'none' OR p.end_ts > $1)
+		AND (p.repeat_until IS NULL OR p.repeat_until >= ($1::timestamptz)::date - 1)
+		AND `+where+`
+		`,
+		append([]any{from, to}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetOccurrences: db select: %w", err)
+	}
+	defer rows.Close()
+
+	occurrences := []Occurrence{}
+	plan_ids := []int{}
+	for rows.Next() {
+		var plan model.PlannedShift
+		var time_zone string
+		err = rows.Scan(
+			&plan.Id,
+			&plan.ProfileId,
+			&plan.TaskId,
+			&plan.LocationId,
+			&plan.StartTs,
+			&plan.EndTs,
+			&plan.Recurrence,
+			&plan.RepeatUntil,
+			&plan.Note,
+			&time_zone,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("GetOccurrences: db scan: %w", err)
+		}
+
+		loc, err := time.LoadLocation(time_zone)
+		if err != nil {
+			return nil, fmt.Errorf("GetOccurrences: load location: %w", err)
+		}
+
+		length := plan.EndTs.Sub(plan.StartTs)
+		for _, start := range Expand(plan, loc, from, to) {
+			occurrences = append(occurrences, Occurrence{
+				PlannedShiftId: plan.Id,
+				ProfileId:      plan.ProfileId,
+				TaskId:         plan.TaskId,
+				LocationId:     plan.LocationId,
+				StartTs:        start,
+				EndTs:          start.Add(length),
+				Note:           plan.Note,
+			})
+		}
+		plan_ids = append(plan_ids, plan.Id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetOccurrences: rows: %w", err)
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartTs.Before(occurrences[j].StartTs)
+	})
+
+	if err := fillActualShifts(ctx, db, plan_ids, occurrences); err != nil {
+		return nil, err
+	}
+
+	return occurrences, nil
+}
+
+// occurrenceKey identifies an occurrence the way a shift links to it.
+type occurrenceKey struct {
+	plan_id int
+	start   int64
+}
+
+func fillActualShifts(
+	ctx context.Context,
+	db Querier,
+	plan_ids []int,
+	occurrences []Occurrence,
+) error {
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	index := map[occurrenceKey]int{}
+	for i := range occurrences {
+		key := occurrenceKey{occurrences[i].PlannedShiftId, occurrences[i].StartTs.Unix()}
+		index[key] = i
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`
+		SELECT id, profile_id, task_id, start_ts, end_ts, planned_shift_id, planned_start_ts
+		FROM shift
+		WHERE planned_shift_id = ANY($1)
+		AND planned_start_ts >= $2
+		AND planned_start_ts <= $3
+		`,
+		pq.Array(plan_ids),
+		occurrences[0].StartTs,
+		occurrences[len(occurrences)-1].StartTs,
+	)
+	if err != nil {
+		return fmt.Errorf("fillActualShifts: db select: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shift model.Shift
+		err = rows.Scan(
+			&shift.Id,
+			&shift.ProfileId,
+			&shift.TaskId,
+			&shift.StartTs,
+			&shift.EndTs,
+			&shift.PlannedShiftId,
+			&shift.PlannedStartTs,
+		)
+		if err != nil {
+			return fmt.Errorf("fillActualShifts: db scan: %w", err)
+		}
+
+		i, ok := index[occurrenceKey{*shift.PlannedShiftId, shift.PlannedStartTs.Unix()}]
+		if !ok {
+			continue
+		}
+		occurrence := &occurrences[i]
+
+		loc := occurrence.StartTs.Location()
+		shift.StartTs = shift.StartTs.In(loc)
+		occurrence.Shift = &shift
+
+		start_delta := int(shift.StartTs.Sub(occurrence.StartTs) / time.Minute)
+		occurrence.StartDeltaMinutes = &start_delta
+		if shift.EndTs != nil {
+			end_ts := shift.EndTs.In(loc)
+			shift.EndTs = &end_ts
+			end_delta := int(end_ts.Sub(occurrence.EndTs) / time.Minute)
+			occurrence.EndDeltaMinutes = &end_delta
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("fillActualShifts: rows: %w", err)
+	}
+
+	return nil
+}
+
+// Match returns the occurrence a shift of the profile on the task starting
+// at is clocked in for: one not clocked in for yet, planned to start no more
+// than matchWindow after at and not over yet, the one planned to start
+// closest to at. It returns nil when there is none.
+func Match(
+	ctx context.Context,
+	db Querier,
+	profile_id int,
+	task_id int,
+	at time.Time,
+) (*Occurrence, error) {
+	occurrences, err := GetOccurrences(
+		ctx,
+		db,
+		at,
+		at.Add(matchWindow),
+		`p.profile_id = $3 AND p.task_id = $4`,
+		profile_id,
+		task_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var match *Occurrence
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		if occurrence.Shift != nil {
+			continue
+		}
+		if match == nil || distance(occurrence.StartTs, at) < distance(match.StartTs, at) {
+			match = occurrence
+		}
+	}
+
+	return match, nil
+}
+
+func distance(a, b time.Time) time.Duration {
+	if a.Before(b) {
+		return b.Sub(a)
+	}
+	return a.Sub(b)
+}
@@ -0,0 +1,143 @@
+package roster
+
+import (
+	"test/internal/model"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func TestExpand(t *testing.T) {
+	london := mustLoad(t, "Europe/London")
+	until := "2026-03-31"
+
+	tests := []struct {
+		name string
+		plan model.PlannedShift
+		from time.Time
+		to   time.Time
+		want []time.Time
+	}{
+		{
+			name: "once",
+			plan: model.PlannedShift{
+				StartTs:    time.Date(2026, 3, 2, 9, 0, 0, 0, london),
+				EndTs:      time.Date(2026, 3, 2, 17, 0, 0, 0, london),
+				Recurrence: model.RecurrenceNone,
+			},
+			from: time.Date(2026, 3, 2, 12, 0, 0, 0, london),
+			to:   time.Date(2026, 3, 3, 0, 0, 0, 0, london),
+			want: []time.Time{time.Date(2026, 3, 2, 9, 0, 0, 0, london)},
+		},
+		{
+			name: "once, outside the range",
+			plan: model.PlannedShift{
+				StartTs:    time.Date(2026, 3, 2, 9, 0, 0, 0, london),
+				EndTs:      time.Date(2026, 3, 2, 17, 0, 0, 0, london),
+				Recurrence: model.RecurrenceNone,
+			},
+			from: time.Date(2026, 3, 2, 17, 0, 0, 0, london),
+			to:   time.Date(2026, 3, 3, 0, 0, 0, 0, london),
+			want: nil,
+		},
+		{
+			name: "daily across the DST change keeps local time",
+			plan: model.PlannedShift{
+				StartTs:    time.Date(2026, 3, 28, 9, 0, 0, 0, london),
+				EndTs:      time.Date(2026, 3, 28, 17, 0, 0, 0, london),
+				Recurrence: model.RecurrenceDaily,
+			},
+			from: time.Date(2026, 3, 28, 0, 0, 0, 0, london),
+			to:   time.Date(2026, 3, 31, 0, 0, 0, 0, london),
+			want: []time.Time{
+				time.Date(2026, 3, 28, 9, 0, 0, 0, london),
+				time.Date(2026, 3, 29, 9, 0, 0, 0, london),
+				time.Date(2026, 3, 30, 9, 0, 0, 0, london),
+			},
+		},
+		{
+			name: "weekly up to repeat_until",
+			plan: model.PlannedShift{
+				StartTs:     time.Date(2026, 3, 2, 9, 0, 0, 0, london),
+				EndTs:       time.Date(2026, 3, 2, 17, 0, 0, 0, london),
+				Recurrence:  model.RecurrenceWeekly,
+				RepeatUntil: &until,
+			},
+			from: time.Date(2026, 3, 20, 0, 0, 0, 0, london),
+			to:   time.Date(2026, 5, 1, 0, 0, 0, 0, london),
+			want: []time.Time{
+				time.Date(2026, 3, 23, 9, 0, 0, 0, london),
+				time.Date(2026, 3, 30, 9, 0, 0, 0, london),
+			},
+		},
+		{
+			name: "overlapping the start of the range",
+			plan: model.PlannedShift{
+				StartTs:    time.Date(2026, 3, 2, 22, 0, 0, 0, london),
+				EndTs:      time.Date(2026, 3, 3, 6, 0, 0, 0, london),
+				Recurrence: model.RecurrenceDaily,
+			},
+			from: time.Date(2026, 3, 10, 0, 0, 0, 0, london),
+			to:   time.Date(2026, 3, 10, 12, 0, 0, 0, london),
+			want: []time.Time{time.Date(2026, 3, 9, 22, 0, 0, 0, london)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Expand(tt.plan, london, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFitsRepeat(t *testing.T) {
+	london := mustLoad(t, "Europe/London")
+
+	tests := []struct {
+		name       string
+		start      time.Time
+		length     time.Duration
+		recurrence model.Recurrence
+		want       bool
+	}{
+		{"once, any length", time.Date(2026, 3, 2, 9, 0, 0, 0, london), 72 * time.Hour, model.RecurrenceNone, true},
+		{"daily, a full day", time.Date(2026, 3, 2, 9, 0, 0, 0, london), 24 * time.Hour, model.RecurrenceDaily, true},
+		{"daily, over a day", time.Date(2026, 3, 2, 9, 0, 0, 0, london), 24*time.Hour + time.Minute, model.RecurrenceDaily, false},
+		// the day the clocks go forward is 23 hours long
+		{"daily, 24 hours into a short day", time.Date(2026, 3, 28, 9, 0, 0, 0, london), 24 * time.Hour, model.RecurrenceDaily, false},
+		// and the day they go back 25
+		{"daily, 25 hours into a long day", time.Date(2026, 10, 24, 9, 0, 0, 0, london), 25 * time.Hour, model.RecurrenceDaily, true},
+		{"weekly, a full week", time.Date(2026, 3, 2, 9, 0, 0, 0, london), 7 * 24 * time.Hour, model.RecurrenceWeekly, true},
+		{"weekly, over a week", time.Date(2026, 3, 2, 9, 0, 0, 0, london), 7*24*time.Hour + time.Minute, model.RecurrenceWeekly, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := model.PlannedShift{
+				StartTs:    tt.start,
+				EndTs:      tt.start.Add(tt.length),
+				Recurrence: tt.recurrence,
+			}
+			if got := FitsRepeat(plan, london); got != tt.want {
+				t.Errorf("FitsRepeat = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
-	"os"
 	"test/internal/auth"
 	"test/internal/manage"
+	"test/internal/model"
 	"test/internal/pin"
 	"time"
 
@@ -15,7 +15,7 @@ import (
 	"github.com/go-chi/cors"
 )
 
-func CreateRouter(db *sql.DB) http.Handler {
+func CreateRouter(db *sql.DB, keys *auth.Keyring) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
@@ -32,6 +32,8 @@ func CreateRouter(db *sql.DB) http.Handler {
 
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", auth.JWKSHandler(keys))
+
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/checkhealth", checkhealthHandler(db))
 		r.Route("/auth", func(r chi.Router) {
@@ -39,18 +41,43 @@ func CreateRouter(db *sql.DB) http.Handler {
 
 			r.Post("/register", auth.RegisterHandler(db))
 			r.Post("/login", auth.LoginHandler(db))
+			r.Post("/password-login", auth.PasswordLoginHandler(db))
 			r.Post("/refresh", auth.SilentRefreshHandler(db))
 			r.Post("/reauth", auth.ReAuthHandler(db))
+			r.Post("/switch-employment", auth.SwitchEmploymentHandler(db))
+			r.Post("/logout", auth.LogoutHandler(db))
+
+			r.With(auth.SessionAuthMiddleware(keys)).
+				Get("/sessions", auth.GetSessionsHandler(db))
 		})
 
 		r.Route("/manage", func(r chi.Router) {
-			r.Post("/workspace",  manage.CreateWorkspaceHandler(db))
+			r.Use(auth.PasswordAuthMiddleware(keys))
+			r.Use(auth.RequireRoleMiddleware(db, model.RoleOwner, model.RoleAdmin, model.RoleManager))
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireRoleMiddleware(db, model.RoleOwner, model.RoleAdmin))
+
+				r.Post("/workspace",        manage.CreateWorkspaceHandler(db))
+				r.Delete("/workspaces/{id}", manage.DeleteWorkspaceHandler(db))
+				r.Patch("/workspaces/{id}",  manage.PatchWorkspaceHandler(db))
+				r.Delete("/companies/{id}",  manage.DeleteCompanyHandler(db))
+				r.Delete("/contracts/{id}",  manage.DeleteContractHandler(db))
+			})
+
 			r.Post("/company",    manage.CreateCompanyHandler(db))
 			r.Post("/location",   manage.CreateLocationHandler(db))
 			r.Post("/task",       manage.CreateTaskHandler(db))
 			r.Post("/contract",   manage.CreateContractHandler(db))
+			r.Post("/break-type", manage.CreateBreakTypeHandler(db))
+			r.Post("/pay-rule",   manage.CreatePayRuleHandler(db))
+			r.Post("/holiday",    manage.CreateHolidayHandler(db))
+			r.Post("/planned-shift", manage.CreatePlannedShiftHandler(db))
+			r.Post("/contracts/{id}/versions", manage.ScheduleContractVersionHandler(db))
 			r.Post("/employment", manage.CreateEmploymentHandler(db))
-			r.Post("/profile",    auth.RegisterHandler(db))
+			r.Post("/profile",    manage.CreateProfileHandler(db))
+			r.Post("/profiles/{id}/unlock", auth.UnlockProfileHandler(db))
+			r.Post("/profiles/{id}/reset-pin", auth.ResetPinHandler(db))
 
 			r.Get("/workspaces",  manage.GetWorkspacesHandler(db))
 			r.Get("/companies",   manage.GetCompaniesHandler(db))
@@ -58,40 +85,68 @@ func CreateRouter(db *sql.DB) http.Handler {
 			r.Get("/tasks",       manage.GetTasksHandler(db))
 			r.Get("/profiles",    manage.GetProfilesHandler(db))
 			r.Get("/employments",    manage.GetEmploymentsHandler(db))
+			r.Get("/employments/{id}/earnings", manage.GetEmploymentEarningsHandler(db))
 			r.Get("/contracts",    manage.GetContractsHandler(db))
+			r.Get("/contracts/{id}/versions", manage.GetContractVersionsHandler(db))
+			r.Get("/break-types",  manage.GetBreakTypesHandler(db))
+			r.Get("/pay-rules",    manage.GetPayRulesHandler(db))
+			r.Get("/holidays",     manage.GetHolidaysHandler(db))
 			r.Get("/shifts",      manage.GetShiftsHandler(db))
+			r.Get("/shifts/flagged", manage.GetFlaggedShiftsHandler(db))
+			r.Get("/planned-shifts", manage.GetPlannedShiftsHandler(db))
+			r.Get("/roster",      manage.GetRosterHandler(db))
+			r.Get("/edit-requests", manage.GetEditRequestsHandler(db))
+			r.Post("/edit-requests/{id}/approve", manage.ApproveEditRequestHandler(db))
+			r.Post("/edit-requests/{id}/reject",  manage.RejectEditRequestHandler(db))
 
-			r.Delete("/workspaces/{id}",  manage.DeleteWorkspaceHandler(db))
-			r.Delete("/companies/{id}",   manage.DeleteCompanyHandler(db))
 			r.Delete("/locations/{id}",   manage.DeleteLocationHandler(db))
 			r.Delete("/tasks/{id}",       manage.DeleteTaskHandler(db))
 			r.Delete("/profiles/{id}",    manage.DeleteProfileHandler(db))
-			r.Delete("/contracts/{id}",   manage.DeleteContractHandler(db))
+			r.Delete("/profiles/{id}/sessions", auth.RevokeProfileSessionsHandler(db))
 			r.Delete("/shifts/{id}",      manage.DeleteShiftHandler(db))
+			r.Delete("/break-types/{id}", manage.DeleteBreakTypeHandler(db))
+			r.Delete("/pay-rules/{id}",   manage.DeletePayRuleHandler(db))
+			r.Delete("/holidays/{id}",    manage.DeleteHolidayHandler(db))
+			r.Delete("/contract-versions/{id}", manage.DeleteContractVersionHandler(db))
+			r.Delete("/planned-shifts/{id}", manage.DeletePlannedShiftHandler(db))
 
-			r.Patch("/workspaces/{id}",  manage.PatchWorkspaceHandler(db))
 			r.Patch("/companies/{id}",   manage.PatchCompanyHandler(db))
 			r.Patch("/locations/{id}",   manage.PatchLocationHandler(db))
 			r.Patch("/tasks/{id}",       manage.PatchTaskHandler(db))
 			r.Patch("/profiles/{id}",    manage.PatchProfileHandler(db))
 			r.Patch("/contracts/{id}",   manage.PatchContractHandler(db))
 			r.Patch("/shifts/{id}",      manage.PatchShiftHandler(db))
+			r.Patch("/break-types/{id}", manage.PatchBreakTypeHandler(db))
+			r.Patch("/pay-rules/{id}",   manage.PatchPayRuleHandler(db))
+			r.Patch("/planned-shifts/{id}", manage.PatchPlannedShiftHandler(db))
 		})
 
 		r.Route("/pin", func(r chi.Router) {
-			r.Use(auth.PinAuthMiddleware([]byte(os.Getenv("JWT_SECRET"))))
+			r.Use(auth.PinAuthMiddleware(keys))
 			r.Use(auth.DeviceIdMiddleware())
 
-			r.Post("/clock-in", pin.ClockInHandler(db))
-			r.Post("/clock-out", pin.ClockOutHandler(db))
-			r.Post("/sync-shift", pin.SyncShiftHandler(db))
-			r.Get("/shift-overview", pin.ShiftOverviewHandler(db))
-			r.Get("/shift-history", pin.ShiftHistoryHandler(db))
-			r.Get("/locations", pin.GetLocationsHandler(db))
-			r.Get("/tasks", pin.GetTasksHandler(db))
-			r.Get("/employments-detailed", pin.GetEmploymentsDetailedHandler(db))
-			r.Get("/my-pin", pin.GetPinHandler(db))
-			r.Post("/send-edit-request", pin.PostEditRequestHandler(db))
+			r.Post("/change-pin", auth.ChangePinHandler(db))
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequirePinChangedMiddleware())
+
+				r.Post("/clock-in", pin.ClockInHandler(db))
+				r.Post("/clock-out", pin.ClockOutHandler(db))
+				r.Post("/sync-shifts", pin.SyncShiftsHandler(db))
+				r.Post("/switch-task", pin.SwitchTaskHandler(db))
+				r.Post("/break-start", pin.StartBreakHandler(db))
+				r.Post("/break-end", pin.EndBreakHandler(db))
+				r.Get("/break-types", pin.GetBreakTypesHandler(db))
+				r.Get("/shift-overview", pin.ShiftOverviewHandler(db))
+				r.Get("/shift-history", pin.ShiftHistoryHandler(db))
+				r.Get("/schedule", pin.ScheduleHandler(db))
+				r.Get("/locations", pin.GetLocationsHandler(db))
+				r.Get("/tasks", pin.GetTasksHandler(db))
+				r.Get("/employments-detailed", pin.GetEmploymentsDetailedHandler(db))
+				r.Post("/send-edit-request", pin.PostEditRequestHandler(db))
+				r.Get("/edit-requests", pin.GetEditRequestsHandler(db))
+				r.Post("/edit-requests/{id}/cancel", pin.CancelEditRequestHandler(db))
+			})
 		})
 	})
 